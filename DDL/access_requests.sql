@@ -0,0 +1,55 @@
+--Who may approve requests for a role: its owner, the owner of the requester's group
+--and/or the requester's manager, as listed in roles.approvers
+ALTER TABLE roles ADD COLUMN owner_id INT NULL;
+ALTER TABLE roles ADD COLUMN approvers TEXT[] NOT NULL DEFAULT '{owner}';
+ALTER TABLE groups ADD COLUMN owner_id INT NULL;
+ALTER TABLE users ADD COLUMN manager_id INT NULL;
+
+ALTER TABLE roles ADD CONSTRAINT rl_ownid_fk FOREIGN KEY(owner_id) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE groups ADD CONSTRAINT grp_ownid_fk FOREIGN KEY(owner_id) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE users ADD CONSTRAINT usr_mgrid_fk FOREIGN KEY(manager_id) REFERENCES users(user_id)
+ON DELETE SET NULL;
+
+CREATE TABLE access_requests(
+    request_id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    role_id INT NOT NULL,
+    justification TEXT NOT NULL,
+    status VARCHAR(9) NOT NULL DEFAULT 'pending'
+        CHECK(status IN ('pending', 'approved', 'rejected', 'cancelled')),
+    --Window of the grant made on approval
+    valid_from TIMESTAMP NULL,
+    valid_until TIMESTAMP NULL,
+    decided_by INT NULL,
+    decision_note TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    decided_at TIMESTAMP NULL
+);
+
+--A user can only have one open request per role
+CREATE UNIQUE INDEX acc_req_pending_idx ON access_requests(user_id, role_id) WHERE status = 'pending';
+
+ALTER TABLE access_requests ADD CONSTRAINT acc_req_usrid_fk FOREIGN KEY(user_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE access_requests ADD CONSTRAINT acc_req_rid_fk FOREIGN KEY(role_id) REFERENCES roles(role_id)
+ON DELETE CASCADE;
+ALTER TABLE access_requests ADD CONSTRAINT acc_req_decid_fk FOREIGN KEY(decided_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+
+--Every state transition of a request, starting with its creation
+CREATE TABLE access_request_events(
+    event_id BIGSERIAL PRIMARY KEY,
+    request_id INT NOT NULL,
+    from_status VARCHAR(9) NULL,
+    to_status VARCHAR(9) NOT NULL,
+    actor_id INT NULL,
+    note TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE access_request_events ADD CONSTRAINT acc_req_evt_reqid_fk FOREIGN KEY(request_id) REFERENCES access_requests(request_id)
+ON DELETE CASCADE;
+ALTER TABLE access_request_events ADD CONSTRAINT acc_req_evt_actid_fk FOREIGN KEY(actor_id) REFERENCES users(user_id)
+ON DELETE SET NULL;
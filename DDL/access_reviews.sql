@@ -0,0 +1,43 @@
+--Certification campaigns. Opening a campaign snapshots every user_role, group_role and
+--role_privilege grant into review_items; closing it revokes the items decided as revoke.
+CREATE TABLE review_campaigns(
+    campaign_id BIGSERIAL PRIMARY KEY,
+    campaign_name VARCHAR(64) UNIQUE NOT NULL,
+    description VARCHAR(255),
+    status VARCHAR(6) NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'closed')),
+    due_at TIMESTAMP NULL,
+    created_by INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    closed_by INT NULL,
+    closed_at TIMESTAMP NULL
+);
+
+ALTER TABLE review_campaigns ADD CONSTRAINT rev_cmp_crtby_fk FOREIGN KEY(created_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE review_campaigns ADD CONSTRAINT rev_cmp_clsby_fk FOREIGN KEY(closed_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+
+--Names are copied so the report still reads after users, roles or privileges are deleted
+CREATE TABLE review_items(
+    item_id BIGSERIAL PRIMARY KEY,
+    campaign_id INT NOT NULL,
+    --user_role, group_role or role_privilege, as in grant_events
+    grant_type VARCHAR(20) NOT NULL,
+    subject_id INT NOT NULL,
+    subject_name VARCHAR(64) NOT NULL,
+    object_id INT NOT NULL,
+    object_name VARCHAR(64) NOT NULL,
+    valid_from TIMESTAMP NULL,
+    valid_until TIMESTAMP NULL,
+    reviewer_id INT NULL,
+    decision VARCHAR(6) NULL CHECK(decision IN ('keep', 'revoke')),
+    comment TEXT NULL,
+    decided_at TIMESTAMP NULL,
+    --Set at close when the revoke was carried out. Left NULL if the grant was already gone
+    revoked_at TIMESTAMP NULL
+);
+
+ALTER TABLE review_items ADD CONSTRAINT rev_itm_cmpid_fk FOREIGN KEY(campaign_id) REFERENCES review_campaigns(campaign_id)
+ON DELETE CASCADE;
+ALTER TABLE review_items ADD CONSTRAINT rev_itm_revid_fk FOREIGN KEY(reviewer_id) REFERENCES users(user_id)
+ON DELETE SET NULL;
@@ -0,0 +1,43 @@
+--Working calendars. An employee works by the calendar of their location, or by the default
+--calendar of the tenant if their location has none. work_weekdays are ISO weekdays, 1 is Monday.
+CREATE TABLE calendars(
+    calendar_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    calendar_name VARCHAR(64) NOT NULL,
+    location VARCHAR(64) NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    work_weekdays SMALLINT[] NOT NULL DEFAULT '{1,2,3,4,5}'
+        CHECK(work_weekdays <@ '{1,2,3,4,5,6,7}'),
+    hours_per_day NUMERIC(4, 2) NOT NULL DEFAULT 8 CHECK(hours_per_day > 0 AND hours_per_day <= 24),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT cal_tnt_name_key UNIQUE(tenant_id, calendar_name),
+    CONSTRAINT cal_tnt_loc_key UNIQUE(tenant_id, location)
+);
+
+--One default calendar per tenant
+CREATE UNIQUE INDEX cal_tnt_default_idx ON calendars(tenant_id) WHERE is_default;
+
+ALTER TABLE calendars ADD CONSTRAINT cal_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--Days off on a calendar: public holidays and company closures. source is manual or ical,
+--uid is the UID of the iCalendar event a day was imported from.
+CREATE TABLE calendar_days(
+    day_id BIGSERIAL PRIMARY KEY,
+    calendar_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    day DATE NOT NULL,
+    day_type VARCHAR(7) NOT NULL CHECK(day_type IN ('holiday', 'closure')),
+    day_name VARCHAR(128) NOT NULL,
+    source VARCHAR(6) NOT NULL DEFAULT 'manual' CHECK(source IN ('manual', 'ical')),
+    uid TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT cal_day_key UNIQUE(calendar_id, day)
+);
+
+ALTER TABLE calendar_days ADD CONSTRAINT cal_day_calid_fk FOREIGN KEY(calendar_id) REFERENCES calendars(calendar_id)
+ON DELETE CASCADE;
+ALTER TABLE calendar_days ADD CONSTRAINT cal_day_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--Every tenant starts with a Monday to Friday default calendar
+INSERT INTO calendars(tenant_id, calendar_name, is_default)
+SELECT tenant_id, 'Default', TRUE FROM tenants;
@@ -0,0 +1,71 @@
+--Cost centers carry the budget. A department may name the cost center its employees are
+--charged to, an assignment can override it.
+CREATE TABLE cost_centers(
+    cost_center_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    cost_center_code VARCHAR(32) NOT NULL,
+    cost_center_name VARCHAR(64) NOT NULL,
+    head_id INT NULL,
+    budget NUMERIC(14, 2) NULL,
+    currency CHAR(3) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT cc_tnt_code_key UNIQUE(tenant_id, cost_center_code)
+);
+
+ALTER TABLE cost_centers ADD CONSTRAINT cc_headid_fk FOREIGN KEY(head_id) REFERENCES employees(user_id)
+ON DELETE SET NULL;
+ALTER TABLE cost_centers ADD CONSTRAINT cc_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--Departments form a tree through parent_department_id. Cycles are rejected by the API.
+CREATE TABLE departments(
+    department_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    department_code VARCHAR(32) NOT NULL,
+    department_name VARCHAR(64) NOT NULL,
+    parent_department_id INT NULL,
+    head_id INT NULL,
+    cost_center_id INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT dept_tnt_code_key UNIQUE(tenant_id, department_code),
+    CHECK(parent_department_id <> department_id)
+);
+
+ALTER TABLE departments ADD CONSTRAINT dept_parentid_fk FOREIGN KEY(parent_department_id) REFERENCES departments(department_id);
+ALTER TABLE departments ADD CONSTRAINT dept_headid_fk FOREIGN KEY(head_id) REFERENCES employees(user_id)
+ON DELETE SET NULL;
+ALTER TABLE departments ADD CONSTRAINT dept_ccid_fk FOREIGN KEY(cost_center_id) REFERENCES cost_centers(cost_center_id);
+ALTER TABLE departments ADD CONSTRAINT dept_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--Which department an employee works in from effective_from to effective_to, both inclusive.
+--A NULL effective_to is open ended. The API keeps the periods of an employee from overlapping.
+CREATE TABLE employee_assignments(
+    assignment_id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    department_id INT NOT NULL,
+    cost_center_id INT NULL,
+    effective_from DATE NOT NULL,
+    effective_to DATE NULL,
+    created_by INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK(effective_to IS NULL OR effective_to >= effective_from)
+);
+
+ALTER TABLE employee_assignments ADD CONSTRAINT emp_asg_uid_fk FOREIGN KEY(user_id) REFERENCES employees(user_id)
+ON DELETE CASCADE;
+ALTER TABLE employee_assignments ADD CONSTRAINT emp_asg_deptid_fk FOREIGN KEY(department_id) REFERENCES departments(department_id);
+ALTER TABLE employee_assignments ADD CONSTRAINT emp_asg_ccid_fk FOREIGN KEY(cost_center_id) REFERENCES cost_centers(cost_center_id);
+ALTER TABLE employee_assignments ADD CONSTRAINT emp_asg_crby_fk FOREIGN KEY(created_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE employee_assignments ADD CONSTRAINT emp_asg_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+CREATE INDEX emp_asg_uid_idx ON employee_assignments(user_id);
+CREATE INDEX emp_asg_deptid_idx ON employee_assignments(department_id);
+
+--Turn the free text departments of employees into departments and assignments
+INSERT INTO departments(tenant_id, department_code, department_name)
+SELECT DISTINCT tenant_id, LEFT(department, 32), department FROM employees WHERE department IS NOT NULL
+ON CONFLICT DO NOTHING;
+INSERT INTO employee_assignments(user_id, tenant_id, department_id, effective_from)
+SELECT e.user_id, e.tenant_id, d.department_id, COALESCE(e.hire_date, CURRENT_DATE) FROM employees e
+JOIN departments d ON d.tenant_id = e.tenant_id AND d.department_name = e.department;
+ALTER TABLE employees DROP COLUMN department;
@@ -0,0 +1,70 @@
+--Kinds of employee documents. allowed_types are the media types a file of the category may
+--have, as sniffed from its first bytes, and max_bytes caps its size. read_privilege and
+--write_privilege, when set, are needed on top of the route's privilege to read, or to
+--upload and delete, documents of the category.
+CREATE TABLE document_categories(
+    category_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    category_code VARCHAR(32) NOT NULL,
+    category_name VARCHAR(64) NOT NULL,
+    allowed_types TEXT[] NOT NULL,
+    --At most 25 MiB, the largest upload the server accepts
+    max_bytes INT NOT NULL CHECK(max_bytes > 0 AND max_bytes <= 26214400),
+    read_privilege VARCHAR(64) NULL,
+    write_privilege VARCHAR(64) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT doc_cat_tnt_code_key UNIQUE(tenant_id, category_code)
+);
+
+ALTER TABLE document_categories ADD CONSTRAINT doc_cat_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+INSERT INTO document_categories(tenant_id, category_code, category_name, allowed_types, max_bytes)
+SELECT tenant_id, 'contract', 'Contracts', ARRAY['application/pdf'], 10485760 FROM tenants
+UNION ALL
+SELECT tenant_id, 'identity', 'Identity documents', ARRAY['application/pdf', 'image/jpeg', 'image/png'], 10485760 FROM tenants
+UNION ALL
+SELECT tenant_id, 'certificate', 'Certificates', ARRAY['application/pdf', 'image/jpeg', 'image/png'], 10485760 FROM tenants;
+
+--A document attached to an employee record. The content lives in the blob store under
+--storage_key; expires_on is when the document itself runs out, e.g. a passport.
+CREATE TABLE employee_documents(
+    document_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    user_id INT NOT NULL,
+    category_id INT NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size_bytes INT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    storage_key VARCHAR(255) UNIQUE NOT NULL,
+    expires_on DATE NULL,
+    uploaded_by INT NULL,
+    uploaded_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX emp_doc_uid_idx ON employee_documents(user_id);
+
+ALTER TABLE employee_documents ADD CONSTRAINT emp_doc_uid_fk FOREIGN KEY(user_id) REFERENCES employees(user_id)
+ON DELETE CASCADE;
+--Categories still holding documents cannot be deleted
+ALTER TABLE employee_documents ADD CONSTRAINT emp_doc_catid_fk FOREIGN KEY(category_id) REFERENCES document_categories(category_id);
+ALTER TABLE employee_documents ADD CONSTRAINT emp_doc_upby_fk FOREIGN KEY(uploaded_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE employee_documents ADD CONSTRAINT emp_doc_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--Contents to remove from the blob store. Every deleted document row lands here, also when
+--the employee record or user goes, and the purge job deletes the content.
+CREATE TABLE document_purges(
+    storage_key VARCHAR(255) PRIMARY KEY,
+    queued_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE FUNCTION queue_document_purge() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO document_purges(storage_key) VALUES (OLD.storage_key) ON CONFLICT DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER emp_doc_purge_trg AFTER DELETE ON employee_documents
+FOR EACH ROW EXECUTE PROCEDURE queue_document_purge();
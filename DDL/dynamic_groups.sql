@@ -0,0 +1,28 @@
+--Attributes membership rules can refer to as user.department, user.job_title,
+--user.location and user.employment_type
+ALTER TABLE users ADD COLUMN department VARCHAR(64) NULL;
+ALTER TABLE users ADD COLUMN job_title VARCHAR(64) NULL;
+ALTER TABLE users ADD COLUMN location VARCHAR(64) NULL;
+ALTER TABLE users ADD COLUMN employment_type VARCHAR(32) NULL;
+
+--A group with a membership rule is dynamic: its members are the users of its tenant the
+--rule holds for, kept in group_rule_members by the sync. Users are not added to it by hand,
+--group_members (DDL/group_members.sql) only holds the members of static groups. A user's
+--groups are the union of both tables.
+ALTER TABLE groups ADD COLUMN membership_rule TEXT NULL;
+ALTER TABLE groups ADD COLUMN rule_evaluated_at TIMESTAMP NULL;
+
+CREATE TABLE group_rule_members(
+    group_id INT NOT NULL,
+    user_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    added_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY(group_id, user_id)
+);
+
+ALTER TABLE group_rule_members ADD CONSTRAINT grp_rl_mem_gid_fk FOREIGN KEY(group_id) REFERENCES groups(group_id)
+ON DELETE CASCADE;
+ALTER TABLE group_rule_members ADD CONSTRAINT grp_rl_mem_uid_fk FOREIGN KEY(user_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE group_rule_members ADD CONSTRAINT grp_rl_mem_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+CREATE INDEX grp_rl_mem_uid_idx ON group_rule_members(user_id);
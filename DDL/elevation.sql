@@ -0,0 +1,51 @@
+--Just-in-time elevation. An eligibility lets a user activate a role for at most max_minutes
+--at a time; the role is only in force while an activation is active and not expired.
+CREATE TABLE role_eligibilities(
+    eligibility_id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    role_id INT NOT NULL,
+    max_minutes INT NOT NULL DEFAULT 60 CHECK(max_minutes > 0),
+    requires_approval BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, role_id)
+);
+
+ALTER TABLE role_eligibilities ADD CONSTRAINT rl_elg_usrid_fk FOREIGN KEY(user_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE role_eligibilities ADD CONSTRAINT rl_elg_rid_fk FOREIGN KEY(role_id) REFERENCES roles(role_id)
+ON DELETE CASCADE;
+ALTER TABLE role_eligibilities ADD CONSTRAINT rl_elg_crtby_fk FOREIGN KEY(created_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+
+CREATE TABLE role_activations(
+    activation_id BIGSERIAL PRIMARY KEY,
+    --NULL once the eligibility is removed, the activation stays as history
+    eligibility_id INT NULL,
+    user_id INT NOT NULL,
+    role_id INT NOT NULL,
+    reason TEXT NOT NULL,
+    minutes INT NOT NULL CHECK(minutes > 0),
+    --pending (awaiting approval), active, rejected, expired or deactivated
+    status VARCHAR(11) NOT NULL,
+    decided_by INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    activated_at TIMESTAMP NULL,
+    expires_at TIMESTAMP NULL,
+    ended_at TIMESTAMP NULL
+);
+
+--One open activation per user and role
+CREATE UNIQUE INDEX rl_act_open_idx ON role_activations(user_id, role_id) WHERE status IN ('pending', 'active');
+
+ALTER TABLE role_activations ADD CONSTRAINT rl_act_elgid_fk FOREIGN KEY(eligibility_id) REFERENCES role_eligibilities(eligibility_id)
+ON DELETE SET NULL;
+ALTER TABLE role_activations ADD CONSTRAINT rl_act_usrid_fk FOREIGN KEY(user_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE role_activations ADD CONSTRAINT rl_act_rid_fk FOREIGN KEY(role_id) REFERENCES roles(role_id)
+ON DELETE CASCADE;
+ALTER TABLE role_activations ADD CONSTRAINT rl_act_decby_fk FOREIGN KEY(decided_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+
+--The grant sweeper closes expired activations and logs them as role_activation grants
+COMMENT ON COLUMN grant_events.grant_type IS 'user_role, role_privilege, group_role or role_activation';
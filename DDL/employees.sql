@@ -0,0 +1,49 @@
+--HR record of a user. department, job_title, location and employment_type move here from
+--users and stay available to membership rules as user.department and so on.
+CREATE TABLE employees(
+    user_id INT PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    employee_number VARCHAR(32) NOT NULL,
+    hire_date DATE NULL,
+    job_title VARCHAR(64) NULL,
+    department VARCHAR(64) NULL,
+    location VARCHAR(64) NULL,
+    employment_type VARCHAR(32) NULL,
+    work_email VARCHAR(254) NULL,
+    work_phone VARCHAR(32) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT emp_tnt_num_key UNIQUE(tenant_id, employee_number)
+);
+
+ALTER TABLE employees ADD CONSTRAINT emp_uid_fk FOREIGN KEY(user_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE employees ADD CONSTRAINT emp_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--Carry over the attributes users already had. The employee number defaults to the user id
+INSERT INTO employees(user_id, tenant_id, employee_number, job_title, department, location, employment_type)
+SELECT user_id, tenant_id, user_id::TEXT, job_title, department, location, employment_type FROM users
+WHERE job_title IS NOT NULL OR department IS NOT NULL OR location IS NOT NULL OR employment_type IS NOT NULL;
+ALTER TABLE users DROP COLUMN department;
+ALTER TABLE users DROP COLUMN job_title;
+ALTER TABLE users DROP COLUMN location;
+ALTER TABLE users DROP COLUMN employment_type;
+
+--One row per field changed. Kept after the profile is deleted, until the user is.
+CREATE TABLE employee_history(
+    history_id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    field VARCHAR(32) NOT NULL,
+    old_value TEXT NULL,
+    new_value TEXT NULL,
+    changed_by INT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE employee_history ADD CONSTRAINT emp_hist_uid_fk FOREIGN KEY(user_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE employee_history ADD CONSTRAINT emp_hist_chby_fk FOREIGN KEY(changed_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE employee_history ADD CONSTRAINT emp_hist_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+CREATE INDEX emp_hist_uid_idx ON employee_history(user_id);
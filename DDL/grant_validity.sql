@@ -0,0 +1,23 @@
+--Every grant can be limited to a window. NULL bounds are open ended.
+ALTER TABLE users ADD COLUMN role_valid_from TIMESTAMP NULL;
+ALTER TABLE users ADD COLUMN role_valid_until TIMESTAMP NULL;
+
+ALTER TABLE role_privileges ADD COLUMN valid_from TIMESTAMP NULL;
+ALTER TABLE role_privileges ADD COLUMN valid_until TIMESTAMP NULL;
+
+ALTER TABLE group_roles ADD COLUMN valid_from TIMESTAMP NULL;
+ALTER TABLE group_roles ADD COLUMN valid_until TIMESTAMP NULL;
+
+--Written by the sweeper whenever it revokes an expired grant
+CREATE TABLE grant_events(
+    event_id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(20) NOT NULL,
+    --user_role, role_privilege or group_role
+    grant_type VARCHAR(20) NOT NULL,
+    --user_id for user_role, role_id for role_privilege, group_id for group_role
+    subject_id INT NOT NULL,
+    --role_id for user_role and group_role, privilege_id for role_privilege
+    object_id INT NOT NULL,
+    valid_until TIMESTAMP NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,15 @@
+--A parent group contains its child groups. Members of a child are members of every
+--group above it and get the roles of all of them. Cycles are rejected by the API.
+CREATE TABLE group_hierarchy(
+    parent_group_id INT NOT NULL,
+    child_group_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    PRIMARY KEY(parent_group_id, child_group_id),
+    CHECK(parent_group_id <> child_group_id)
+);
+
+ALTER TABLE group_hierarchy ADD CONSTRAINT grp_hier_pgid_fk FOREIGN KEY(parent_group_id) REFERENCES groups(group_id)
+ON DELETE CASCADE;
+ALTER TABLE group_hierarchy ADD CONSTRAINT grp_hier_cgid_fk FOREIGN KEY(child_group_id) REFERENCES groups(group_id)
+ON DELETE CASCADE;
+ALTER TABLE group_hierarchy ADD CONSTRAINT grp_hier_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
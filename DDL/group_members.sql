@@ -0,0 +1,27 @@
+--A user can be in any number of groups. Memberships past expires_at no longer count
+--and are removed by the grant sweeper. Members of dynamic groups stay in group_rule_members.
+CREATE TABLE group_members(
+    group_id INT NOT NULL,
+    user_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    added_by INT NULL,
+    added_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NULL,
+    PRIMARY KEY(group_id, user_id)
+);
+
+ALTER TABLE group_members ADD CONSTRAINT grp_mem_gid_fk FOREIGN KEY(group_id) REFERENCES groups(group_id)
+ON DELETE CASCADE;
+ALTER TABLE group_members ADD CONSTRAINT grp_mem_uid_fk FOREIGN KEY(user_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE group_members ADD CONSTRAINT grp_mem_addby_fk FOREIGN KEY(added_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE group_members ADD CONSTRAINT grp_mem_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+CREATE INDEX grp_mem_uid_idx ON group_members(user_id);
+
+--Carry over the single group each user had
+INSERT INTO group_members(group_id, user_id, tenant_id)
+SELECT group_id, user_id, tenant_id FROM users WHERE group_id IS NOT NULL;
+ALTER TABLE users DROP COLUMN group_id;
+
+COMMENT ON COLUMN grant_events.grant_type IS 'user_role, role_privilege, group_role, role_activation or group_membership';
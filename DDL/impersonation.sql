@@ -0,0 +1,13 @@
+--Every impersonation token issued and every request made with one. actor_id is the admin,
+--subject_id the user being impersonated. No foreign keys, the trail outlives deleted users.
+CREATE TABLE impersonation_audit(
+    audit_id BIGSERIAL PRIMARY KEY,
+    actor_id INT NOT NULL,
+    subject_id INT NOT NULL,
+    --issued or request
+    event VARCHAR(10) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(255) NOT NULL,
+    status INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
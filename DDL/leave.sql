@@ -0,0 +1,83 @@
+--Kinds of leave. A type that tracks a balance can only be taken up to what was accrued or
+--adjusted in. accrual_days are credited every month or year up to max_balance.
+CREATE TABLE leave_types(
+    leave_type_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    leave_type_code VARCHAR(32) NOT NULL,
+    leave_type_name VARCHAR(64) NOT NULL,
+    paid BOOLEAN NOT NULL DEFAULT TRUE,
+    tracks_balance BOOLEAN NOT NULL DEFAULT TRUE,
+    accrual_method VARCHAR(7) NOT NULL DEFAULT 'none'
+        CHECK(accrual_method IN ('none', 'monthly', 'yearly')),
+    accrual_days NUMERIC(6, 2) NOT NULL DEFAULT 0 CHECK(accrual_days >= 0),
+    max_balance NUMERIC(6, 2) NULL CHECK(max_balance >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT lt_tnt_code_key UNIQUE(tenant_id, leave_type_code)
+);
+
+ALTER TABLE leave_types ADD CONSTRAINT lt_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+INSERT INTO leave_types(tenant_id, leave_type_code, leave_type_name, paid, tracks_balance, accrual_method, accrual_days, max_balance)
+SELECT tenant_id, 'annual', 'Annual leave', TRUE, TRUE, 'monthly', 2, 30 FROM tenants
+UNION ALL
+SELECT tenant_id, 'sick', 'Sick leave', TRUE, TRUE, 'yearly', 10, 10 FROM tenants
+UNION ALL
+SELECT tenant_id, 'unpaid', 'Unpaid leave', FALSE, FALSE, 'none', 0, NULL FROM tenants;
+
+--A request for leave from start_date to end_date, both inclusive. days is the number of
+--working days in the period, worked out when the request is made.
+CREATE TABLE leave_requests(
+    request_id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    leave_type_id INT NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    days NUMERIC(6, 2) NOT NULL,
+    reason TEXT NULL,
+    status VARCHAR(9) NOT NULL DEFAULT 'pending'
+        CHECK(status IN ('pending', 'approved', 'rejected', 'cancelled')),
+    decided_by INT NULL,
+    decision_note TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    decided_at TIMESTAMP NULL,
+    CHECK(end_date >= start_date)
+);
+
+ALTER TABLE leave_requests ADD CONSTRAINT lv_req_uid_fk FOREIGN KEY(user_id) REFERENCES employees(user_id)
+ON DELETE CASCADE;
+ALTER TABLE leave_requests ADD CONSTRAINT lv_req_ltid_fk FOREIGN KEY(leave_type_id) REFERENCES leave_types(leave_type_id);
+ALTER TABLE leave_requests ADD CONSTRAINT lv_req_decid_fk FOREIGN KEY(decided_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE leave_requests ADD CONSTRAINT lv_req_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+CREATE INDEX lv_req_uid_idx ON leave_requests(user_id, start_date);
+
+--Every change to a balance. The balance of an employee for a type is the sum of days.
+--kind is accrual, adjustment, taken (negative, on approval) or reversal (of a cancelled approval)
+CREATE TABLE leave_ledger(
+    entry_id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    leave_type_id INT NOT NULL,
+    days NUMERIC(6, 2) NOT NULL,
+    kind VARCHAR(10) NOT NULL CHECK(kind IN ('accrual', 'adjustment', 'taken', 'reversal')),
+    --The month or year an accrual is for
+    period DATE NULL,
+    request_id INT NULL,
+    note TEXT NULL,
+    created_by INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+--Accrual runs are repeated safely, one accrual per employee, type and period
+CREATE UNIQUE INDEX lv_ldg_accrual_idx ON leave_ledger(user_id, leave_type_id, period) WHERE kind = 'accrual';
+CREATE INDEX lv_ldg_uid_idx ON leave_ledger(user_id, leave_type_id);
+
+ALTER TABLE leave_ledger ADD CONSTRAINT lv_ldg_uid_fk FOREIGN KEY(user_id) REFERENCES employees(user_id)
+ON DELETE CASCADE;
+ALTER TABLE leave_ledger ADD CONSTRAINT lv_ldg_ltid_fk FOREIGN KEY(leave_type_id) REFERENCES leave_types(leave_type_id);
+ALTER TABLE leave_ledger ADD CONSTRAINT lv_ldg_reqid_fk FOREIGN KEY(request_id) REFERENCES leave_requests(request_id)
+ON DELETE SET NULL;
+ALTER TABLE leave_ledger ADD CONSTRAINT lv_ldg_crby_fk FOREIGN KEY(created_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE leave_ledger ADD CONSTRAINT lv_ldg_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
@@ -0,0 +1,37 @@
+--Employment lifecycle. Suspended and terminated users cannot authenticate and their tokens
+--stop working. Tokens issued before tokens_valid_after are rejected, it is moved on suspension
+--and termination. Everybody existing before lifecycle states is active.
+ALTER TABLE users ADD COLUMN status VARCHAR(10) NOT NULL DEFAULT 'active'
+    CHECK(status IN ('pending', 'active', 'on_leave', 'suspended', 'terminated'));
+ALTER TABLE users ADD COLUMN status_since DATE NOT NULL DEFAULT CURRENT_DATE;
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP NULL;
+
+--Every status change of a user. A change dated in the future stays scheduled until the day
+--it takes effect; it is then applied, or cancelled when the user's status no longer allows it.
+CREATE TABLE user_status_changes(
+    change_id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    from_status VARCHAR(10) NULL,
+    to_status VARCHAR(10) NOT NULL
+        CHECK(to_status IN ('pending', 'active', 'on_leave', 'suspended', 'terminated')),
+    effective_date DATE NOT NULL,
+    reason TEXT NULL,
+    --scheduled, applied or cancelled
+    state VARCHAR(9) NOT NULL DEFAULT 'scheduled' CHECK(state IN ('scheduled', 'applied', 'cancelled')),
+    changed_by INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    applied_at TIMESTAMP NULL
+);
+
+--One scheduled change per user at a time
+CREATE UNIQUE INDEX usr_sts_scheduled_idx ON user_status_changes(user_id) WHERE state = 'scheduled';
+CREATE INDEX usr_sts_uid_idx ON user_status_changes(user_id, created_at);
+
+ALTER TABLE user_status_changes ADD CONSTRAINT usr_sts_uid_fk FOREIGN KEY(user_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE user_status_changes ADD CONSTRAINT usr_sts_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+ALTER TABLE user_status_changes ADD CONSTRAINT usr_sts_chgby_fk FOREIGN KEY(changed_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+
+COMMENT ON COLUMN grant_events.event_type IS 'expired, review_revoked or terminated';
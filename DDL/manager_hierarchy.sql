@@ -0,0 +1,17 @@
+--Who an employee reports to. Moves here from users.manager_id. Both ends need an employee
+--record, loops are rejected by the API.
+ALTER TABLE employees ADD COLUMN manager_id INT NULL;
+ALTER TABLE employees ADD CONSTRAINT emp_mgrid_fk FOREIGN KEY(manager_id) REFERENCES employees(user_id)
+ON DELETE SET NULL;
+ALTER TABLE employees ADD CONSTRAINT emp_mgr_self_chk CHECK(manager_id <> user_id);
+CREATE INDEX emp_mgrid_idx ON employees(manager_id);
+
+--Users with a manager and their managers get a record numbered after their user id
+INSERT INTO employees(user_id, tenant_id, employee_number)
+SELECT user_id, tenant_id, user_id::TEXT FROM users
+WHERE user_id IN (SELECT user_id FROM users WHERE manager_id IS NOT NULL
+    UNION SELECT manager_id FROM users WHERE manager_id IS NOT NULL)
+ON CONFLICT DO NOTHING;
+UPDATE employees e SET manager_id = u.manager_id FROM users u
+WHERE u.user_id = e.user_id AND u.manager_id IS NOT NULL AND u.manager_id <> u.user_id;
+ALTER TABLE users DROP COLUMN manager_id;
@@ -0,0 +1,147 @@
+--Rating scales of performance reviews. A rating is a position in labels, from 1 for the
+--first label up to the number of labels.
+CREATE TABLE rating_scales(
+    scale_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    scale_name VARCHAR(64) NOT NULL,
+    labels TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT rs_tnt_name_key UNIQUE(tenant_id, scale_name)
+);
+
+ALTER TABLE rating_scales ADD CONSTRAINT rs_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+INSERT INTO rating_scales(tenant_id, scale_name, labels)
+SELECT tenant_id, 'Five point', ARRAY['Unsatisfactory', 'Needs improvement', 'Meets expectations',
+    'Exceeds expectations', 'Outstanding'] FROM tenants;
+
+--What a review asks. Every rated section of the template is rated on its scale.
+CREATE TABLE performance_templates(
+    template_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    template_name VARCHAR(64) NOT NULL,
+    scale_id INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT pf_tpl_tnt_name_key UNIQUE(tenant_id, template_name)
+);
+
+--Scales in use by a template cannot be deleted
+ALTER TABLE performance_templates ADD CONSTRAINT pf_tpl_sclid_fk FOREIGN KEY(scale_id) REFERENCES rating_scales(scale_id);
+ALTER TABLE performance_templates ADD CONSTRAINT pf_tpl_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--The sections of a template. kind says who answers it: the employee, their manager or peers.
+--Sections that are not rated only take a comment.
+CREATE TABLE performance_template_sections(
+    section_id BIGSERIAL PRIMARY KEY,
+    template_id INT NOT NULL,
+    position INT NOT NULL,
+    --self, manager or peer
+    kind VARCHAR(7) NOT NULL CHECK(kind IN ('self', 'manager', 'peer')),
+    title VARCHAR(128) NOT NULL,
+    description TEXT NULL,
+    rated BOOLEAN NOT NULL DEFAULT TRUE,
+    UNIQUE(template_id, position)
+);
+
+ALTER TABLE performance_template_sections ADD CONSTRAINT pf_sec_tplid_fk FOREIGN KEY(template_id) REFERENCES performance_templates(template_id)
+ON DELETE CASCADE;
+
+--A review cycle over a period. It covers the employees of department_id and the departments
+--below it, everyone when NULL. Launching it creates the reviews and their reviewers; the
+--answers of each kind are due by the end of the matching due date.
+CREATE TABLE performance_cycles(
+    cycle_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    cycle_name VARCHAR(64) NOT NULL,
+    template_id INT NOT NULL,
+    department_id INT NULL,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    self_due DATE NOT NULL,
+    peer_due DATE NOT NULL,
+    manager_due DATE NOT NULL,
+    --How many of the employees sharing their manager review each employee
+    peer_count INT NOT NULL DEFAULT 0 CHECK(peer_count >= 0 AND peer_count <= 10),
+    --draft, open, calibration or closed
+    status VARCHAR(11) NOT NULL DEFAULT 'draft' CHECK(status IN ('draft', 'open', 'calibration', 'closed')),
+    created_by INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    launched_at TIMESTAMP NULL,
+    closed_at TIMESTAMP NULL,
+    CONSTRAINT pf_cyc_tnt_name_key UNIQUE(tenant_id, cycle_name),
+    CHECK(period_end >= period_start)
+);
+
+--Templates and departments in use by a cycle cannot be deleted
+ALTER TABLE performance_cycles ADD CONSTRAINT pf_cyc_tplid_fk FOREIGN KEY(template_id) REFERENCES performance_templates(template_id);
+ALTER TABLE performance_cycles ADD CONSTRAINT pf_cyc_deptid_fk FOREIGN KEY(department_id) REFERENCES departments(department_id);
+ALTER TABLE performance_cycles ADD CONSTRAINT pf_cyc_crby_fk FOREIGN KEY(created_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE performance_cycles ADD CONSTRAINT pf_cyc_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--The review of one employee in a cycle. manager_id and department_id are as they were at
+--launch; calibration summaries group by that department. final_rating is set in calibration,
+--or from the manager's ratings when the cycle closes.
+CREATE TABLE performance_reviews(
+    review_id BIGSERIAL PRIMARY KEY,
+    cycle_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    user_id INT NOT NULL,
+    manager_id INT NULL,
+    department_id INT NULL,
+    final_rating NUMERIC(4, 2) NULL,
+    calibration_note TEXT NULL,
+    calibrated_by INT NULL,
+    calibrated_at TIMESTAMP NULL,
+    UNIQUE(cycle_id, user_id)
+);
+
+CREATE INDEX pf_rev_uid_idx ON performance_reviews(user_id);
+
+ALTER TABLE performance_reviews ADD CONSTRAINT pf_rev_cycid_fk FOREIGN KEY(cycle_id) REFERENCES performance_cycles(cycle_id)
+ON DELETE CASCADE;
+ALTER TABLE performance_reviews ADD CONSTRAINT pf_rev_uid_fk FOREIGN KEY(user_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE performance_reviews ADD CONSTRAINT pf_rev_mgrid_fk FOREIGN KEY(manager_id) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE performance_reviews ADD CONSTRAINT pf_rev_deptid_fk FOREIGN KEY(department_id) REFERENCES departments(department_id)
+ON DELETE SET NULL;
+ALTER TABLE performance_reviews ADD CONSTRAINT pf_rev_calby_fk FOREIGN KEY(calibrated_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE performance_reviews ADD CONSTRAINT pf_rev_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--Who answers which sections of a review: the employee for self, their manager for manager
+--and each peer for peer.
+CREATE TABLE performance_assignments(
+    assignment_id BIGSERIAL PRIMARY KEY,
+    review_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    reviewer_id INT NOT NULL,
+    kind VARCHAR(7) NOT NULL CHECK(kind IN ('self', 'manager', 'peer')),
+    --pending or submitted
+    status VARCHAR(9) NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'submitted')),
+    submitted_at TIMESTAMP NULL,
+    CONSTRAINT pf_asg_rev_key UNIQUE(review_id, reviewer_id, kind)
+);
+
+CREATE INDEX pf_asg_rvwid_idx ON performance_assignments(reviewer_id);
+
+ALTER TABLE performance_assignments ADD CONSTRAINT pf_asg_revid_fk FOREIGN KEY(review_id) REFERENCES performance_reviews(review_id)
+ON DELETE CASCADE;
+ALTER TABLE performance_assignments ADD CONSTRAINT pf_asg_rvwid_fk FOREIGN KEY(reviewer_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE performance_assignments ADD CONSTRAINT pf_asg_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--The answer of a reviewer to one section. rating is NULL for sections that are not rated.
+CREATE TABLE performance_answers(
+    assignment_id INT NOT NULL,
+    section_id INT NOT NULL,
+    rating INT NULL CHECK(rating >= 1),
+    comment TEXT NULL,
+    PRIMARY KEY(assignment_id, section_id)
+);
+
+ALTER TABLE performance_answers ADD CONSTRAINT pf_ans_asgid_fk FOREIGN KEY(assignment_id) REFERENCES performance_assignments(assignment_id)
+ON DELETE CASCADE;
+ALTER TABLE performance_answers ADD CONSTRAINT pf_ans_secid_fk FOREIGN KEY(section_id) REFERENCES performance_template_sections(section_id)
+ON DELETE CASCADE;
@@ -0,0 +1,27 @@
+--Attribute based policies evaluated after the role check. Every edit adds a new row to
+--policy_versions; policies.current_version points at the one in force.
+CREATE TABLE policies(
+    policy_id BIGSERIAL PRIMARY KEY,
+    policy_name VARCHAR(64) UNIQUE NOT NULL,
+    description VARCHAR(255),
+    current_version INT NOT NULL DEFAULT 1,
+    active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE policy_versions(
+    policy_id INT NOT NULL,
+    version INT NOT NULL,
+    --permit: at least one permit policy for the action must match. deny: a match always denies
+    effect VARCHAR(6) NOT NULL CHECK(effect IN ('permit', 'deny')),
+    --Privilege names the policy applies to, '*' for all
+    actions TEXT[] NOT NULL,
+    condition TEXT NOT NULL,
+    created_by INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY(policy_id, version)
+);
+
+ALTER TABLE policy_versions ADD CONSTRAINT pol_ver_pid_fk FOREIGN KEY(policy_id) REFERENCES policies(policy_id)
+ON DELETE CASCADE;
+ALTER TABLE policy_versions ADD CONSTRAINT pol_ver_usrid_fk FOREIGN KEY(created_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+--Privileges are declared in code and synced into this table at startup.
+--Declared names are longer than the original 12 characters.
+ALTER TABLE privileges ALTER COLUMN privilege_name TYPE VARCHAR(64);
+ALTER TABLE privileges ALTER COLUMN description TYPE VARCHAR(255);
+--Set by the sync for privileges no package declares any more
+ALTER TABLE privileges ADD COLUMN orphaned BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,16 @@
+--Privileges renamed in code. Run after privilege_catalog.sql, which widens privilege_name.
+--Renaming in place keeps the roles holding the old name; the sync would orphan it instead.
+
+--modify_user became modify_any_user next to modify_own_user
+UPDATE privileges SET privilege_name = 'modify_any_user', orphaned = FALSE
+WHERE privilege_name = 'modify_user'
+AND NOT EXISTS (SELECT 1 FROM privileges WHERE privilege_name = 'modify_any_user');
+
+--If the sync already added modify_any_user, the roles holding modify_user move over to it
+UPDATE role_privileges rp SET privilege_id = n.privilege_id
+FROM privileges o, privileges n
+WHERE o.privilege_name = 'modify_user' AND n.privilege_name = 'modify_any_user'
+AND rp.privilege_id = o.privilege_id
+AND NOT EXISTS (SELECT 1 FROM role_privileges x WHERE x.role_id = rp.role_id AND x.privilege_id = n.privilege_id);
+DELETE FROM role_privileges WHERE privilege_id IN (SELECT privilege_id FROM privileges WHERE privilege_name = 'modify_user');
+DELETE FROM privileges WHERE privilege_name = 'modify_user';
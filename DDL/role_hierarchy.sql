@@ -0,0 +1,13 @@
+--A parent role inherits every privilege granted to its children, directly or through
+--further descendants. Cycles are rejected by the API before an edge is inserted.
+CREATE TABLE role_hierarchy(
+    parent_role_id INT NOT NULL,
+    child_role_id INT NOT NULL,
+    PRIMARY KEY(parent_role_id, child_role_id),
+    CHECK(parent_role_id <> child_role_id)
+);
+
+ALTER TABLE role_hierarchy ADD CONSTRAINT rl_hier_prid_fk FOREIGN KEY(parent_role_id) REFERENCES roles(role_id)
+ON DELETE CASCADE;
+ALTER TABLE role_hierarchy ADD CONSTRAINT rl_hier_crid_fk FOREIGN KEY(child_role_id) REFERENCES roles(role_id)
+ON DELETE CASCADE;
@@ -0,0 +1,21 @@
+--Separation of duties. A user may hold at most max_roles of the roles of a constraint.
+--static constraints are checked on every grant, dynamic ones on every request against
+--the grants in force at that moment.
+CREATE TABLE sod_constraints(
+    constraint_id BIGSERIAL PRIMARY KEY,
+    constraint_name VARCHAR(64) UNIQUE NOT NULL,
+    description VARCHAR(255),
+    kind VARCHAR(7) NOT NULL CHECK(kind IN ('static', 'dynamic')),
+    max_roles INT NOT NULL DEFAULT 1 CHECK(max_roles > 0)
+);
+
+CREATE TABLE sod_constraint_roles(
+    constraint_id INT NOT NULL,
+    role_id INT NOT NULL,
+    PRIMARY KEY(constraint_id, role_id)
+);
+
+ALTER TABLE sod_constraint_roles ADD CONSTRAINT sod_con_cid_fk FOREIGN KEY(constraint_id) REFERENCES sod_constraints(constraint_id)
+ON DELETE CASCADE;
+ALTER TABLE sod_constraint_roles ADD CONSTRAINT sod_con_rid_fk FOREIGN KEY(role_id) REFERENCES roles(role_id)
+ON DELETE CASCADE;
@@ -0,0 +1,82 @@
+--Organizations. Every row belongs to one tenant; everything that existed before
+--tenants goes to the default tenant 1.
+CREATE TABLE tenants(
+    tenant_id BIGSERIAL PRIMARY KEY,
+    tenant_name VARCHAR(64) UNIQUE NOT NULL,
+    description VARCHAR(255),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO tenants(tenant_id, tenant_name, description) VALUES (1, 'default', 'Organization of the data created before tenants');
+SELECT setval('tenants_tenant_id_seq', 1);
+
+ALTER TABLE users ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE groups ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE roles ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE role_privileges ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE group_roles ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE role_hierarchy ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE policies ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE policy_versions ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE grant_events ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE sod_constraints ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE sod_constraint_roles ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE impersonation_audit ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE access_requests ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE access_request_events ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE review_campaigns ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE review_items ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE role_eligibilities ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE role_activations ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+
+--Privileges declared in code have no tenant and are shared by all of them.
+--Privileges added through the API belong to the tenant that added them.
+ALTER TABLE privileges ADD COLUMN tenant_id INT NULL;
+UPDATE privileges SET tenant_id = 1 WHERE orphaned;
+
+--New rows must name their tenant
+ALTER TABLE users ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE groups ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE roles ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE role_privileges ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE group_roles ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE role_hierarchy ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE policies ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE policy_versions ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE grant_events ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE sod_constraints ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE sod_constraint_roles ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE impersonation_audit ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE access_requests ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE access_request_events ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE review_campaigns ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE review_items ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE role_eligibilities ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE role_activations ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE users ADD CONSTRAINT usr_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+ALTER TABLE groups ADD CONSTRAINT grp_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+ALTER TABLE roles ADD CONSTRAINT rl_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+ALTER TABLE privileges ADD CONSTRAINT priv_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+ALTER TABLE policies ADD CONSTRAINT pol_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+ALTER TABLE sod_constraints ADD CONSTRAINT sod_con_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+ALTER TABLE access_requests ADD CONSTRAINT acc_req_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+ALTER TABLE review_campaigns ADD CONSTRAINT rev_cmp_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+ALTER TABLE role_eligibilities ADD CONSTRAINT rl_elg_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--Names are unique within a tenant. Usernames stay global, they identify the tenant at login
+ALTER TABLE roles DROP CONSTRAINT roles_role_name_key;
+ALTER TABLE roles ADD CONSTRAINT rl_tnt_name_key UNIQUE(tenant_id, role_name);
+ALTER TABLE groups DROP CONSTRAINT groups_group_name_key;
+ALTER TABLE groups ADD CONSTRAINT grp_tnt_name_key UNIQUE(tenant_id, group_name);
+ALTER TABLE policies DROP CONSTRAINT policies_policy_name_key;
+ALTER TABLE policies ADD CONSTRAINT pol_tnt_name_key UNIQUE(tenant_id, policy_name);
+ALTER TABLE sod_constraints DROP CONSTRAINT sod_constraints_constraint_name_key;
+ALTER TABLE sod_constraints ADD CONSTRAINT sod_con_tnt_name_key UNIQUE(tenant_id, constraint_name);
+ALTER TABLE review_campaigns DROP CONSTRAINT review_campaigns_campaign_name_key;
+ALTER TABLE review_campaigns ADD CONSTRAINT rev_cmp_tnt_name_key UNIQUE(tenant_id, campaign_name);
+--Privilege names are unique within a tenant and among the declared privileges, which have no tenant
+ALTER TABLE privileges DROP CONSTRAINT privileges_privilege_name_key;
+ALTER TABLE privileges ADD CONSTRAINT priv_tnt_name_key UNIQUE(tenant_id, privilege_name);
+CREATE UNIQUE INDEX priv_declared_name_key ON privileges(privilege_name) WHERE tenant_id IS NULL;
@@ -0,0 +1,74 @@
+--Clock-in/clock-out records. An employee has at most one open record, the one without clock_out.
+CREATE TABLE attendance(
+    attendance_id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    clock_in TIMESTAMP NOT NULL DEFAULT NOW(),
+    clock_out TIMESTAMP NULL,
+    note TEXT NULL,
+    CHECK(clock_out IS NULL OR clock_out >= clock_in)
+);
+
+CREATE UNIQUE INDEX att_open_idx ON attendance(user_id) WHERE clock_out IS NULL;
+CREATE INDEX att_uid_idx ON attendance(user_id, clock_in);
+
+ALTER TABLE attendance ADD CONSTRAINT att_uid_fk FOREIGN KEY(user_id) REFERENCES employees(user_id)
+ON DELETE CASCADE;
+ALTER TABLE attendance ADD CONSTRAINT att_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--Project and task codes time is booked on. An empty task_code books on the project itself.
+CREATE TABLE time_codes(
+    time_code_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    project_code VARCHAR(32) NOT NULL,
+    task_code VARCHAR(32) NOT NULL DEFAULT '',
+    description VARCHAR(128) NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT tc_tnt_code_key UNIQUE(tenant_id, project_code, task_code)
+);
+
+ALTER TABLE time_codes ADD CONSTRAINT tc_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--One timesheet per employee and week, the week starting on Monday. Approved timesheets are
+--locked until they are reopened. expected_hours come from the working calendar less approved
+--leave, overtime_hours is what was worked beyond them.
+CREATE TABLE timesheets(
+    timesheet_id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    week_start DATE NOT NULL CHECK(EXTRACT(ISODOW FROM week_start) = 1),
+    status VARCHAR(9) NOT NULL DEFAULT 'draft'
+        CHECK(status IN ('draft', 'submitted', 'approved', 'rejected')),
+    worked_hours NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    expected_hours NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    overtime_hours NUMERIC(5, 2) NOT NULL DEFAULT 0,
+    submitted_at TIMESTAMP NULL,
+    decided_by INT NULL,
+    decision_note TEXT NULL,
+    decided_at TIMESTAMP NULL,
+    CONSTRAINT ts_uid_week_key UNIQUE(user_id, week_start)
+);
+
+ALTER TABLE timesheets ADD CONSTRAINT ts_uid_fk FOREIGN KEY(user_id) REFERENCES employees(user_id)
+ON DELETE CASCADE;
+ALTER TABLE timesheets ADD CONSTRAINT ts_decid_fk FOREIGN KEY(decided_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE timesheets ADD CONSTRAINT ts_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+CREATE TABLE timesheet_entries(
+    entry_id BIGSERIAL PRIMARY KEY,
+    timesheet_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    work_date DATE NOT NULL,
+    time_code_id INT NOT NULL,
+    hours NUMERIC(4, 2) NOT NULL CHECK(hours > 0 AND hours <= 24),
+    note TEXT NULL
+);
+
+CREATE INDEX ts_ent_tsid_idx ON timesheet_entries(timesheet_id);
+
+ALTER TABLE timesheet_entries ADD CONSTRAINT ts_ent_tsid_fk FOREIGN KEY(timesheet_id) REFERENCES timesheets(timesheet_id)
+ON DELETE CASCADE;
+ALTER TABLE timesheet_entries ADD CONSTRAINT ts_ent_tcid_fk FOREIGN KEY(time_code_id) REFERENCES time_codes(time_code_id);
+ALTER TABLE timesheet_entries ADD CONSTRAINT ts_ent_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
@@ -0,0 +1,109 @@
+--Onboarding and offboarding templates. A template applies to new hires, or terminated
+--employees, with its job_title and department; a NULL key matches any. The most specific
+--template wins. Groups and the role are only given by onboarding templates.
+CREATE TABLE workflow_templates(
+    template_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    template_name VARCHAR(64) NOT NULL,
+    --onboarding or offboarding
+    kind VARCHAR(11) NOT NULL CHECK(kind IN ('onboarding', 'offboarding')),
+    job_title VARCHAR(64) NULL,
+    department_id INT NULL,
+    role_id INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT wf_tpl_tnt_name_key UNIQUE(tenant_id, template_name),
+    CHECK(kind = 'onboarding' OR role_id IS NULL)
+);
+
+CREATE UNIQUE INDEX wf_tpl_match_idx ON workflow_templates(tenant_id, kind, COALESCE(job_title, ''), COALESCE(department_id, 0));
+
+ALTER TABLE workflow_templates ADD CONSTRAINT wf_tpl_deptid_fk FOREIGN KEY(department_id) REFERENCES departments(department_id)
+ON DELETE CASCADE;
+ALTER TABLE workflow_templates ADD CONSTRAINT wf_tpl_rid_fk FOREIGN KEY(role_id) REFERENCES roles(role_id)
+ON DELETE SET NULL;
+ALTER TABLE workflow_templates ADD CONSTRAINT wf_tpl_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+CREATE TABLE workflow_template_groups(
+    template_id INT NOT NULL,
+    group_id INT NOT NULL,
+    PRIMARY KEY(template_id, group_id)
+);
+
+ALTER TABLE workflow_template_groups ADD CONSTRAINT wf_tpl_grp_tplid_fk FOREIGN KEY(template_id) REFERENCES workflow_templates(template_id)
+ON DELETE CASCADE;
+ALTER TABLE workflow_template_groups ADD CONSTRAINT wf_tpl_grp_gid_fk FOREIGN KEY(group_id) REFERENCES groups(group_id)
+ON DELETE CASCADE;
+
+--The checklist of a template. due_days counts from the start date, or the termination date
+--for offboarding, and can be negative.
+CREATE TABLE workflow_template_tasks(
+    template_task_id BIGSERIAL PRIMARY KEY,
+    template_id INT NOT NULL,
+    position INT NOT NULL,
+    title VARCHAR(128) NOT NULL,
+    description TEXT NULL,
+    --hr, it or manager
+    assignee VARCHAR(7) NOT NULL CHECK(assignee IN ('hr', 'it', 'manager')),
+    due_days INT NOT NULL DEFAULT 0,
+    UNIQUE(template_id, position)
+);
+
+ALTER TABLE workflow_template_tasks ADD CONSTRAINT wf_tpl_tsk_tplid_fk FOREIGN KEY(template_id) REFERENCES workflow_templates(template_id)
+ON DELETE CASCADE;
+
+--A workflow run for one user. It is completed once none of its tasks is open.
+CREATE TABLE workflows(
+    workflow_id BIGSERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    user_id INT NOT NULL,
+    --NULL once the template is deleted, the workflow keeps its copy of the tasks
+    template_id INT NULL,
+    kind VARCHAR(11) NOT NULL CHECK(kind IN ('onboarding', 'offboarding')),
+    --open, completed or cancelled
+    status VARCHAR(9) NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'completed', 'cancelled')),
+    start_date DATE NOT NULL,
+    created_by INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMP NULL
+);
+
+CREATE INDEX wf_uid_idx ON workflows(user_id);
+
+ALTER TABLE workflows ADD CONSTRAINT wf_uid_fk FOREIGN KEY(user_id) REFERENCES users(user_id)
+ON DELETE CASCADE;
+ALTER TABLE workflows ADD CONSTRAINT wf_tplid_fk FOREIGN KEY(template_id) REFERENCES workflow_templates(template_id)
+ON DELETE SET NULL;
+ALTER TABLE workflows ADD CONSTRAINT wf_crby_fk FOREIGN KEY(created_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE workflows ADD CONSTRAINT wf_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
+
+--Tasks of a workflow, copied from its template. Manager tasks go to assignee_id, the
+--manager of the user when the workflow started; HR and IT tasks to anyone working that queue.
+--Manager tasks without a manager are worked by HR.
+CREATE TABLE workflow_tasks(
+    task_id BIGSERIAL PRIMARY KEY,
+    workflow_id INT NOT NULL,
+    tenant_id INT NOT NULL,
+    position INT NOT NULL,
+    title VARCHAR(128) NOT NULL,
+    description TEXT NULL,
+    assignee VARCHAR(7) NOT NULL CHECK(assignee IN ('hr', 'it', 'manager')),
+    assignee_id INT NULL,
+    due_date DATE NOT NULL,
+    --open, done or skipped
+    status VARCHAR(7) NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'done', 'skipped')),
+    note TEXT NULL,
+    completed_by INT NULL,
+    completed_at TIMESTAMP NULL
+);
+
+CREATE INDEX wf_tsk_wfid_idx ON workflow_tasks(workflow_id);
+CREATE INDEX wf_tsk_open_idx ON workflow_tasks(tenant_id, assignee, assignee_id) WHERE status = 'open';
+
+ALTER TABLE workflow_tasks ADD CONSTRAINT wf_tsk_wfid_fk FOREIGN KEY(workflow_id) REFERENCES workflows(workflow_id)
+ON DELETE CASCADE;
+ALTER TABLE workflow_tasks ADD CONSTRAINT wf_tsk_asgid_fk FOREIGN KEY(assignee_id) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE workflow_tasks ADD CONSTRAINT wf_tsk_cmpby_fk FOREIGN KEY(completed_by) REFERENCES users(user_id)
+ON DELETE SET NULL;
+ALTER TABLE workflow_tasks ADD CONSTRAINT wf_tsk_tntid_fk FOREIGN KEY(tenant_id) REFERENCES tenants(tenant_id);
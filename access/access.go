@@ -0,0 +1,587 @@
+package access
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"hrm/user"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//Requests user $1 may decide, through the approvers configured on the requested role.
+//Nobody decides their own request. Needs the groups of the requesters from requesterGroups.
+const decidableBy = ` FROM access_requests a
+	JOIN roles r ON r.role_id = a.role_id
+	JOIN users u ON u.user_id = a.user_id
+	WHERE a.user_id <> $1 AND (('owner' = ANY(r.approvers) AND r.owner_id = $1)
+	OR ('group' = ANY(r.approvers) AND EXISTS(SELECT 1 FROM user_groups ug JOIN groups g ON g.group_id = ug.group_id
+		WHERE ug.user_id = a.user_id AND g.owner_id = $1))
+	OR ('manager' = ANY(r.approvers) AND EXISTS(SELECT 1 FROM employees e WHERE e.user_id = a.user_id AND e.manager_id = $1)))`
+
+//The groups of the users of the access requests matching cond, including those they are in
+//through a group rule or a nested group
+func requesterGroups(cond string) string {
+	return `WITH RECURSIVE ` + middleware.UserGroupsCTE(`user_id IN (SELECT user_id FROM access_requests WHERE `+cond+`)`) + ` `
+}
+
+const selectRequest = `SELECT a.request_id, a.user_id, u.username, a.role_id, r.role_name, a.justification,
+		a.status, a.valid_from, a.valid_until, a.decided_by, COALESCE(a.decision_note, ''), a.created_at, a.decided_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRequest(row scanner, request *AccessRequestModel) error {
+	var decidedBy sql.NullInt64
+	err := row.Scan(&request.RequestId, &request.UserId, &request.Username, &request.RoleId, &request.RoleName,
+		&request.Justification, &request.Status, &request.ValidFrom, &request.ValidUntil, &decidedBy,
+		&request.DecisionNote, &request.CreatedAt, &request.DecidedAt)
+	if decidedBy.Valid {
+		id := uint64(decidedBy.Int64)
+		request.DecidedBy = &id
+	}
+	return err
+}
+
+func queryRequests(db *sql.DB, stmt string, args ...interface{}) ([]AccessRequestModel, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	requests := []AccessRequestModel{}
+	for rows.Next() {
+		request := AccessRequestModel{}
+		if err := scanRequest(rows, &request); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+//Returns the state transitions of a request, oldest first
+func requestEvents(db *sql.DB, requestId uint64) ([]EventModel, error) {
+	stmt := `SELECT event_id, COALESCE(from_status, ''), to_status, actor_id, COALESCE(note, ''), created_at
+		FROM access_request_events WHERE request_id = $1 ORDER BY event_id`
+	rows, err := db.Query(stmt, requestId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []EventModel{}
+	for rows.Next() {
+		event := EventModel{}
+		var actorId sql.NullInt64
+		err := rows.Scan(&event.EventId, &event.FromStatus, &event.ToStatus, &actorId, &event.Note, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if actorId.Valid {
+			id := uint64(actorId.Int64)
+			event.ActorId = &id
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+//Persists a state transition. from is "" when the request is created
+func recordEvent(tx *sql.Tx, requestId uint64, from, to string, actorId uint64, note string) error {
+	stmt := `INSERT INTO access_request_events(request_id, from_status, to_status, actor_id, note, tenant_id)
+		SELECT $1, NULLIF($2, ''), $3, $4, NULLIF($5, ''), tenant_id FROM access_requests WHERE request_id = $1`
+	_, err := tx.Exec(stmt, requestId, from, to, actorId, note)
+	return err
+}
+
+//For requesting a role for the caller. Takes role_name, justification and an optional window
+func AddAccessRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	request := AccessRequestModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if request.Justification == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "A justification is required",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if !request.IsValid() {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "valid_until must be after valid_from",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	//Users request roles of their own tenant
+	stmt := `SELECT role_id FROM roles WHERE role_name = $1 AND tenant_id = $2`
+	err := db.QueryRow(stmt, request.RoleName, principal.HomeTenantId).Scan(&request.RoleId)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Role not found!",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	stmt = `INSERT INTO access_requests(user_id, role_id, justification, valid_from, valid_until, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING request_id`
+	err = tx.QueryRow(stmt, principal.UserId, request.RoleId, request.Justification,
+		request.ValidFrom, request.ValidUntil, principal.HomeTenantId).Scan(&request.RequestId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		//Only one pending request per user and role
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "A request for this role is already pending",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	err = recordEvent(tx, request.RequestId, "", "pending", principal.UserId, request.Justification)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Access request " + strconv.FormatUint(request.RequestId, 10) + " submitted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all access requests, newest first
+func GetAccessRequests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	status := r.URL.Query().Get("status")
+	var userId int64
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Unable to convert user_id to int",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		userId = id
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectRequest + ` FROM access_requests a
+		JOIN users u ON u.user_id = a.user_id JOIN roles r ON r.role_id = a.role_id
+		WHERE ($1 = '' OR a.status = $1) AND ($2 = 0 OR a.user_id = $2) AND a.tenant_id = $3
+		ORDER BY a.request_id DESC`
+	data, err := queryRequests(db, stmt, status, userId, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of request objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the caller's own requests, each with its history
+func GetMyAccessRequests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectRequest + ` FROM access_requests a
+		JOIN users u ON u.user_id = a.user_id JOIN roles r ON r.role_id = a.role_id
+		WHERE a.user_id = $1 ORDER BY a.request_id DESC`
+	data, err := queryRequests(db, stmt, principal.UserId)
+	for i := 0; err == nil && i < len(data); i++ {
+		data[i].Events, err = requestEvents(db, data[i].RequestId)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of request objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the pending requests the caller is an approver for, oldest first
+func GetAccessRequestInbox(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := requesterGroups(`status = 'pending' AND tenant_id = $2`) + selectRequest + decidableBy +
+		` AND a.status = 'pending' AND a.tenant_id = $2 ORDER BY a.request_id`
+	data, err := queryRequests(db, stmt, principal.UserId, principal.TenantId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of request objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching a single request with its history
+func GetAccessRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	request := AccessRequestModel{}
+	//Extract request id from req params
+	params := mux.Vars(r)
+	requestId, err := strconv.Atoi(params["request_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectRequest + ` FROM access_requests a
+		JOIN users u ON u.user_id = a.user_id JOIN roles r ON r.role_id = a.role_id
+		WHERE a.request_id = $1 AND a.tenant_id = $2`
+	err = scanRequest(db.QueryRow(stmt, uint64(requestId), middleware.TenantId(r)), &request)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Access request not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		request.Events, err = requestEvents(db, request.RequestId)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return request object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(request)
+}
+
+//For approving a pending request. The grant goes through user.GrantRoleTx, so it is
+//subject to separation of duties, and the request stays pending if the grant is refused.
+//As with assigning a role directly, the user's groups must have the role, and a role the
+//user already holds is only replaced when the body sets replace_role
+func ApproveAccessRequest(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "approved")
+}
+
+//For rejecting a pending request
+func RejectAccessRequest(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "rejected")
+}
+
+//For withdrawing the caller's own pending request
+func CancelAccessRequest(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "cancelled")
+}
+
+//Moves a pending request to status. Cancelling is for the requester, approving and
+//rejecting for the approvers configured on the role
+func decide(w http.ResponseWriter, r *http.Request, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract request id from req params
+	params := mux.Vars(r)
+	requestId, err := strconv.Atoi(params["request_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	decision := DecisionModel{}
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//An impersonating admin must not decide in someone else's name
+	if principal.Impersonated() && status != "cancelled" {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Access requests cannot be decided under impersonation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Lock the request so two approvers cannot decide it at the same time
+	request := AccessRequestModel{RequestId: uint64(requestId)}
+	stmt := `SELECT user_id, role_id, status, valid_from, valid_until FROM access_requests
+		WHERE request_id = $1 AND tenant_id = $2 FOR UPDATE`
+	err = tx.QueryRow(stmt, request.RequestId, principal.TenantId).Scan(&request.UserId, &request.RoleId, &request.Status,
+		&request.ValidFrom, &request.ValidUntil)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Access request not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	allowed := false
+	if err == nil {
+		if status == "cancelled" {
+			allowed = request.UserId == principal.UserId
+		} else {
+			stmt = requesterGroups(`request_id = $2`) + `SELECT EXISTS(SELECT 1` + decidableBy + ` AND a.request_id = $2)`
+			err = tx.QueryRow(stmt, principal.UserId, request.RequestId).Scan(&allowed)
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if !allowed {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You are not allowed to decide this request",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if request.Status != "pending" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Access request is already " + request.Status,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if status == "approved" {
+		//Same prerequisite as assigning the role directly
+		found, err := user.GroupsHaveRole(tx, request.UserId, request.RoleId)
+		var current string
+		if err == nil && found {
+			stmt = `SELECT COALESCE(r.role_name, '') FROM users u LEFT JOIN roles r ON r.role_id = u.role_id
+				AND u.role_id <> $2 AND (u.role_valid_until IS NULL OR u.role_valid_until > NOW())
+				WHERE u.user_id = $1 FOR UPDATE OF u`
+			err = tx.QueryRow(stmt, request.UserId, request.RoleId).Scan(&current)
+		}
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "User not found",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Internal server error" + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if !found {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "User is not part of a group or user's group does not have this role",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if current != "" && !decision.ReplaceRole {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "User holds role " + current + ", approve with replace_role to replace it",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		msg, err := user.GrantRoleTx(tx, principal.UserId, request.UserId, request.RoleId, request.Validity)
+		if err, ok := err.(middleware.UngrantableError); ok {
+			w.WriteHeader(http.StatusForbidden)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if err == user.ErrUserNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "User not found",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Internal server error" + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if msg != "" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: msg,
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	stmt = `UPDATE access_requests SET status = $2, decided_by = $3, decision_note = NULLIF($4, ''),
+		decided_at = NOW() WHERE request_id = $1`
+	_, err = tx.Exec(stmt, request.RequestId, status, principal.UserId, decision.Note)
+	if err == nil {
+		err = recordEvent(tx, request.RequestId, request.Status, status, principal.UserId, decision.Note)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Access request " + status,
+	}
+	json.NewEncoder(w).Encode(res)
+}
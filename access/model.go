@@ -0,0 +1,41 @@
+package access
+
+import (
+	"hrm/role"
+	"time"
+)
+
+//A request by a user for a role. Approval grants the role for the requested window
+type AccessRequestModel struct {
+	RequestId     uint64 `json:"id"`
+	UserId        uint64 `json:"user_id"`
+	Username      string `json:"username"`
+	RoleId        uint64 `json:"role_id"`
+	RoleName      string `json:"role_name"`
+	Justification string `json:"justification"`
+	//pending, approved, rejected or cancelled
+	Status       string     `json:"status"`
+	DecidedBy    *uint64    `json:"decided_by,omitempty"`
+	DecisionNote string     `json:"decision_note,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	role.Validity
+	Events []EventModel `json:"events,omitempty"`
+}
+
+//A state transition of a request. FromStatus is empty for the creation of the request
+type EventModel struct {
+	EventId    uint64    `json:"id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorId    *uint64   `json:"actor_id,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+//Optional body of approve, reject and cancel. Users have a single role: approving a request
+//of a user who holds another one replaces it, which replace_role has to confirm
+type DecisionModel struct {
+	Note        string `json:"note"`
+	ReplaceRole bool   `json:"replace_role"`
+}
@@ -0,0 +1,10 @@
+package access
+
+import "hrm/catalog"
+
+//Privileges required by the access request routes
+var (
+	PrivRequestRole        = catalog.Declare("request_role", "Request a role and follow the caller's own requests")
+	PrivReadAccessRequests = catalog.Declare("read_access_requests", "List all access requests and their history")
+	PrivDecideAccess       = catalog.Declare("decide_access_request", "Approve or reject access requests the caller is an approver for")
+)
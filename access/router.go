@@ -0,0 +1,41 @@
+package access
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleAccessRoutes(r *mux.Router) {
+	//Endpoint for requesting a role for yourself
+	r.HandleFunc("/access-requests",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRequestRole, AddAccessRequest))).Methods("POST")
+
+	//Endpoint for fetching all access requests. ?status= and ?user_id= narrow it down
+	r.HandleFunc("/access-requests",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadAccessRequests, GetAccessRequests))).Methods("GET")
+
+	//Endpoint for fetching the caller's own requests with their history
+	r.HandleFunc("/access-requests/mine",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRequestRole, GetMyAccessRequests))).Methods("GET")
+
+	//Endpoint for fetching the pending requests the caller can decide
+	r.HandleFunc("/access-requests/inbox",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDecideAccess, GetAccessRequestInbox))).Methods("GET")
+
+	//Endpoint for fetching a single request with its history
+	r.HandleFunc("/access-requests/{request_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadAccessRequests, GetAccessRequest))).Methods("GET")
+
+	//Endpoint for approving a request. Grants the role
+	r.HandleFunc("/access-requests/{request_id}/approve",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDecideAccess, ApproveAccessRequest))).Methods("POST")
+
+	//Endpoint for rejecting a request
+	r.HandleFunc("/access-requests/{request_id}/reject",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDecideAccess, RejectAccessRequest))).Methods("POST")
+
+	//Endpoint for withdrawing your own pending request
+	r.HandleFunc("/access-requests/{request_id}/cancel",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRequestRole, CancelAccessRequest))).Methods("POST")
+}
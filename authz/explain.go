@@ -0,0 +1,126 @@
+package authz
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+//For explaining whether a user holds a privilege and why. Policies are evaluated with
+//the environment (time, ip) of this request, as the user's own requests are not known.
+func Explain(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	req := ExplainRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if req.Privilege == "" || (req.UserId == 0 && req.Username == "") {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "privilege and either user_id or username are required",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	principal := middleware.Principal{}
+	var roleId sql.NullInt64
+	stmt := `SELECT user_id, username, role_id, tenant_id FROM users
+		WHERE (user_id = $1 OR ($1 = 0 AND username = $2)) AND tenant_id = $3`
+	err := db.QueryRow(stmt, req.UserId, req.Username, middleware.TenantId(r)).Scan(&principal.UserId,
+		&principal.Username, &roleId, &principal.HomeTenantId)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "User not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal.RoleId = uint64(roleId.Int64)
+	principal.TenantId = principal.HomeTenantId
+	explanation := ExplainModel{
+		UserId:    principal.UserId,
+		Username:  principal.Username,
+		Privilege: req.Privilege,
+		Decision:  "deny",
+		Paths:     [][]string{},
+	}
+	paths, err := middleware.ExplainPrivilege(db, principal.UserId, req.Privilege)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var dropped string
+	if len(paths) == 0 {
+		dropped, err = middleware.SoDDropReason(db, principal.UserId, req.Privilege)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if dropped != "" {
+		explanation.DeniedBy = dropped
+	} else if len(paths) == 0 {
+		explanation.DeniedBy = "no role grants " + req.Privilege
+	} else {
+		explanation.Paths = paths
+		//Evaluate the policies as if the user called a route acting on the resource user
+		target := r
+		if req.ResourceUserId != 0 {
+			target = mux.SetURLVars(r, map[string]string{"user_id": strconv.FormatUint(req.ResourceUserId, 10)})
+		}
+		allowed, reason, err := middleware.EvaluatePolicies(db, target, principal, req.Privilege)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if allowed {
+			explanation.Decision = "allow"
+		} else {
+			explanation.DeniedBy = reason
+		}
+	}
+	//If everything went well, return the explanation
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(explanation)
+}
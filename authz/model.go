@@ -0,0 +1,22 @@
+package authz
+
+//Body of an explain request. Either user_id or username identifies the user.
+type ExplainRequest struct {
+	UserId    uint64 `json:"user_id"`
+	Username  string `json:"username"`
+	Privilege string `json:"privilege"`
+	//Optional target user, for policies that look at resource attributes
+	ResourceUserId uint64 `json:"resource_user_id"`
+}
+
+type ExplainModel struct {
+	UserId    uint64 `json:"user_id"`
+	Username  string `json:"username"`
+	Privilege string `json:"privilege"`
+	//allow or deny
+	Decision string `json:"decision"`
+	//Every user → group → role → privilege path granting the privilege
+	Paths [][]string `json:"paths"`
+	//Why the privilege was denied, empty when allowed
+	DeniedBy string `json:"denied_by,omitempty"`
+}
@@ -0,0 +1,13 @@
+package authz
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleAuthzRoutes(r *mux.Router) {
+	//Endpoint for explaining an authorization decision
+	r.HandleFunc("/authz/explain",
+		middleware.JwtVerify(middleware.IsAuthorize(middleware.PrivExplainAuthz, Explain))).Methods("POST")
+}
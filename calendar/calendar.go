@@ -0,0 +1,322 @@
+package calendar
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const selectCalendar = `SELECT calendar_id, calendar_name, location, is_default, work_weekdays, hours_per_day, created_at
+	FROM calendars`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCalendar(row scanner, c *CalendarModel) error {
+	return row.Scan(&c.CalendarId, &c.CalendarName, &c.Location, &c.IsDefault, pq.Array(&c.WorkWeekdays),
+		&c.HoursPerDay, &c.CreatedAt)
+}
+
+//Returns what is wrong with a calendar, or an empty string
+func validateCalendar(c CalendarModel) string {
+	if c.CalendarName == "" {
+		return "calendar_name is required"
+	}
+	if len(c.WorkWeekdays) == 0 {
+		return "work_weekdays needs at least one weekday"
+	}
+	seen := map[int64]bool{}
+	for _, weekday := range c.WorkWeekdays {
+		if weekday < 1 || weekday > 7 || seen[weekday] {
+			return "work_weekdays must be distinct ISO weekdays from 1 (Monday) to 7 (Sunday)"
+		}
+		seen[weekday] = true
+	}
+	if c.HoursPerDay <= 0 || c.HoursPerDay > 24 {
+		return "hours_per_day must be more than 0 and at most 24"
+	}
+	if c.Location != nil && *c.Location == "" {
+		return "location cannot be empty, leave it out for no location"
+	}
+	return ""
+}
+
+//Names, location and the default flag are each unique within a tenant
+func conflictMessage(err *pq.Error) string {
+	switch err.Constraint {
+	case "cal_tnt_loc_key":
+		return "Location already has a calendar"
+	case "cal_tnt_default_idx":
+		return "Another calendar is the default"
+	}
+	return "Calendar name already in use"
+}
+
+//Writes the error response of a failed insert or update of a calendar
+func writeCalendarError(w http.ResponseWriter, err error) {
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: conflictMessage(err),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+	res := middleware.Response{
+		Error:   true,
+		Message: err.Error(),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For creating a calendar. Takes calendar_name and the optional location, is_default,
+//work_weekdays (Monday to Friday by default) and hours_per_day (8 by default)
+func AddCalendar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	calendar := CalendarModel{WorkWeekdays: []int64{1, 2, 3, 4, 5}, HoursPerDay: 8}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&calendar); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateCalendar(calendar); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `INSERT INTO calendars(tenant_id, calendar_name, location, is_default, work_weekdays, hours_per_day)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING calendar_id`
+	err := db.QueryRow(stmt, middleware.TenantId(r), calendar.CalendarName, calendar.Location, calendar.IsDefault,
+		pq.Array(calendar.WorkWeekdays), calendar.HoursPerDay).Scan(&calendar.CalendarId)
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Calendar created with id " + strconv.FormatUint(calendar.CalendarId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all calendars
+func GetCalendars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	rows, err := db.Query(selectCalendar+` WHERE tenant_id = $1 ORDER BY calendar_name`, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []CalendarModel{}
+	for rows.Next() {
+		calendar := CalendarModel{}
+		if err := scanCalendar(rows, &calendar); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, calendar)
+	}
+	//If everything went well, return array of calendar objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching a single calendar
+func GetCalendar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get calendar id from req params
+	params := mux.Vars(r)
+	calendarId, err := strconv.Atoi(params["calendar_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	calendar := CalendarModel{}
+	row := db.QueryRow(selectCalendar+` WHERE calendar_id = $1 AND tenant_id = $2`, uint64(calendarId), middleware.TenantId(r))
+	err = scanCalendar(row, &calendar)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Calendar not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return calendar object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(calendar)
+}
+
+//For editing a calendar. Every field is replaced, the days off are kept
+func EditCalendar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get calendar id from req params
+	params := mux.Vars(r)
+	calendarId, err := strconv.Atoi(params["calendar_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	calendar := CalendarModel{WorkWeekdays: []int64{1, 2, 3, 4, 5}, HoursPerDay: 8}
+	if err := json.NewDecoder(r.Body).Decode(&calendar); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateCalendar(calendar); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `UPDATE calendars SET calendar_name = $2, location = $3, is_default = $4, work_weekdays = $5,
+		hours_per_day = $6 WHERE calendar_id = $1 AND tenant_id = $7`
+	result, err := db.Exec(stmt, uint64(calendarId), calendar.CalendarName, calendar.Location, calendar.IsDefault,
+		pq.Array(calendar.WorkWeekdays), calendar.HoursPerDay, middleware.TenantId(r))
+	if err != nil {
+		writeCalendarError(w, err)
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Calendar not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Calendar updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For deleting a calendar with its days off
+func DeleteCalendar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get calendar id from req params
+	params := mux.Vars(r)
+	calendarId, err := strconv.Atoi(params["calendar_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM calendars WHERE calendar_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(calendarId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Check if any row was affected by the delete operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Calendar not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Calendar deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,301 @@
+package calendar
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+//Sets a day off, replacing whatever the calendar had for that day
+const upsertDay = `INSERT INTO calendar_days(calendar_id, tenant_id, day, day_type, day_name, source, uid)
+	VALUES ($1, $2, $3::DATE, $4, $5, $6, NULLIF($7, ''))
+	ON CONFLICT (calendar_id, day) DO UPDATE SET day_type = EXCLUDED.day_type, day_name = EXCLUDED.day_name,
+		source = EXCLUDED.source, uid = EXCLUDED.uid`
+
+//Writes the error response and returns false if the calendar_id req param is not a calendar of the caller's tenant
+func calendarParam(w http.ResponseWriter, r *http.Request, db *sql.DB) (uint64, bool) {
+	params := mux.Vars(r)
+	calendarId, err := strconv.Atoi(params["calendar_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return 0, false
+	}
+	var found bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM calendars WHERE calendar_id = $1 AND tenant_id = $2)`
+	if err := db.QueryRow(stmt, uint64(calendarId), middleware.TenantId(r)).Scan(&found); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return 0, false
+	}
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Calendar not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return 0, false
+	}
+	return uint64(calendarId), true
+}
+
+//For fetching the days off of a calendar in date order. ?year= limits them to one year
+func GetCalendarDays(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var year int
+	if value := r.URL.Query().Get("year"); value != "" {
+		var err error
+		if year, err = strconv.Atoi(value); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Unable to convert year to int",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	calendarId, ok := calendarParam(w, r, db)
+	if !ok {
+		return
+	}
+	stmt := `SELECT day_id, TO_CHAR(day, 'YYYY-MM-DD'), day_type, day_name, source FROM calendar_days
+		WHERE calendar_id = $1 AND ($2 = 0 OR EXTRACT(YEAR FROM day) = $2) ORDER BY day`
+	rows, err := db.Query(stmt, calendarId, year)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []CalendarDayModel{}
+	for rows.Next() {
+		d := CalendarDayModel{}
+		if err := rows.Scan(&d.DayId, &d.Day, &d.DayType, &d.DayName, &d.Source); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, d)
+	}
+	//If everything went well, return array of day objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For setting a day off. Takes day, day_type (holiday or closure) and day_name
+func AddCalendarDay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	day := CalendarDayModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&day); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg := ""
+	if _, err := time.Parse("2006-01-02", day.Day); err != nil {
+		msg = "day must be formatted YYYY-MM-DD"
+	} else if day.DayType != "holiday" && day.DayType != "closure" {
+		msg = "day_type must be holiday or closure"
+	} else if day.DayName == "" {
+		msg = "day_name is required"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	calendarId, ok := calendarParam(w, r, db)
+	if !ok {
+		return
+	}
+	_, err := db.Exec(upsertDay, calendarId, middleware.TenantId(r), day.Day, day.DayType, day.DayName, "manual", "")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Day off set",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For removing a day off. The day is given as YYYY-MM-DD
+func DeleteCalendarDay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	day := mux.Vars(r)["day"]
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "day must be formatted YYYY-MM-DD",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	calendarId, ok := calendarParam(w, r, db)
+	if !ok {
+		return
+	}
+	result, err := db.Exec(`DELETE FROM calendar_days WHERE calendar_id = $1 AND day = $2::DATE`, calendarId, day)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Day off not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Day off removed",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For importing days off from an iCalendar file sent as the req body. Every day an event
+//covers becomes a day off of ?type= (holiday by default), replacing what the calendar had
+//for it. Recurring events and events longer than a year are skipped
+func ImportCalendar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	dayType := r.URL.Query().Get("type")
+	if dayType == "" {
+		dayType = "holiday"
+	}
+	if dayType != "holiday" && dayType != "closure" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "type must be holiday or closure",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	events, err := parseICal(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to read iCalendar file: " + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	calendarId, ok := calendarParam(w, r, db)
+	if !ok {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	imported, skipped := 0, 0
+	for _, event := range events {
+		if event.recurring || event.end.After(event.start.AddDate(1, 0, 0)) {
+			skipped++
+			continue
+		}
+		name := event.summary
+		if name == "" {
+			name = "Imported " + dayType
+		}
+		if runes := []rune(name); len(runes) > 128 {
+			name = string(runes[:128])
+		}
+		for day := event.start; err == nil && day.Before(event.end); day = day.AddDate(0, 0, 1) {
+			_, err = tx.Exec(upsertDay, calendarId, tenantId, day.Format("2006-01-02"), dayType, name, "ical", event.uid)
+			imported++
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Imported " + strconv.Itoa(imported) + " days off, skipped " + strconv.Itoa(skipped) + " events",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,123 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+//An event of an iCalendar file, reduced to the days it covers. end is exclusive
+type icalEvent struct {
+	uid       string
+	summary   string
+	start     time.Time
+	end       time.Time
+	recurring bool
+}
+
+//Joins folded lines. A line starting with a space or a tab continues the one before it (RFC 5545 3.1)
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+//Splits a content line into its upper-cased name and its value. Parameters are dropped,
+//a colon inside a quoted parameter value does not end the name
+func splitProperty(line string) (string, string) {
+	quoted := false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == ':' && !quoted:
+			name := line[:i]
+			if semi := strings.IndexByte(name, ';'); semi >= 0 {
+				name = name[:semi]
+			}
+			return strings.ToUpper(name), line[i+1:]
+		}
+	}
+	return strings.ToUpper(line), ""
+}
+
+//Undoes the escaping of TEXT values
+var unescaper = strings.NewReplacer(`\\`, `\`, `\;`, `;`, `\,`, `,`, `\n`, " ", `\N`, " ")
+
+//Reads the date of a DATE or DATE-TIME value. The time of day is dropped
+func parseDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, errors.New("invalid date " + value)
+	}
+	return time.Parse("20060102", value[:8])
+}
+
+//Reads the events of an iCalendar file. An event without DTEND lasts one day
+func parseICal(r io.Reader) ([]icalEvent, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var events []icalEvent
+	var event *icalEvent
+	calendar := false
+	for _, line := range lines {
+		name, value := splitProperty(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VCALENDAR"):
+			calendar = true
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			if event != nil {
+				return nil, errors.New("event " + event.uid + " has no END")
+			}
+			event = &icalEvent{}
+		case event == nil:
+			continue
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if event.start.IsZero() {
+				return nil, errors.New("event " + event.uid + " has no DTSTART")
+			}
+			if !event.end.After(event.start) {
+				event.end = event.start.AddDate(0, 0, 1)
+			}
+			events = append(events, *event)
+			event = nil
+		case name == "UID":
+			event.uid = value
+		case name == "SUMMARY":
+			event.summary = unescaper.Replace(value)
+		case name == "DTSTART":
+			if event.start, err = parseDate(value); err != nil {
+				return nil, err
+			}
+		case name == "DTEND":
+			if event.end, err = parseDate(value); err != nil {
+				return nil, err
+			}
+			//The end of a DATE value is exclusive, a DATE-TIME ending during a day covers that day
+			if len(value) >= 15 && value[9:15] != "000000" {
+				event.end = event.end.AddDate(0, 0, 1)
+			}
+		case name == "RRULE" || name == "RDATE":
+			event.recurring = true
+		}
+	}
+	if !calendar {
+		return nil, errors.New("not an iCalendar file")
+	}
+	if event != nil {
+		return nil, errors.New("event " + event.uid + " has no END")
+	}
+	return events, nil
+}
@@ -0,0 +1,97 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func ical(lines ...string) string {
+	return strings.Join(append(append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...), "END:VCALENDAR"), "\r\n")
+}
+
+func TestParseICal(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []icalEvent
+	}{
+		{"all-day event", ical(
+			"BEGIN:VEVENT", "UID:1", "SUMMARY:New Year", "DTSTART;VALUE=DATE:20250101", "DTEND;VALUE=DATE:20250102", "END:VEVENT",
+		), []icalEvent{{uid: "1", summary: "New Year", start: date("2025-01-01"), end: date("2025-01-02")}}},
+		{"all-day event over several days", ical(
+			"BEGIN:VEVENT", "UID:2", "DTSTART;VALUE=DATE:20251224", "DTEND;VALUE=DATE:20251227", "END:VEVENT",
+		), []icalEvent{{uid: "2", start: date("2025-12-24"), end: date("2025-12-27")}}},
+		{"event without DTEND lasts one day", ical(
+			"BEGIN:VEVENT", "UID:3", "DTSTART;VALUE=DATE:20250501", "END:VEVENT",
+		), []icalEvent{{uid: "3", start: date("2025-05-01"), end: date("2025-05-02")}}},
+		{"timed event covers its day", ical(
+			"BEGIN:VEVENT", "UID:4", "DTSTART:20250301T090000Z", "DTEND:20250301T170000Z", "END:VEVENT",
+		), []icalEvent{{uid: "4", start: date("2025-03-01"), end: date("2025-03-02")}}},
+		{"timed event with TZID ending during a later day", ical(
+			"BEGIN:VEVENT", "UID:5", `DTSTART;TZID="Europe/Berlin":20250301T220000`, "DTEND;TZID=Europe/Berlin:20250302T020000", "END:VEVENT",
+		), []icalEvent{{uid: "5", start: date("2025-03-01"), end: date("2025-03-03")}}},
+		{"timed event ending at midnight", ical(
+			"BEGIN:VEVENT", "UID:6", "DTSTART:20250301T090000", "DTEND:20250303T000000", "END:VEVENT",
+		), []icalEvent{{uid: "6", start: date("2025-03-01"), end: date("2025-03-03")}}},
+		{"folded and escaped lines", ical(
+			"BEGIN:VEVENT", "UID:7", "SUMMARY:Company\\, team", " and family day", "\tout", "DTSTART;VALUE=DATE:20250601", "END:VEVENT",
+		), []icalEvent{{uid: "7", summary: "Company, teamand family dayout", start: date("2025-06-01"), end: date("2025-06-02")}}},
+		{"recurring events", ical(
+			"BEGIN:VEVENT", "UID:8", "DTSTART;VALUE=DATE:20250101", "RRULE:FREQ=YEARLY", "END:VEVENT",
+			"BEGIN:VEVENT", "UID:9", "DTSTART;VALUE=DATE:20250101", "RDATE;VALUE=DATE:20260101", "END:VEVENT",
+		), []icalEvent{
+			{uid: "8", start: date("2025-01-01"), end: date("2025-01-02"), recurring: true},
+			{uid: "9", start: date("2025-01-01"), end: date("2025-01-02"), recurring: true},
+		}},
+		{"lower case names and LF line endings", strings.Replace(ical(
+			"begin:vevent", "uid:10", "dtstart;value=date:20250701", "end:vevent",
+		), "\r\n", "\n", -1), []icalEvent{{uid: "10", start: date("2025-07-01"), end: date("2025-07-02")}}},
+		{"properties outside events are ignored", ical(
+			"X-WR-CALNAME:Holidays", "DTSTART:20250101", "BEGIN:VTIMEZONE", "TZID:Europe/Berlin", "END:VTIMEZONE",
+		), nil},
+	}
+	for _, tt := range tests {
+		got, err := parseICal(strings.NewReader(tt.src))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d events, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: event %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestParseICalMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"not a calendar", "BEGIN:VEVENT\r\nDTSTART:20250101\r\nEND:VEVENT"},
+		{"empty input", ""},
+		{"event without DTSTART", ical("BEGIN:VEVENT", "UID:1", "SUMMARY:No date", "END:VEVENT")},
+		{"short DTSTART", ical("BEGIN:VEVENT", "DTSTART:2025", "END:VEVENT")},
+		{"invalid DTSTART", ical("BEGIN:VEVENT", "DTSTART:20251301", "END:VEVENT")},
+		{"invalid DTEND", ical("BEGIN:VEVENT", "DTSTART:20250101", "DTEND:2025-01-02", "END:VEVENT")},
+		{"event without END", ical("BEGIN:VEVENT", "UID:1", "DTSTART:20250101")},
+		{"nested event", ical("BEGIN:VEVENT", "UID:1", "DTSTART:20250101", "BEGIN:VEVENT", "UID:2",
+			"DTSTART:20250102", "END:VEVENT")},
+	}
+	for _, tt := range tests {
+		if _, err := parseICal(strings.NewReader(tt.src)); err == nil {
+			t.Errorf("%s: parsed, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package calendar
+
+import "time"
+
+//A working calendar. work_weekdays are ISO weekdays, 1 is Monday and 7 is Sunday. location
+//matches the location of employee records, the default calendar covers everyone else
+type CalendarModel struct {
+	CalendarId   uint64    `json:"id"`
+	CalendarName string    `json:"calendar_name"`
+	Location     *string   `json:"location"`
+	IsDefault    bool      `json:"is_default"`
+	WorkWeekdays []int64   `json:"work_weekdays"`
+	HoursPerDay  float64   `json:"hours_per_day"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+//A day off on a calendar. day is YYYY-MM-DD, day_type is holiday or closure
+type CalendarDayModel struct {
+	DayId   uint64 `json:"id"`
+	Day     string `json:"day"`
+	DayType string `json:"day_type"`
+	DayName string `json:"day_name"`
+	Source  string `json:"source"`
+}
+
+//The working days of an employee from one date to another, both inclusive. days_off are the
+//holidays and closures on working weekdays of the period
+type WorkingDaysModel struct {
+	UserId       uint64             `json:"user_id"`
+	CalendarId   *uint64            `json:"calendar_id"`
+	CalendarName string             `json:"calendar_name"`
+	From         string             `json:"from"`
+	To           string             `json:"to"`
+	WorkingDays  float64            `json:"working_days"`
+	Hours        float64            `json:"hours"`
+	DaysOff      []CalendarDayModel `json:"days_off"`
+}
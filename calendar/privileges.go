@@ -0,0 +1,9 @@
+package calendar
+
+import "hrm/catalog"
+
+//Privileges required by the calendar routes
+var (
+	PrivManageCalendars = catalog.Declare("manage_calendars", "Create, edit and delete working calendars, their days off and imports")
+	PrivReadCalendars   = catalog.Declare("read_calendars", "List working calendars and compute the working days of employees")
+)
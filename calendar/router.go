@@ -0,0 +1,49 @@
+package calendar
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleCalendarRoutes(r *mux.Router) {
+	//Endpoint for creating a working calendar
+	r.HandleFunc("/calendars",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCalendars, AddCalendar))).Methods("POST")
+
+	//Endpoint for fetching all working calendars
+	r.HandleFunc("/calendars",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadCalendars, GetCalendars))).Methods("GET")
+
+	//Endpoint for fetching a working calendar
+	r.HandleFunc("/calendars/{calendar_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadCalendars, GetCalendar))).Methods("GET")
+
+	//Endpoint for editing a working calendar
+	r.HandleFunc("/calendars/{calendar_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCalendars, EditCalendar))).Methods("PUT")
+
+	//Endpoint for deleting a working calendar
+	r.HandleFunc("/calendars/{calendar_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCalendars, DeleteCalendar))).Methods("DELETE")
+
+	//Endpoint for fetching the holidays and closures of a calendar. ?year= narrows it down
+	r.HandleFunc("/calendars/{calendar_id}/days",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadCalendars, GetCalendarDays))).Methods("GET")
+
+	//Endpoint for setting a holiday or closure
+	r.HandleFunc("/calendars/{calendar_id}/days",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCalendars, AddCalendarDay))).Methods("POST")
+
+	//Endpoint for removing a holiday or closure
+	r.HandleFunc("/calendars/{calendar_id}/days/{day}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCalendars, DeleteCalendarDay))).Methods("DELETE")
+
+	//Endpoint for importing holidays or closures from an iCalendar file
+	r.HandleFunc("/calendars/{calendar_id}/import",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCalendars, ImportCalendar))).Methods("POST")
+
+	//Endpoint for computing the working days of an employee between two dates
+	r.HandleFunc("/employees/{user_id}/working-days",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadCalendars, GetWorkingDays))).Methods("GET")
+}
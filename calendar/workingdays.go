@@ -0,0 +1,159 @@
+package calendar
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//Implemented by both *sql.DB and *sql.Tx
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+//The calendar employee $1 works by: the one of their location, else the default of their tenant
+const selectEmployeeCalendar = `SELECT c.calendar_id, c.calendar_name, c.work_weekdays, c.hours_per_day
+	FROM employees e JOIN calendars c ON c.tenant_id = e.tenant_id
+	WHERE e.user_id = $1 AND (c.location = e.location OR c.is_default)
+	ORDER BY (c.location = e.location) IS TRUE DESC LIMIT 1`
+
+//Works out the working days of an employee from from to to, both inclusive. Without any
+//calendar for the employee every Monday to Friday counts, at 8 hours a day
+func WorkingDays(q Queryer, userId uint64, from, to time.Time) (WorkingDaysModel, error) {
+	period := WorkingDaysModel{
+		UserId:  userId,
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+		DaysOff: []CalendarDayModel{},
+	}
+	calendar := CalendarModel{WorkWeekdays: []int64{1, 2, 3, 4, 5}, HoursPerDay: 8}
+	err := q.QueryRow(selectEmployeeCalendar, userId).Scan(&calendar.CalendarId, &calendar.CalendarName,
+		pq.Array(&calendar.WorkWeekdays), &calendar.HoursPerDay)
+	if err != nil && err != sql.ErrNoRows {
+		return period, err
+	}
+	daysOff := map[string]CalendarDayModel{}
+	if err == nil {
+		period.CalendarId, period.CalendarName = &calendar.CalendarId, calendar.CalendarName
+		stmt := `SELECT day_id, TO_CHAR(day, 'YYYY-MM-DD'), day_type, day_name, source FROM calendar_days
+			WHERE calendar_id = $1 AND day BETWEEN $2::DATE AND $3::DATE`
+		rows, err := q.Query(stmt, calendar.CalendarId, period.From, period.To)
+		if err != nil {
+			return period, err
+		}
+		for rows.Next() {
+			d := CalendarDayModel{}
+			if err := rows.Scan(&d.DayId, &d.Day, &d.DayType, &d.DayName, &d.Source); err != nil {
+				rows.Close()
+				return period, err
+			}
+			daysOff[d.Day] = d
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return period, err
+		}
+	}
+	weekdays := map[int64]bool{}
+	for _, weekday := range calendar.WorkWeekdays {
+		weekdays[weekday] = true
+	}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		//time.Weekday counts from Sunday as 0, ISO weekdays from Monday as 1
+		isoWeekday := int64(day.Weekday())
+		if isoWeekday == 0 {
+			isoWeekday = 7
+		}
+		if !weekdays[isoWeekday] {
+			continue
+		}
+		if off, ok := daysOff[day.Format("2006-01-02")]; ok {
+			period.DaysOff = append(period.DaysOff, off)
+			continue
+		}
+		period.WorkingDays++
+	}
+	period.Hours = period.WorkingDays * calendar.HoursPerDay
+	return period, nil
+}
+
+//For computing the working days of an employee between ?from= and ?to=, both YYYY-MM-DD
+//and inclusive, by the calendar of their location
+func GetWorkingDays(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg := ""
+	from, err := time.Parse("2006-01-02", r.URL.Query().Get("from"))
+	if err != nil {
+		msg = "from must be formatted YYYY-MM-DD"
+	}
+	to, err := time.Parse("2006-01-02", r.URL.Query().Get("to"))
+	if err != nil {
+		msg = "to must be formatted YYYY-MM-DD"
+	}
+	if msg == "" && to.Before(from) {
+		msg = "to cannot be before from"
+	}
+	if msg == "" && to.After(from.AddDate(10, 0, 0)) {
+		msg = "The period cannot be longer than ten years"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	var found bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM employees WHERE user_id = $1 AND tenant_id = $2)`
+	err = db.QueryRow(stmt, uint64(userId), middleware.TenantId(r)).Scan(&found)
+	if err == nil && !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	period := WorkingDaysModel{}
+	if err == nil {
+		period, err = WorkingDays(db, uint64(userId), from, to)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return the working days
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(period)
+}
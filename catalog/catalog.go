@@ -0,0 +1,168 @@
+//Package catalog keeps the privileges the application knows about. Packages declare
+//their privileges when they are loaded, the authorization middleware records every
+//privilege a route requires, and Sync reconciles both with the privileges table.
+package catalog
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+type Entry struct {
+	Name        string `json:"privilege_name"`
+	Description string `json:"description"`
+}
+
+var (
+	mu         sync.Mutex
+	declared   = map[string]string{}
+	referenced = map[string]bool{}
+	platform   = map[string]bool{}
+)
+
+//Declares a privilege and returns its name, so it can be assigned to a package level variable.
+//Declaring the same name twice with different descriptions is a programming error.
+func Declare(name, description string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	if existing, ok := declared[name]; ok && existing != description {
+		panic("catalog: privilege " + name + " declared twice")
+	}
+	declared[name] = description
+	return name
+}
+
+//Reports whether a package declares the privilege. Tenants cannot create privileges of these names
+func IsDeclared(name string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	_, ok := declared[name]
+	return ok
+}
+
+//Declares a privilege over the whole platform rather than one tenant. Only holders of a
+//platform privilege may grant it, tenant admins cannot hand it to their roles.
+func DeclarePlatform(name, description string) string {
+	Declare(name, description)
+	mu.Lock()
+	defer mu.Unlock()
+	platform[name] = true
+	return name
+}
+
+//Reports whether the privilege was declared with DeclarePlatform
+func IsPlatform(name string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return platform[name]
+}
+
+//Records that a route requires the privilege. Called when routes are registered
+func Reference(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	referenced[name] = true
+}
+
+//Returns the declared privileges sorted by name
+func Declared() []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+	entries := make([]Entry, 0, len(declared))
+	for name, description := range declared {
+		entries = append(entries, Entry{name, description})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+//Returns the privileges referenced by a route but never declared
+func Undeclared() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	var names []string
+	for name := range referenced {
+		if _, ok := declared[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Run once at startup after the routes are registered. Fails if a route references an
+//undeclared privilege, inserts declared privileges missing from the table, fills in
+//missing descriptions and flags privileges without a tenant that are no longer declared.
+//Returns the names of the orphaned privileges.
+func Sync(db *sql.DB) ([]string, error) {
+	if undeclared := Undeclared(); len(undeclared) > 0 {
+		return nil, fmt.Errorf("catalog: routes reference undeclared privileges: %s", strings.Join(undeclared, ", "))
+	}
+	entries := Declared()
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	//Declared privileges are shared by every tenant. Privileges tenants created before a package
+	//declared the same name stay theirs, they are reported below
+	stmt := `INSERT INTO privileges(privilege_name, description, orphaned) VALUES ($1, $2, FALSE)
+		ON CONFLICT (privilege_name) WHERE tenant_id IS NULL DO UPDATE SET orphaned = FALSE,
+		description = COALESCE(NULLIF(privileges.description, ''), EXCLUDED.description)`
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if _, err := tx.Exec(stmt, e.Name, e.Description); err != nil {
+			return nil, err
+		}
+		names = append(names, e.Name)
+	}
+	stmt = `UPDATE privileges SET orphaned = TRUE WHERE tenant_id IS NULL AND NOT (privilege_name = ANY($1))
+		RETURNING privilege_name`
+	rows, err := tx.Query(stmt, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	orphaned, err := scanNames(rows)
+	if err != nil {
+		return nil, err
+	}
+	stmt = `SELECT DISTINCT privilege_name FROM privileges WHERE tenant_id IS NOT NULL AND privilege_name = ANY($1)
+		ORDER BY privilege_name`
+	if rows, err = tx.Query(stmt, pq.Array(names)); err != nil {
+		return nil, err
+	}
+	clashing, err := scanNames(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if len(orphaned) > 0 {
+		log.Printf("catalog: privileges not declared by any package: %s", strings.Join(orphaned, ", "))
+	}
+	if len(clashing) > 0 {
+		log.Printf("catalog: tenant privileges named like declared ones, rename them: %s", strings.Join(clashing, ", "))
+	}
+	return orphaned, nil
+}
+
+//Reads the names in rows and closes them
+func scanNames(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
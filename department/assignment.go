@@ -0,0 +1,373 @@
+package department
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/group"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const selectAssignment = `SELECT a.assignment_id, a.user_id, u.username, a.department_id, d.department_name,
+		a.cost_center_id, TO_CHAR(a.effective_from, 'YYYY-MM-DD'), COALESCE(TO_CHAR(a.effective_to, 'YYYY-MM-DD'), ''),
+		a.created_by, a.created_at
+	FROM employee_assignments a JOIN users u ON u.user_id = a.user_id
+	JOIN departments d ON d.department_id = a.department_id`
+
+func queryAssignments(db *sql.DB, stmt string, args ...interface{}) ([]AssignmentModel, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	assignments := []AssignmentModel{}
+	for rows.Next() {
+		a := AssignmentModel{}
+		err := rows.Scan(&a.AssignmentId, &a.UserId, &a.Username, &a.DepartmentId, &a.DepartmentName,
+			&a.CostCenterId, &a.EffectiveFrom, &a.EffectiveTo, &a.CreatedBy, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+//Returns what is wrong with the period of an assignment, or an empty string
+func validatePeriod(a AssignmentModel) string {
+	from, err := time.Parse("2006-01-02", a.EffectiveFrom)
+	if err != nil {
+		return "effective_from must be formatted YYYY-MM-DD"
+	}
+	if a.EffectiveTo == "" {
+		return ""
+	}
+	to, err := time.Parse("2006-01-02", a.EffectiveTo)
+	if err != nil {
+		return "effective_to must be formatted YYYY-MM-DD"
+	}
+	if to.Before(from) {
+		return "effective_to cannot be before effective_from"
+	}
+	return ""
+}
+
+//For moving an employee to a department from effective_from on. An open ended assignment
+//starting earlier ends the day before, any other overlap is rejected
+func AddAssignment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	assignment := AssignmentModel{}
+	if err := json.NewDecoder(r.Body).Decode(&assignment); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validatePeriod(assignment); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Locking the employee keeps concurrent assignments of the same employee from overlapping
+	stmt := `SELECT user_id FROM employees WHERE user_id = $1 AND tenant_id = $2 FOR UPDATE`
+	err = tx.QueryRow(stmt, uint64(userId), tenantId).Scan(new(uint64))
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg := ""
+	if err == nil {
+		msg, err = checkReferences(tx, tenantId,
+			reference{&assignment.DepartmentId, departmentExists, "Department not found"},
+			reference{assignment.CostCenterId, costCenterExists, "Cost center not found"})
+	}
+	if err == nil && msg != "" {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		stmt = `UPDATE employee_assignments SET effective_to = $2::DATE - 1
+			WHERE user_id = $1 AND effective_to IS NULL AND effective_from < $2::DATE`
+		_, err = tx.Exec(stmt, uint64(userId), assignment.EffectiveFrom)
+	}
+	var overlaps bool
+	if err == nil {
+		stmt = `SELECT EXISTS(SELECT 1 FROM employee_assignments WHERE user_id = $1
+			AND effective_from <= COALESCE(NULLIF($3, '')::DATE, 'infinity')
+			AND COALESCE(effective_to, 'infinity') >= $2::DATE)`
+		err = tx.QueryRow(stmt, uint64(userId), assignment.EffectiveFrom, assignment.EffectiveTo).Scan(&overlaps)
+	}
+	if overlaps {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Assignment overlaps another assignment of the employee",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		stmt = `INSERT INTO employee_assignments(user_id, tenant_id, department_id, cost_center_id,
+				effective_from, effective_to, created_by)
+			VALUES ($1, $2, $3, $4, $5::DATE, NULLIF($6, '')::DATE, $7) RETURNING assignment_id`
+		err = tx.QueryRow(stmt, uint64(userId), tenantId, assignment.DepartmentId, assignment.CostCenterId,
+			assignment.EffectiveFrom, assignment.EffectiveTo, principal.UserId).Scan(&assignment.AssignmentId)
+	}
+	//An assignment starting today can move the employee in and out of dynamic groups
+	if err == nil {
+		msg, err = group.SyncUser(tx, uint64(userId))
+	}
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Assignment created with id " + strconv.FormatUint(assignment.AssignmentId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching the department history of an employee, the latest first
+func GetAssignments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	data, err := queryAssignments(db, selectAssignment+` WHERE a.user_id = $1 AND a.tenant_id = $2
+		ORDER BY a.effective_from DESC`, uint64(userId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of assignment objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For removing an assignment entered by mistake. Moves between departments are recorded
+//with a new assignment instead
+func DeleteAssignment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id and assignment id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	assignmentId, err := strconv.Atoi(params["assignment_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	stmt := `DELETE FROM employee_assignments WHERE assignment_id = $1 AND user_id = $2 AND tenant_id = $3`
+	result, err := tx.Exec(stmt, uint64(assignmentId), uint64(userId), middleware.TenantId(r))
+	if err == nil {
+		if count, err := result.RowsAffected(); err == nil && count == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Assignment not found",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	msg := ""
+	if err == nil {
+		msg, err = group.SyncUser(tx, uint64(userId))
+	}
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Assignment deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching who works in a department today. ?subtree=true adds the departments below it
+func GetDepartmentEmployees(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get department id from req params
+	params := mux.Vars(r)
+	departmentId, err := strconv.Atoi(params["department_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	var found bool
+	err = db.QueryRow(departmentExists, uint64(departmentId), tenantId).Scan(&found)
+	if err == nil && !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Department not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	data := []AssignmentModel{}
+	if err == nil {
+		stmt := selectAssignment + ` WHERE a.department_id = $1`
+		if r.URL.Query().Get("subtree") == "true" {
+			stmt = descendantsCTE + ` ` + selectAssignment + ` WHERE a.department_id IN (SELECT department_id FROM descendants)`
+		}
+		stmt += ` AND a.tenant_id = $2 AND a.effective_from <= CURRENT_DATE
+			AND (a.effective_to IS NULL OR a.effective_to >= CURRENT_DATE) ORDER BY u.username`
+		data, err = queryAssignments(db, stmt, uint64(departmentId), tenantId)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of assignment objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
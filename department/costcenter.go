@@ -0,0 +1,312 @@
+package department
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const selectCostCenter = `SELECT cost_center_id, cost_center_code, cost_center_name, head_id,
+		COALESCE(budget::TEXT, ''), COALESCE(currency, ''), created_at
+	FROM cost_centers`
+
+var (
+	budgetPattern   = regexp.MustCompile(`^[0-9]{1,12}(\.[0-9]{1,2})?$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+//Returns what is wrong with a cost center, or an empty string
+func validateCostCenter(c CostCenterModel) string {
+	if c.CostCenterCode == "" || c.CostCenterName == "" {
+		return "cost_center_code and cost_center_name are required"
+	}
+	if c.Budget != "" && !budgetPattern.MatchString(c.Budget) {
+		return "budget must be a positive amount with at most two decimals"
+	}
+	if c.Currency != "" && !currencyPattern.MatchString(c.Currency) {
+		return "currency must be a three letter ISO 4217 code"
+	}
+	if c.Budget != "" && c.Currency == "" {
+		return "A budget needs a currency"
+	}
+	return ""
+}
+
+//Writes the error response and returns false if the head of a cost center is not an employee of tenantId
+func headExists(w http.ResponseWriter, db *sql.DB, headId *uint64, tenantId uint64) bool {
+	msg, err := checkReferences(db, tenantId, reference{headId, employeeExists, "Head not found"})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return false
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return false
+	}
+	return true
+}
+
+//For creating a cost center. Takes cost_center_code, cost_center_name and the optional
+//head_id, budget and currency
+func AddCostCenter(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	costCenter := CostCenterModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&costCenter); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateCostCenter(costCenter); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	if !headExists(w, db, costCenter.HeadId, tenantId) {
+		return
+	}
+	stmt := `INSERT INTO cost_centers(tenant_id, cost_center_code, cost_center_name, head_id, budget, currency)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::NUMERIC, NULLIF($6, '')) RETURNING cost_center_id`
+	err := db.QueryRow(stmt, tenantId, costCenter.CostCenterCode, costCenter.CostCenterName, costCenter.HeadId,
+		costCenter.Budget, costCenter.Currency).Scan(&costCenter.CostCenterId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Cost center code already in use",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Cost center created with id " + strconv.FormatUint(costCenter.CostCenterId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all cost centers
+func GetCostCenters(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	rows, err := db.Query(selectCostCenter+` WHERE tenant_id = $1 ORDER BY cost_center_code`, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []CostCenterModel{}
+	for rows.Next() {
+		c := CostCenterModel{}
+		err := rows.Scan(&c.CostCenterId, &c.CostCenterCode, &c.CostCenterName, &c.HeadId, &c.Budget, &c.Currency, &c.CreatedAt)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, c)
+	}
+	//If everything went well, return array of cost center objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For editing a cost center. Every field is replaced
+func EditCostCenter(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get cost center id from req params
+	params := mux.Vars(r)
+	costCenterId, err := strconv.Atoi(params["cost_center_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	costCenter := CostCenterModel{}
+	if err := json.NewDecoder(r.Body).Decode(&costCenter); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateCostCenter(costCenter); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	if !headExists(w, db, costCenter.HeadId, tenantId) {
+		return
+	}
+	stmt := `UPDATE cost_centers SET cost_center_code = $2, cost_center_name = $3, head_id = $4,
+		budget = NULLIF($5, '')::NUMERIC, currency = NULLIF($6, '') WHERE cost_center_id = $1 AND tenant_id = $7`
+	result, err := db.Exec(stmt, uint64(costCenterId), costCenter.CostCenterCode, costCenter.CostCenterName,
+		costCenter.HeadId, costCenter.Budget, costCenter.Currency, tenantId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Cost center code already in use",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Cost center not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Cost center updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For deleting a cost center no department or assignment refers to
+func DeleteCostCenter(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get cost center id from req params
+	params := mux.Vars(r)
+	costCenterId, err := strconv.Atoi(params["cost_center_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM cost_centers WHERE cost_center_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(costCenterId), middleware.TenantId(r))
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23503" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Cost center is used by departments or assignments",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//Check if any row was affected by the delete operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Cost center not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Cost center deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,487 @@
+package department
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const selectDepartment = `SELECT department_id, department_code, department_name, parent_department_id,
+		head_id, cost_center_id, created_at
+	FROM departments`
+
+//Walks down the department tree from department $1, including $1 itself
+const descendantsCTE = `WITH RECURSIVE descendants(department_id) AS (
+		SELECT CAST($1 AS BIGINT)
+	UNION
+		SELECT d.department_id FROM departments d JOIN descendants ds ON d.parent_department_id = ds.department_id
+	)`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDepartment(row scanner, d *DepartmentModel) error {
+	return row.Scan(&d.DepartmentId, &d.DepartmentCode, &d.DepartmentName, &d.ParentDepartmentId,
+		&d.HeadId, &d.CostCenterId, &d.CreatedAt)
+}
+
+//Implemented by both *sql.DB and *sql.Tx
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+//Statements telling whether id $1 exists in tenant $2
+const (
+	departmentExists = `SELECT EXISTS(SELECT 1 FROM departments WHERE department_id = $1 AND tenant_id = $2)`
+	employeeExists   = `SELECT EXISTS(SELECT 1 FROM employees WHERE user_id = $1 AND tenant_id = $2)`
+	costCenterExists = `SELECT EXISTS(SELECT 1 FROM cost_centers WHERE cost_center_id = $1 AND tenant_id = $2)`
+)
+
+//An optional id something refers to, with the statement checking it and the message if it is missing
+type reference struct {
+	id      *uint64
+	stmt    string
+	message string
+}
+
+//Returns the message of the first reference that does not exist in tenantId, or an empty string
+func checkReferences(q rowQueryer, tenantId uint64, refs ...reference) (string, error) {
+	for _, ref := range refs {
+		if ref.id == nil {
+			continue
+		}
+		var found bool
+		if err := q.QueryRow(ref.stmt, *ref.id, tenantId).Scan(&found); err != nil {
+			return "", err
+		}
+		if !found {
+			return ref.message, nil
+		}
+	}
+	return "", nil
+}
+
+func departmentReferences(d DepartmentModel) []reference {
+	return []reference{
+		{d.ParentDepartmentId, departmentExists, "Parent department not found"},
+		{d.HeadId, employeeExists, "Head not found"},
+		{d.CostCenterId, costCenterExists, "Cost center not found"},
+	}
+}
+
+//For creating a department. Takes department_code, department_name and the optional
+//parent_department_id, head_id and cost_center_id
+func AddDepartment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	department := DepartmentModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&department); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if department.DepartmentCode == "" || department.DepartmentName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "department_code and department_name are required",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	msg, err := checkReferences(db, tenantId, departmentReferences(department)...)
+	if err == nil && msg != "" {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		stmt := `INSERT INTO departments(tenant_id, department_code, department_name, parent_department_id, head_id, cost_center_id)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING department_id`
+		err = db.QueryRow(stmt, tenantId, department.DepartmentCode, department.DepartmentName,
+			department.ParentDepartmentId, department.HeadId, department.CostCenterId).Scan(&department.DepartmentId)
+	}
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Department code already in use",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Department created with id " + strconv.FormatUint(department.DepartmentId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all departments
+func GetDepartments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	rows, err := db.Query(selectDepartment+` WHERE tenant_id = $1 ORDER BY department_code`, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []DepartmentModel{}
+	for rows.Next() {
+		department := DepartmentModel{}
+		if err := scanDepartment(rows, &department); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, department)
+	}
+	//If everything went well, return array of department objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching a single department
+func GetDepartment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get department id from req params
+	params := mux.Vars(r)
+	departmentId, err := strconv.Atoi(params["department_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	department := DepartmentModel{}
+	row := db.QueryRow(selectDepartment+` WHERE department_id = $1 AND tenant_id = $2`, uint64(departmentId), middleware.TenantId(r))
+	err = scanDepartment(row, &department)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Department not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return department object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(department)
+}
+
+//For editing a department. Every field is replaced. The new parent cannot be the department
+//itself or a department below it
+func EditDepartment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get department id from req params
+	params := mux.Vars(r)
+	departmentId, err := strconv.Atoi(params["department_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	department := DepartmentModel{}
+	if err := json.NewDecoder(r.Body).Decode(&department); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if department.DepartmentCode == "" || department.DepartmentName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "department_code and department_name are required",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	msg, err := checkReferences(db, tenantId, departmentReferences(department)...)
+	if err == nil && msg != "" {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Moving the department under itself or one of its descendants creates a cycle
+	var loops bool
+	if err == nil && department.ParentDepartmentId != nil {
+		stmt := descendantsCTE + ` SELECT EXISTS(SELECT 1 FROM descendants WHERE department_id = $2)`
+		err = db.QueryRow(stmt, uint64(departmentId), *department.ParentDepartmentId).Scan(&loops)
+	}
+	if loops {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Department hierarchy cannot contain a cycle",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var result sql.Result
+	if err == nil {
+		stmt := `UPDATE departments SET department_code = $2, department_name = $3, parent_department_id = $4,
+			head_id = $5, cost_center_id = $6 WHERE department_id = $1 AND tenant_id = $7`
+		result, err = db.Exec(stmt, uint64(departmentId), department.DepartmentCode, department.DepartmentName,
+			department.ParentDepartmentId, department.HeadId, department.CostCenterId, tenantId)
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Department code already in use",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Department not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Department updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For deleting a department. A department with sub-departments or with employees assigned
+//to it, now or in the past, cannot be deleted
+func DeleteDepartment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get department id from req params
+	params := mux.Vars(r)
+	departmentId, err := strconv.Atoi(params["department_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM departments WHERE department_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(departmentId), middleware.TenantId(r))
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23503" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Department has sub-departments or assignments",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//Check if any row was affected by the delete operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Department not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Department deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching a department with all the departments below it
+func GetDepartmentTree(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get department id from req params
+	params := mux.Vars(r)
+	departmentId, err := strconv.Atoi(params["department_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := descendantsCTE + ` SELECT d.department_id, d.department_code, d.department_name, d.head_id,
+			d.parent_department_id
+		FROM departments d JOIN descendants ds ON ds.department_id = d.department_id
+		WHERE d.tenant_id = $2 ORDER BY d.department_code`
+	rows, err := db.Query(stmt, uint64(departmentId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	nodes := map[uint64]DepartmentTreeModel{}
+	children := map[uint64][]uint64{}
+	for rows.Next() {
+		node := DepartmentTreeModel{}
+		var parentId sql.NullInt64
+		if err := rows.Scan(&node.DepartmentId, &node.DepartmentCode, &node.DepartmentName, &node.HeadId, &parentId); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		nodes[node.DepartmentId] = node
+		if parentId.Valid && node.DepartmentId != uint64(departmentId) {
+			children[uint64(parentId.Int64)] = append(children[uint64(parentId.Int64)], node.DepartmentId)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	root, ok := nodes[uint64(departmentId)]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Department not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return the nested department tree
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(buildTree(root, nodes, children))
+}
+
+func buildTree(node DepartmentTreeModel, nodes map[uint64]DepartmentTreeModel, children map[uint64][]uint64) DepartmentTreeModel {
+	node.Children = []DepartmentTreeModel{}
+	for _, childId := range children[node.DepartmentId] {
+		node.Children = append(node.Children, buildTree(nodes[childId], nodes, children))
+	}
+	return node
+}
@@ -0,0 +1,48 @@
+package department
+
+import "time"
+
+type DepartmentModel struct {
+	DepartmentId       uint64    `json:"id"`
+	DepartmentCode     string    `json:"department_code"`
+	DepartmentName     string    `json:"department_name"`
+	ParentDepartmentId *uint64   `json:"parent_department_id"`
+	HeadId             *uint64   `json:"head_id"`
+	CostCenterId       *uint64   `json:"cost_center_id"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+//A department with the departments below it
+type DepartmentTreeModel struct {
+	DepartmentId   uint64                `json:"id"`
+	DepartmentCode string                `json:"department_code"`
+	DepartmentName string                `json:"department_name"`
+	HeadId         *uint64               `json:"head_id"`
+	Children       []DepartmentTreeModel `json:"children"`
+}
+
+//budget is a decimal string with up to two decimals, currency an ISO 4217 code
+type CostCenterModel struct {
+	CostCenterId   uint64    `json:"id"`
+	CostCenterCode string    `json:"cost_center_code"`
+	CostCenterName string    `json:"cost_center_name"`
+	HeadId         *uint64   `json:"head_id"`
+	Budget         string    `json:"budget"`
+	Currency       string    `json:"currency"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+//An employee working in a department over a period. Dates are YYYY-MM-DD, both inclusive,
+//an empty effective_to is open ended. Without cost_center_id the department's cost center applies
+type AssignmentModel struct {
+	AssignmentId   uint64    `json:"id"`
+	UserId         uint64    `json:"user_id"`
+	Username       string    `json:"username"`
+	DepartmentId   uint64    `json:"department_id"`
+	DepartmentName string    `json:"department_name"`
+	CostCenterId   *uint64   `json:"cost_center_id"`
+	EffectiveFrom  string    `json:"effective_from"`
+	EffectiveTo    string    `json:"effective_to"`
+	CreatedBy      *uint64   `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package department
+
+import "hrm/catalog"
+
+//Privileges required by the department routes
+var (
+	PrivCreateDepartment = catalog.Declare("create_department", "Create a department")
+	PrivReadDepartments  = catalog.Declare("read_departments", "List departments, read one and its tree")
+	PrivModifyDepartment = catalog.Declare("modify_department", "Edit a department, its parent and head")
+	PrivDeleteDepartment = catalog.Declare("delete_department", "Delete an empty department")
+
+	PrivManageCostCenters = catalog.Declare("manage_cost_centers", "Create, edit and delete cost centers")
+	PrivReadCostCenters   = catalog.Declare("read_cost_centers", "List cost centers and their budgets")
+
+	PrivAssignDepartment = catalog.Declare("assign_department", "Move employees between departments and cost centers")
+	PrivReadAssignments  = catalog.Declare("read_assignments", "Read the department history of employees and who works in a department")
+)
@@ -0,0 +1,65 @@
+package department
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleDepartmentRoutes(r *mux.Router) {
+	//Endpoint for creating a department
+	r.HandleFunc("/departments",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivCreateDepartment, AddDepartment))).Methods("POST")
+
+	//Endpoint for fetching all departments
+	r.HandleFunc("/departments",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadDepartments, GetDepartments))).Methods("GET")
+
+	//Endpoint for fetching a department
+	r.HandleFunc("/departments/{department_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadDepartments, GetDepartment))).Methods("GET")
+
+	//Endpoint for editing a department
+	r.HandleFunc("/departments/{department_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyDepartment, EditDepartment))).Methods("PUT")
+
+	//Endpoint for deleting a department
+	r.HandleFunc("/departments/{department_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDeleteDepartment, DeleteDepartment))).Methods("DELETE")
+
+	//Endpoint for fetching a department with the departments below it
+	r.HandleFunc("/departments/{department_id}/tree",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadDepartments, GetDepartmentTree))).Methods("GET")
+
+	//Endpoint for fetching the employees currently assigned to a department
+	r.HandleFunc("/departments/{department_id}/employees",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadAssignments, GetDepartmentEmployees))).Methods("GET")
+
+	//Endpoint for creating a cost center
+	r.HandleFunc("/cost-centers",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCostCenters, AddCostCenter))).Methods("POST")
+
+	//Endpoint for fetching all cost centers
+	r.HandleFunc("/cost-centers",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadCostCenters, GetCostCenters))).Methods("GET")
+
+	//Endpoint for editing a cost center
+	r.HandleFunc("/cost-centers/{cost_center_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCostCenters, EditCostCenter))).Methods("PUT")
+
+	//Endpoint for deleting a cost center
+	r.HandleFunc("/cost-centers/{cost_center_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCostCenters, DeleteCostCenter))).Methods("DELETE")
+
+	//Endpoint for assigning an employee to a department
+	r.HandleFunc("/employees/{user_id}/assignments",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivAssignDepartment, AddAssignment))).Methods("POST")
+
+	//Endpoint for fetching the department history of an employee
+	r.HandleFunc("/employees/{user_id}/assignments",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadAssignments, GetAssignments))).Methods("GET")
+
+	//Endpoint for deleting an assignment of an employee
+	r.HandleFunc("/employees/{user_id}/assignments/{assignment_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivAssignDepartment, DeleteAssignment))).Methods("DELETE")
+}
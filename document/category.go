@@ -0,0 +1,330 @@
+package document
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//The largest upload the server reads, whatever the category allows
+const maxUploadBytes = 25 << 20
+
+const selectCategory = `SELECT category_id, category_code, category_name, allowed_types, max_bytes,
+		read_privilege, write_privilege, created_at
+	FROM document_categories`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCategory(row scanner, c *CategoryModel) error {
+	return row.Scan(&c.CategoryId, &c.CategoryCode, &c.CategoryName, pq.Array(&c.AllowedTypes), &c.MaxBytes,
+		&c.ReadPrivilege, &c.WritePrivilege, &c.CreatedAt)
+}
+
+//Returns what is wrong with a category, or an empty string
+func validateCategory(c CategoryModel) string {
+	if c.CategoryCode == "" || c.CategoryName == "" {
+		return "category_code and category_name are required"
+	}
+	if len(c.AllowedTypes) == 0 {
+		return "allowed_types needs at least one media type"
+	}
+	for _, allowed := range c.AllowedTypes {
+		mediaType, params, err := mime.ParseMediaType(allowed)
+		if err != nil || len(params) > 0 || mediaType != allowed {
+			return "allowed_types must be lowercase media types without parameters, e.g. application/pdf"
+		}
+	}
+	if c.MaxBytes <= 0 || c.MaxBytes > maxUploadBytes {
+		return "max_bytes must be between 1 and " + strconv.Itoa(maxUploadBytes)
+	}
+	return ""
+}
+
+//Returns the name of a privilege of the category that does not exist in tenantId, or an empty string
+func unknownPrivilege(db *sql.DB, c CategoryModel, tenantId uint64) (string, error) {
+	stmt := `SELECT EXISTS(SELECT 1 FROM privileges WHERE privilege_name = $1 AND (tenant_id IS NULL OR tenant_id = $2))`
+	for _, privilege := range []*string{c.ReadPrivilege, c.WritePrivilege} {
+		if privilege == nil {
+			continue
+		}
+		var found bool
+		if err := db.QueryRow(stmt, *privilege, tenantId).Scan(&found); err != nil || !found {
+			return *privilege, err
+		}
+	}
+	return "", nil
+}
+
+//Writes the error response and returns false if the category cannot be saved
+func checkCategory(w http.ResponseWriter, db *sql.DB, c CategoryModel, tenantId uint64) bool {
+	if msg := validateCategory(c); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return false
+	}
+	privilege, err := unknownPrivilege(db, c, tenantId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return false
+	}
+	if privilege != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Privilege " + privilege + " not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return false
+	}
+	return true
+}
+
+//For creating a document category. Takes category_code, category_name, allowed_types, max_bytes
+//and optionally read_privilege and write_privilege
+func AddCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	category := CategoryModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	if !checkCategory(w, db, category, tenantId) {
+		return
+	}
+	stmt := `INSERT INTO document_categories(tenant_id, category_code, category_name, allowed_types, max_bytes,
+			read_privilege, write_privilege)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING category_id`
+	err := db.QueryRow(stmt, tenantId, category.CategoryCode, category.CategoryName, pq.Array(category.AllowedTypes),
+		category.MaxBytes, category.ReadPrivilege, category.WritePrivilege).Scan(&category.CategoryId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Category code already in use",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Document category created with id " + strconv.FormatUint(category.CategoryId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all document categories
+func GetCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	rows, err := db.Query(selectCategory+` WHERE tenant_id = $1 ORDER BY category_code`, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []CategoryModel{}
+	for rows.Next() {
+		c := CategoryModel{}
+		if err := scanCategory(rows, &c); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, c)
+	}
+	//If everything went well, return array of category objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For editing a document category. Every field is replaced. Documents already uploaded are
+//kept even if they no longer fit the category
+func EditCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get category id from req params
+	params := mux.Vars(r)
+	categoryId, err := strconv.Atoi(params["category_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	category := CategoryModel{}
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	if !checkCategory(w, db, category, tenantId) {
+		return
+	}
+	stmt := `UPDATE document_categories SET category_code = $2, category_name = $3, allowed_types = $4,
+			max_bytes = $5, read_privilege = $6, write_privilege = $7
+		WHERE category_id = $1 AND tenant_id = $8`
+	result, err := db.Exec(stmt, uint64(categoryId), category.CategoryCode, category.CategoryName,
+		pq.Array(category.AllowedTypes), category.MaxBytes, category.ReadPrivilege, category.WritePrivilege, tenantId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Category code already in use",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Document category not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Document category updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For deleting a document category that holds no documents
+func DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get category id from req params
+	params := mux.Vars(r)
+	categoryId, err := strconv.Atoi(params["category_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM document_categories WHERE category_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(categoryId), middleware.TenantId(r))
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23503" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Document category still holds documents",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//Check if any row was affected by the delete operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Document category not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Document category deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,477 @@
+package document
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hrm/db"
+	"hrm/middleware"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const selectDocument = `SELECT d.document_id, d.user_id, d.category_id, c.category_code, d.file_name, d.content_type,
+		d.size_bytes, d.sha256, TO_CHAR(d.expires_on, 'YYYY-MM-DD'), d.uploaded_by, d.uploaded_at,
+		d.storage_key, c.read_privilege, c.write_privilege
+	FROM employee_documents d JOIN document_categories c ON c.category_id = d.category_id`
+
+//A document with where its content is kept and the privileges of its category
+type storedDocument struct {
+	DocumentModel
+	storageKey     string
+	readPrivilege  *string
+	writePrivilege *string
+}
+
+func scanDocument(row scanner, d *storedDocument) error {
+	return row.Scan(&d.DocumentId, &d.UserId, &d.CategoryId, &d.CategoryCode, &d.FileName, &d.ContentType,
+		&d.SizeBytes, &d.Sha256, &d.ExpiresOn, &d.UploadedBy, &d.UploadedAt,
+		&d.storageKey, &d.readPrivilege, &d.writePrivilege)
+}
+
+//Reports whether privileges meet a category privilege. Anyone meets a privilege that is not set
+func holds(privileges []string, privilege *string) bool {
+	if privilege == nil {
+		return true
+	}
+	for _, p := range privileges {
+		if p == *privilege {
+			return true
+		}
+	}
+	return false
+}
+
+//Writes the error response and returns false if the caller lacks the category privilege
+//needed to action its documents
+func checkCategoryPrivilege(w http.ResponseWriter, r *http.Request, privilege *string, action string) bool {
+	if privilege == nil {
+		return true
+	}
+	if !holds(middleware.PrivilegesFromContext(r.Context()), privilege) {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Not allowed to " + action + " documents of this category",
+		}
+		json.NewEncoder(w).Encode(res)
+		return false
+	}
+	return true
+}
+
+//Writes the error response and returns false if the user_id req param is not an employee of the caller's tenant
+func employeeParam(w http.ResponseWriter, r *http.Request, db *sql.DB) (uint64, bool) {
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return 0, false
+	}
+	var found bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM employees WHERE user_id = $1 AND tenant_id = $2)`
+	if err := db.QueryRow(stmt, uint64(userId), middleware.TenantId(r)).Scan(&found); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return 0, false
+	}
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return 0, false
+	}
+	return uint64(userId), true
+}
+
+//Writes the error response and returns false if the document_id req param is not a document
+//of the employee in the user_id req param
+func documentParam(w http.ResponseWriter, r *http.Request, db *sql.DB) (storedDocument, bool) {
+	d := storedDocument{}
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	documentId, err2 := strconv.Atoi(params["document_id"])
+	if err != nil || err2 != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return d, false
+	}
+	row := db.QueryRow(selectDocument+` WHERE d.document_id = $1 AND d.user_id = $2 AND d.tenant_id = $3`,
+		uint64(documentId), uint64(userId), middleware.TenantId(r))
+	err = scanDocument(row, &d)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Document not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return d, false
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return d, false
+	}
+	return d, true
+}
+
+//Returns the media type of file from its first 512 bytes, then rewinds it
+func sniff(file multipart.File) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return mediaType, err
+}
+
+//Storage keys are random so they give nothing away about the document
+func newStorageKey(tenantId, userId uint64) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d/%d/%s", tenantId, userId, hex.EncodeToString(random)), nil
+}
+
+//Streams the content of d as an attachment. Errors before the content starts get a json response
+func serveContent(w http.ResponseWriter, d storedDocument) {
+	store, err := OpenStore()
+	var content io.ReadCloser
+	if err == nil {
+		content, err = store.Get(d.storageKey)
+	}
+	if err == ErrNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Document content not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer content.Close()
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": d.FileName})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	//The type was sniffed on upload, browsers must not second-guess it
+	w.Header().Set("Content-Type", d.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(d.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("documents: sending document %d: %v", d.DocumentId, err)
+	}
+}
+
+//For attaching a document to an employee record. Takes a multipart form with category_id,
+//optionally expires_on as YYYY-MM-DD, and the file. The content type is sniffed from the
+//file itself and must be one the category allows, as must the size
+func UploadDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Room for the form fields on top of the largest file
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to read multipart form: " + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	categoryId, err := strconv.ParseUint(r.FormValue("category_id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "category_id must be a document category id",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var expiresOn *string
+	if value := r.FormValue("expires_on"); value != "" {
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "expires_on must be a date as YYYY-MM-DD",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		expiresOn = &value
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil || header.Size == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "file is required and cannot be empty",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer file.Close()
+	//Long names keep their end, where the extension is
+	fileName := header.Filename
+	if fileName == "" {
+		fileName = "document"
+	}
+	if runes := []rune(fileName); len(runes) > 255 {
+		fileName = string(runes[len(runes)-255:])
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	userId, ok := employeeParam(w, r, db)
+	if !ok {
+		return
+	}
+	category := CategoryModel{}
+	row := db.QueryRow(selectCategory+` WHERE category_id = $1 AND tenant_id = $2`, categoryId, tenantId)
+	err = scanCategory(row, &category)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Document category not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if !checkCategoryPrivilege(w, r, category.WritePrivilege, "upload") {
+		return
+	}
+	if header.Size > category.MaxBytes {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		res := middleware.Response{
+			Error:   true,
+			Message: fmt.Sprintf("%s documents can be at most %d bytes", category.CategoryCode, category.MaxBytes),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	contentType, err := sniff(file)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if !holds(category.AllowedTypes, &contentType) {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Files of type " + contentType + " cannot be uploaded as " + category.CategoryCode + " documents",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	store, err := OpenStore()
+	var key string
+	if err == nil {
+		key, err = newStorageKey(tenantId, userId)
+	}
+	hash := sha256.New()
+	if err == nil {
+		err = store.Put(key, io.TeeReader(file, hash), header.Size, contentType)
+	}
+	var documentId uint64
+	if err == nil {
+		stmt := `INSERT INTO employee_documents(tenant_id, user_id, category_id, file_name, content_type, size_bytes,
+				sha256, storage_key, expires_on, uploaded_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::DATE, $10) RETURNING document_id`
+		err = db.QueryRow(stmt, tenantId, userId, categoryId, fileName, contentType, header.Size,
+			hex.EncodeToString(hash.Sum(nil)), key, expiresOn, principal.UserId).Scan(&documentId)
+		//Nothing refers to the content without the row
+		if err != nil {
+			store.Delete(key)
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Document uploaded with id " + strconv.FormatUint(documentId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching the documents of an employee in the categories the caller may read, by category
+//and the newest first. ?category_id= narrows it down
+func GetDocuments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var categoryId uint64
+	if value := r.URL.Query().Get("category_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "category_id must be a document category id",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		categoryId = id
+	}
+	privileges := middleware.PrivilegesFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	userId, ok := employeeParam(w, r, db)
+	if !ok {
+		return
+	}
+	stmt := selectDocument + ` WHERE d.user_id = $1 AND ($2 = 0 OR d.category_id = $2)
+		ORDER BY c.category_code, d.uploaded_at DESC`
+	rows, err := db.Query(stmt, userId, categoryId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []DocumentModel{}
+	for rows.Next() {
+		d := storedDocument{}
+		if err := scanDocument(rows, &d); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if holds(privileges, d.readPrivilege) {
+			data = append(data, d.DocumentModel)
+		}
+	}
+	//If everything went well, return array of document objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For downloading the content of a document
+func GetDocumentContent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	d, ok := documentParam(w, r, db)
+	if !ok || !checkCategoryPrivilege(w, r, d.readPrivilege, "read") {
+		return
+	}
+	serveContent(w, d)
+}
+
+//For deleting a document. Its content is removed from the store right away, or by the purge
+//job if the store cannot be reached
+func DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	d, ok := documentParam(w, r, db)
+	if !ok || !checkCategoryPrivilege(w, r, d.writePrivilege, "delete") {
+		return
+	}
+	_, err := db.Exec(`DELETE FROM employee_documents WHERE document_id = $1`, d.DocumentId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err := purge(db, d.storageKey); err != nil {
+		log.Printf("documents: %v, left to the purge job", err)
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Document deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
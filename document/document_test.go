@@ -0,0 +1,48 @@
+package document
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+//A multipart.File over a byte slice
+type memFile struct {
+	*bytes.Reader
+}
+
+func (memFile) Close() error {
+	return nil
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj"), "application/pdf"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png"},
+		{"text without a charset parameter", []byte("Employment contract"), "text/plain"},
+		{"html", []byte("<!DOCTYPE html><html><body>hi</body></html>"), "text/html"},
+		{"empty", nil, "text/plain"},
+		{"binary", []byte{0x00, 0x01, 0x02, 0xff}, "application/octet-stream"},
+		{"longer than the sniffed head", append([]byte("%PDF-1.4\n"), make([]byte, 2048)...), "application/pdf"},
+	}
+	for _, tt := range tests {
+		file := memFile{bytes.NewReader(tt.content)}
+		got, err := sniff(file)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: sniff = %q, want %q", tt.name, got, tt.want)
+		}
+		//The upload is read again from the start after sniffing
+		rest, _ := io.ReadAll(file)
+		if !bytes.Equal(rest, tt.content) {
+			t.Errorf("%s: file not rewound after sniffing", tt.name)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package document
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"hrm/db"
+	"hrm/middleware"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+)
+
+//Download links are signed with the jwt secret under their own audience, which JwtVerify
+//turns away, so a link never passes for a login token
+const linkAudience = "document-download"
+
+//Links last 15 minutes unless asked otherwise, a day at most
+const (
+	defaultLinkTtl = 15
+	maxLinkTtl     = 24 * 60
+)
+
+func signLink(d storedDocument, tenantId, issuedBy uint64, expiresAt time.Time) (string, error) {
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["aud"] = linkAudience
+	claims["documentId"] = strconv.FormatUint(d.DocumentId, 10)
+	claims["tenantId"] = strconv.FormatUint(tenantId, 10)
+	claims["issuedBy"] = strconv.FormatUint(issuedBy, 10)
+	claims["exp"] = expiresAt.Unix()
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+//Returns the document, tenant and issuer ids of a download link that is signed and not expired
+func parseLink(link string) (documentId, tenantId, issuedBy uint64, err error) {
+	token, err := jwt.Parse(link, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("invalid signing method")
+		}
+		if !token.Claims.(jwt.MapClaims).VerifyAudience(linkAudience, true) {
+			return nil, fmt.Errorf("invalid aud")
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	ids := [3]uint64{}
+	for i, name := range []string{"documentId", "tenantId", "issuedBy"} {
+		value, _ := claims[name].(string)
+		if ids[i], err = strconv.ParseUint(value, 10, 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid %s", name)
+		}
+	}
+	return ids[0], ids[1], ids[2], nil
+}
+
+//For getting a link that downloads a document without a token until it expires, to hand to
+//a browser or another system. Takes ttl_minutes, 15 by default and at most a day. Links stop
+//working when the document is deleted or the caller is suspended or terminated
+func CreateLink(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	link := LinkRequestModel{}
+	if err := json.NewDecoder(r.Body).Decode(&link); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if link.TtlMinutes == 0 {
+		link.TtlMinutes = defaultLinkTtl
+	}
+	if link.TtlMinutes < 0 || link.TtlMinutes > maxLinkTtl {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "ttl_minutes must be between 1 and " + strconv.Itoa(maxLinkTtl),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	d, ok := documentParam(w, r, db)
+	if !ok || !checkCategoryPrivilege(w, r, d.readPrivilege, "read") {
+		return
+	}
+	expiresAt := time.Now().Add(time.Duration(link.TtlMinutes) * time.Minute)
+	token, err := signLink(d, middleware.TenantId(r), principal.UserId, expiresAt)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return the link object
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(LinkModel{Url: "/document-downloads/" + token, ExpiresAt: expiresAt.UTC()})
+}
+
+//For downloading a document through a link from CreateLink. The link is all it takes
+func DownloadDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection, which loads the jwt secret from .env
+	db := db.ConnectDB()
+	defer db.Close()
+	documentId, tenantId, issuedBy, err := parseLink(mux.Vars(r)["token"])
+	invalid := err != nil
+	var status string
+	if !invalid {
+		err = db.QueryRow(`SELECT status FROM users WHERE user_id = $1`, issuedBy).Scan(&status)
+		invalid = err == sql.ErrNoRows || (err == nil && middleware.StatusLocked(status))
+	}
+	d := storedDocument{}
+	if !invalid && err == nil {
+		row := db.QueryRow(selectDocument+` WHERE d.document_id = $1 AND d.tenant_id = $2`, documentId, tenantId)
+		err = scanDocument(row, &d)
+		invalid = err == sql.ErrNoRows
+	}
+	if invalid {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Download link is invalid or has expired",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	serveContent(w, d)
+}
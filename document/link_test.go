@@ -0,0 +1,65 @@
+package document
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func TestLinkRoundTrip(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	d := storedDocument{DocumentModel: DocumentModel{DocumentId: 7}}
+	link, err := signLink(d, 3, 5, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	documentId, tenantId, issuedBy, err := parseLink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if documentId != 7 || tenantId != 3 || issuedBy != 5 {
+		t.Errorf("parseLink = %d, %d, %d, want 7, 3, 5", documentId, tenantId, issuedBy)
+	}
+	//Signed with another secret
+	os.Setenv("JWT_SECRET", "other-secret")
+	if _, _, _, err := parseLink(link); err == nil {
+		t.Error("parseLink accepted a link signed with another secret")
+	}
+}
+
+func TestLinkExpired(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	d := storedDocument{DocumentModel: DocumentModel{DocumentId: 7}}
+	link, err := signLink(d, 3, 5, time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := parseLink(link); err == nil {
+		t.Error("parseLink accepted an expired link")
+	}
+}
+
+func TestLinkNotLoginToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	//A login token carries no audience, a link another one
+	for _, aud := range []interface{}{nil, "hrm"} {
+		token := jwt.New(jwt.SigningMethodHS256)
+		claims := token.Claims.(jwt.MapClaims)
+		if aud != nil {
+			claims["aud"] = aud
+		}
+		claims["documentId"] = "7"
+		claims["tenantId"] = "3"
+		claims["issuedBy"] = "5"
+		claims["exp"] = time.Now().Add(time.Minute).Unix()
+		signed, err := token.SignedString([]byte("test-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := parseLink(signed); err == nil {
+			t.Errorf("parseLink accepted a token with aud %v", aud)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package document
+
+import "time"
+
+//A kind of employee document. allowed_types are media types such as application/pdf, matched
+//against the type sniffed from the content. read_privilege and write_privilege are needed on
+//top of the route's privilege when set
+type CategoryModel struct {
+	CategoryId     uint64    `json:"id"`
+	CategoryCode   string    `json:"category_code"`
+	CategoryName   string    `json:"category_name"`
+	AllowedTypes   []string  `json:"allowed_types"`
+	MaxBytes       int64     `json:"max_bytes"`
+	ReadPrivilege  *string   `json:"read_privilege"`
+	WritePrivilege *string   `json:"write_privilege"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+//A document attached to an employee record. content_type is the sniffed type and sha256 the
+//hex digest of the content. expires_on is YYYY-MM-DD
+type DocumentModel struct {
+	DocumentId   uint64    `json:"id"`
+	UserId       uint64    `json:"user_id"`
+	CategoryId   uint64    `json:"category_id"`
+	CategoryCode string    `json:"category_code"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Sha256       string    `json:"sha256"`
+	ExpiresOn    *string   `json:"expires_on"`
+	UploadedBy   *uint64   `json:"uploaded_by"`
+	UploadedAt   time.Time `json:"uploaded_at"`
+}
+
+//Asks for a download link valid for ttl_minutes, 15 when left out
+type LinkRequestModel struct {
+	TtlMinutes int `json:"ttl_minutes"`
+}
+
+//A download link. url is the path to GET, without the host, and works without a token until
+//expires_at
+type LinkModel struct {
+	Url       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,15 @@
+package document
+
+import "hrm/catalog"
+
+//Privileges required by the document routes
+var (
+	PrivManageCategories  = catalog.Declare("manage_document_categories", "Create, edit and delete document categories")
+	PrivReadCategories    = catalog.Declare("read_document_categories", "List document categories")
+	PrivUploadDocuments   = catalog.Declare("upload_documents", "Attach documents to any employee record")
+	PrivUploadOwnDocument = catalog.Declare("upload_own_documents", "Attach documents to the caller's own employee record")
+	PrivReadAllDocuments  = catalog.Declare("read_all_documents", "Read the documents of any employee")
+	PrivReadDeptDocuments = catalog.Declare("read_department_documents", "Read the documents of employees in the caller's department or below it")
+	PrivReadOwnDocuments  = catalog.Declare("read_own_documents", "Read the documents of the caller's own employee record")
+	PrivDeleteDocuments   = catalog.Declare("delete_documents", "Delete documents from employee records")
+)
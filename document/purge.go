@@ -0,0 +1,72 @@
+package document
+
+import (
+	"database/sql"
+	"fmt"
+	"hrm/db"
+	"log"
+	"time"
+)
+
+//Removes the content under key from the store and takes it off the purge queue
+func purge(db *sql.DB, key string) error {
+	store, err := OpenStore()
+	if err == nil {
+		err = store.Delete(key)
+	}
+	if err == nil {
+		_, err = db.Exec(`DELETE FROM document_purges WHERE storage_key = $1`, key)
+	}
+	if err != nil {
+		return fmt.Errorf("purging %s: %v", key, err)
+	}
+	return nil
+}
+
+//Removes the contents of deleted documents from the store every interval, including those
+//of employee records and users that were deleted. Meant to run in its own goroutine for the
+//life of the server
+func PurgeDocuments(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		db := db.ConnectDB()
+		count, err := purgeQueued(db)
+		db.Close()
+		if err != nil {
+			log.Printf("documents: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("documents: purged %d deleted documents", count)
+		}
+	}
+}
+
+//Purges the queued keys, the oldest first. Stops at the first one that fails, it is retried
+//on the next run
+func purgeQueued(db *sql.DB) (int, error) {
+	rows, err := db.Query(`SELECT storage_key FROM document_purges ORDER BY queued_at LIMIT 1000`)
+	if err != nil {
+		return 0, err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for i, key := range keys {
+		if err := purge(db, key); err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
+}
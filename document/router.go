@@ -0,0 +1,56 @@
+package document
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleDocumentRoutes(r *mux.Router) {
+	//Endpoint for creating a document category
+	r.HandleFunc("/document-categories",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCategories, AddCategory))).Methods("POST")
+
+	//Endpoint for fetching all document categories
+	r.HandleFunc("/document-categories",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadCategories, GetCategories))).Methods("GET")
+
+	//Endpoint for editing a document category
+	r.HandleFunc("/document-categories/{category_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCategories, EditCategory))).Methods("PUT")
+
+	//Endpoint for deleting a document category
+	r.HandleFunc("/document-categories/{category_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCategories, DeleteCategory))).Methods("DELETE")
+
+	//Endpoint for attaching a document to an employee record
+	r.HandleFunc("/employees/{user_id}/documents",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivUploadOwnDocument, Any: PrivUploadDocuments,
+		}, UploadDocument))).Methods("POST")
+
+	//Endpoint for fetching the documents of an employee
+	r.HandleFunc("/employees/{user_id}/documents",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivReadOwnDocuments, Department: PrivReadDeptDocuments, Any: PrivReadAllDocuments,
+		}, GetDocuments))).Methods("GET")
+
+	//Endpoint for downloading a document
+	r.HandleFunc("/employees/{user_id}/documents/{document_id}/content",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivReadOwnDocuments, Department: PrivReadDeptDocuments, Any: PrivReadAllDocuments,
+		}, GetDocumentContent))).Methods("GET")
+
+	//Endpoint for getting an expiring download link to a document
+	r.HandleFunc("/employees/{user_id}/documents/{document_id}/link",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivReadOwnDocuments, Department: PrivReadDeptDocuments, Any: PrivReadAllDocuments,
+		}, CreateLink))).Methods("POST")
+
+	//Endpoint for deleting a document
+	r.HandleFunc("/employees/{user_id}/documents/{document_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDeleteDocuments, DeleteDocument))).Methods("DELETE")
+
+	//Endpoint for downloading a document through a link. The link stands in for the token
+	r.HandleFunc("/document-downloads/{token}", DownloadDocument).Methods("GET")
+}
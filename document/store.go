@@ -0,0 +1,117 @@
+package document
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//Where the contents of documents are kept, by storage key. Keys are made of letters, digits
+//and '/' and never start with one
+type Store interface {
+	//Stores size bytes of body under key, replacing whatever was there
+	Put(key string, body io.Reader, size int64, contentType string) error
+	//Returns the content under key, or ErrNotFound. The caller closes it
+	Get(key string) (io.ReadCloser, error)
+	//Removes the content under key. Removing a missing key is not an error
+	Delete(key string) error
+}
+
+var ErrNotFound = errors.New("document content not found")
+
+//Opens the store picked by DOCUMENT_STORE: local, the default, keeps contents under the
+//DOCUMENT_DIR directory, s3 in the S3_BUCKET bucket of the S3-compatible service at S3_ENDPOINT
+func OpenStore() (Store, error) {
+	switch kind := os.Getenv("DOCUMENT_STORE"); kind {
+	case "", "local":
+		root := os.Getenv("DOCUMENT_DIR")
+		if root == "" {
+			root = "documents"
+		}
+		return LocalStore{Root: root}, nil
+	case "s3":
+		s := S3Store{
+			Endpoint:  strings.TrimSuffix(os.Getenv("S3_ENDPOINT"), "/"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY are required for the s3 document store")
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown DOCUMENT_STORE %q, must be local or s3", kind)
+	}
+}
+
+//Keeps contents as files below Root, one per key
+type LocalStore struct {
+	Root string
+}
+
+func (s LocalStore) path(key string) (string, error) {
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid storage key %q", key)
+		}
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
+
+//Writes to a temporary file first so a failed upload never leaves half a file under key
+func (s LocalStore) Put(key string, body io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	written, err := io.Copy(tmp, body)
+	if err == nil && written != size {
+		err = fmt.Errorf("wrote %d bytes of %d", written, size)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s LocalStore) Get(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (s LocalStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
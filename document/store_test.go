@@ -0,0 +1,55 @@
+package document
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoreKeys(t *testing.T) {
+	s := LocalStore{Root: t.TempDir()}
+	for _, key := range []string{"", "/1/2/abc", "1/2/", "1//abc", "1/../abc", "../abc", "1/./abc", ".."} {
+		if err := s.Put(key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) stored content under an invalid key", key)
+		}
+		if _, err := s.Get(key); err == nil || err == ErrNotFound {
+			t.Errorf("Get(%q) = %v, want an invalid key error", key, err)
+		}
+		if err := s.Delete(key); err == nil {
+			t.Errorf("Delete(%q) accepted an invalid key", key)
+		}
+	}
+}
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	s := LocalStore{Root: t.TempDir()}
+	key := "1/2/abc"
+	if _, err := s.Get(key); err != ErrNotFound {
+		t.Fatalf("Get before Put = %v, want ErrNotFound", err)
+	}
+	if err := s.Put(key, strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	//A short body must not replace what is stored
+	if err := s.Put(key, strings.NewReader("hi"), 5, "text/plain"); err == nil {
+		t.Error("Put with fewer bytes than size succeeded")
+	}
+	content, err := s.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(content)
+	content.Close()
+	if string(body) != "hello" {
+		t.Errorf("Get = %q, want %q", body, "hello")
+	}
+	if err := s.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(key); err != nil {
+		t.Errorf("Delete of a missing key = %v", err)
+	}
+	if _, err := s.Get(key); err != ErrNotFound {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,436 @@
+package elevation
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const selectActivation = `SELECT a.activation_id, a.user_id, u.username, a.role_id, r.role_name, a.reason,
+		a.minutes, a.status, COALESCE(a.decided_by, 0), a.created_at, a.activated_at, a.expires_at, a.ended_at
+	FROM role_activations a JOIN users u ON u.user_id = a.user_id JOIN roles r ON r.role_id = a.role_id`
+
+func queryActivations(db *sql.DB, stmt string, args ...interface{}) ([]ActivationModel, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	activations := []ActivationModel{}
+	for rows.Next() {
+		a := ActivationModel{}
+		err := rows.Scan(&a.ActivationId, &a.UserId, &a.Username, &a.RoleId, &a.RoleName, &a.Reason,
+			&a.Minutes, &a.Status, &a.DecidedBy, &a.CreatedAt, &a.ActivatedAt, &a.ExpiresAt, &a.EndedAt)
+		if err != nil {
+			return nil, err
+		}
+		activations = append(activations, a)
+	}
+	return activations, rows.Err()
+}
+
+//Returns the first dynamic separation-of-duties violation of userId, "" if there is none.
+//Checked before an activation goes live, as loadPrivileges would drop the privileges of the
+//conflicting roles, the one just activated included
+func dynamicViolation(tx *sql.Tx, userId uint64) (string, error) {
+	violations, err := middleware.SoDViolations(tx, "dynamic", []uint64{userId})
+	if err != nil || len(violations) == 0 {
+		return "", err
+	}
+	return "Separation of duties: " + violations[0].String(), nil
+}
+
+//For activating a role the caller is eligible for. Takes role_name, reason and minutes,
+//which defaults to and may not exceed the eligibility's max_minutes. The role is in force
+//at once unless the eligibility requires approval
+func ActivateRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	activation := ActivationModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&activation); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if activation.Reason == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "A reason is required",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	if principal.Impersonated() {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Roles cannot be activated under impersonation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	var eligibilityId uint64
+	var maxMinutes int
+	var requiresApproval bool
+	stmt := `SELECT e.eligibility_id, e.role_id, e.max_minutes, e.requires_approval
+		FROM role_eligibilities e JOIN roles r ON r.role_id = e.role_id
+		WHERE e.user_id = $1 AND r.role_name = $2`
+	err := db.QueryRow(stmt, principal.UserId, activation.RoleName).Scan(&eligibilityId, &activation.RoleId,
+		&maxMinutes, &requiresApproval)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You are not eligible for this role",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if activation.Minutes == 0 {
+		activation.Minutes = maxMinutes
+	}
+	if activation.Minutes < 0 || activation.Minutes > maxMinutes {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "minutes must be between 1 and " + strconv.Itoa(maxMinutes),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	activation.Status = "active"
+	if requiresApproval {
+		activation.Status = "pending"
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	if requiresApproval {
+		stmt = `INSERT INTO role_activations(eligibility_id, user_id, role_id, reason, minutes, status, tenant_id)
+			VALUES ($1, $2, $3, $4, $5, 'pending', $6) RETURNING activation_id`
+	} else {
+		stmt = `INSERT INTO role_activations(eligibility_id, user_id, role_id, reason, minutes, status, activated_at, expires_at,
+				tenant_id)
+			VALUES ($1, $2, $3, $4, $5, 'active', NOW(), NOW() + make_interval(mins => $5), $6) RETURNING activation_id`
+	}
+	err = tx.QueryRow(stmt, eligibilityId, principal.UserId, activation.RoleId, activation.Reason,
+		activation.Minutes, principal.HomeTenantId).Scan(&activation.ActivationId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		//One open activation per user and role
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "This role already has an open activation",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	msg := ""
+	if err == nil && activation.Status == "active" {
+		msg, err = dynamicViolation(tx, principal.UserId)
+	}
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Activation " + strconv.FormatUint(activation.ActivationId, 10) + " is " + activation.Status,
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all activations, newest first
+func GetActivations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	status := r.URL.Query().Get("status")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectActivation + ` WHERE ($1 = '' OR a.status = $1) AND a.tenant_id = $2 ORDER BY a.activation_id DESC`
+	data, err := queryActivations(db, stmt, status, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of activation objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the caller's own activations, newest first
+func GetMyActivations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectActivation + ` WHERE a.user_id = $1 ORDER BY a.activation_id DESC`
+	data, err := queryActivations(db, stmt, principal.UserId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of activation objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For approving a pending activation. Its time starts running at approval
+func ApproveActivation(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "active")
+}
+
+//For rejecting a pending activation
+func RejectActivation(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "rejected")
+}
+
+//Moves a pending activation to status. Nobody decides their own activation
+func decide(w http.ResponseWriter, r *http.Request, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract activation id from req params
+	params := mux.Vars(r)
+	activationId, err := strconv.Atoi(params["activation_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	if principal.Impersonated() {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Activations cannot be decided under impersonation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	activation := ActivationModel{ActivationId: uint64(activationId)}
+	stmt := `SELECT user_id, status FROM role_activations WHERE activation_id = $1 AND tenant_id = $2 FOR UPDATE`
+	err = tx.QueryRow(stmt, activation.ActivationId, principal.TenantId).Scan(&activation.UserId, &activation.Status)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Activation not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if activation.UserId == principal.UserId {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You cannot decide your own activation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if activation.Status != "pending" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Activation is already " + activation.Status,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if status == "active" {
+		stmt = `UPDATE role_activations SET status = 'active', decided_by = $2, activated_at = NOW(),
+			expires_at = NOW() + make_interval(mins => minutes) WHERE activation_id = $1`
+	} else {
+		stmt = `UPDATE role_activations SET status = 'rejected', decided_by = $2, ended_at = NOW()
+			WHERE activation_id = $1`
+	}
+	_, err = tx.Exec(stmt, activation.ActivationId, principal.UserId)
+	msg := ""
+	if err == nil && status == "active" {
+		msg, err = dynamicViolation(tx, activation.UserId)
+	}
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Activation is " + status,
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For ending the caller's own pending or active activation early
+func DeactivateRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract activation id from req params
+	params := mux.Vars(r)
+	activationId, err := strconv.Atoi(params["activation_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `UPDATE role_activations SET status = 'deactivated', ended_at = NOW()
+		WHERE activation_id = $1 AND user_id = $2 AND status IN ('pending', 'active')`
+	result, err := db.Exec(stmt, uint64(activationId), principal.UserId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Check if any row was affected in the update operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to count rows affected in update operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "No open activation of yours with this id",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Activation deactivated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
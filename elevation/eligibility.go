@@ -0,0 +1,307 @@
+package elevation
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const selectEligibility = `SELECT e.eligibility_id, e.user_id, u.username, e.role_id, r.role_name,
+		e.max_minutes, e.requires_approval, e.created_at
+	FROM role_eligibilities e JOIN users u ON u.user_id = e.user_id JOIN roles r ON r.role_id = e.role_id`
+
+func queryEligibilities(db *sql.DB, stmt string, args ...interface{}) ([]EligibilityModel, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	eligibilities := []EligibilityModel{}
+	for rows.Next() {
+		e := EligibilityModel{}
+		err := rows.Scan(&e.EligibilityId, &e.UserId, &e.Username, &e.RoleId, &e.RoleName,
+			&e.MaxMinutes, &e.RequiresApproval, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		eligibilities = append(eligibilities, e)
+	}
+	return eligibilities, rows.Err()
+}
+
+//For making a user eligible for a role. Takes user_id, role_name, max_minutes and
+//requires_approval. Eligibility counts towards static separation of duties
+func AddEligibility(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	eligibility := EligibilityModel{MaxMinutes: 60}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&eligibility); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if eligibility.MaxMinutes <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "max_minutes must be a positive integer",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT role_id FROM roles WHERE role_name = $1 AND tenant_id = $2`
+	err := db.QueryRow(stmt, eligibility.RoleName, principal.TenantId).Scan(&eligibility.RoleId)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Role not found!",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Tenant admins cannot make anyone eligible for a role carrying a platform privilege
+	err = middleware.CheckGrantableRole(db, principal.UserId, eligibility.RoleId)
+	if err, ok := err.(middleware.UngrantableError); ok {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Nothing is added if the user belongs to another tenant
+	stmt = `INSERT INTO role_eligibilities(user_id, role_id, max_minutes, requires_approval, created_by, tenant_id)
+		SELECT user_id, $2, $3, $4, $5, tenant_id FROM users WHERE user_id = $1 AND tenant_id = $6`
+	result, err := tx.Exec(stmt, eligibility.UserId, eligibility.RoleId, eligibility.MaxMinutes,
+		eligibility.RequiresApproval, principal.UserId, principal.TenantId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "User is already eligible for this role",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else if err.Code == "23503" {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "User not found",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "User not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg, err := middleware.CheckStaticSoD(tx, []uint64{eligibility.UserId})
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Eligibility added",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all eligibilities
+func GetEligibilities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	data, err := queryEligibilities(db, selectEligibility+` WHERE e.tenant_id = $1 ORDER BY e.eligibility_id`, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of eligibility objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the roles the caller can activate
+func GetMyEligibilities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	data, err := queryEligibilities(db, selectEligibility+` WHERE e.user_id = $1 ORDER BY r.role_name`, principal.UserId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of eligibility objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For removing an eligibility. Its open activations are deactivated, so an active one
+//stops counting in IsAuthorize at once
+func DeleteEligibility(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract eligibility id from req params
+	params := mux.Vars(r)
+	eligibilityId, err := strconv.Atoi(params["eligibility_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	tenantId := middleware.TenantId(r)
+	stmt := `UPDATE role_activations SET status = 'deactivated', ended_at = NOW()
+		WHERE eligibility_id = $1 AND status IN ('pending', 'active') AND tenant_id = $2`
+	_, err = tx.Exec(stmt, uint64(eligibilityId), tenantId)
+	var result sql.Result
+	if err == nil {
+		stmt = `DELETE FROM role_eligibilities WHERE eligibility_id = $1 AND tenant_id = $2`
+		result, err = tx.Exec(stmt, uint64(eligibilityId), tenantId)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Check if any row was affected by the delete operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Eligibility not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Eligibility deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,32 @@
+package elevation
+
+import "time"
+
+//A role a user may activate for at most MaxMinutes at a time
+type EligibilityModel struct {
+	EligibilityId    uint64    `json:"id"`
+	UserId           uint64    `json:"user_id"`
+	Username         string    `json:"username"`
+	RoleId           uint64    `json:"role_id"`
+	RoleName         string    `json:"role_name"`
+	MaxMinutes       int       `json:"max_minutes"`
+	RequiresApproval bool      `json:"requires_approval"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type ActivationModel struct {
+	ActivationId uint64 `json:"id"`
+	UserId       uint64 `json:"user_id"`
+	Username     string `json:"username"`
+	RoleId       uint64 `json:"role_id"`
+	RoleName     string `json:"role_name"`
+	Reason       string `json:"reason"`
+	Minutes      int    `json:"minutes"`
+	//pending, active, rejected, expired or deactivated
+	Status      string     `json:"status"`
+	DecidedBy   uint64     `json:"decided_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ActivatedAt *time.Time `json:"activated_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+}
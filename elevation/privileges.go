@@ -0,0 +1,10 @@
+package elevation
+
+import "hrm/catalog"
+
+//Privileges required by the elevation routes
+var (
+	PrivManageEligibility = catalog.Declare("manage_eligibility", "Make users eligible for roles they can activate on demand")
+	PrivActivateRole      = catalog.Declare("activate_role", "Activate a role the caller is eligible for")
+	PrivApproveElevation  = catalog.Declare("approve_elevation", "Approve or reject activations that require approval")
+)
@@ -0,0 +1,49 @@
+package elevation
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleElevationRoutes(r *mux.Router) {
+	//Endpoint for making a user eligible for a role
+	r.HandleFunc("/elevation/eligibilities",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageEligibility, AddEligibility))).Methods("POST")
+
+	//Endpoint for fetching all eligibilities
+	r.HandleFunc("/elevation/eligibilities",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageEligibility, GetEligibilities))).Methods("GET")
+
+	//Endpoint for fetching the roles the caller can activate
+	r.HandleFunc("/elevation/eligibilities/mine",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivActivateRole, GetMyEligibilities))).Methods("GET")
+
+	//Endpoint for removing an eligibility. Ends its open activations
+	r.HandleFunc("/elevation/eligibilities/{eligibility_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageEligibility, DeleteEligibility))).Methods("DELETE")
+
+	//Endpoint for activating a role the caller is eligible for
+	r.HandleFunc("/elevation/activations",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivActivateRole, ActivateRole))).Methods("POST")
+
+	//Endpoint for fetching all activations. ?status= narrows it down
+	r.HandleFunc("/elevation/activations",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivApproveElevation, GetActivations))).Methods("GET")
+
+	//Endpoint for fetching the caller's own activations
+	r.HandleFunc("/elevation/activations/mine",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivActivateRole, GetMyActivations))).Methods("GET")
+
+	//Endpoint for approving a pending activation. The role is active from then on
+	r.HandleFunc("/elevation/activations/{activation_id}/approve",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivApproveElevation, ApproveActivation))).Methods("POST")
+
+	//Endpoint for rejecting a pending activation
+	r.HandleFunc("/elevation/activations/{activation_id}/reject",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivApproveElevation, RejectActivation))).Methods("POST")
+
+	//Endpoint for ending the caller's own activation before it expires
+	r.HandleFunc("/elevation/activations/{activation_id}/deactivate",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivActivateRole, DeactivateRole))).Methods("POST")
+}
@@ -0,0 +1,440 @@
+package employee
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/group"
+	"hrm/middleware"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//department is the one of the current assignment, if any
+const selectEmployee = `SELECT e.user_id, u.username, e.employee_number, COALESCE(TO_CHAR(e.hire_date, 'YYYY-MM-DD'), ''),
+		COALESCE(e.job_title, ''), COALESCE(d.department_name, ''), COALESCE(e.location, ''),
+		COALESCE(e.employment_type, ''), COALESCE(e.work_email, ''), COALESCE(e.work_phone, ''),
+		e.manager_id, e.created_at, e.updated_at
+	FROM employees e JOIN users u ON u.user_id = e.user_id
+	LEFT JOIN employee_assignments a ON a.user_id = e.user_id
+		AND a.effective_from <= CURRENT_DATE AND (a.effective_to IS NULL OR a.effective_to >= CURRENT_DATE)
+	LEFT JOIN departments d ON d.department_id = a.department_id`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEmployee(row scanner, e *EmployeeModel) error {
+	return row.Scan(&e.UserId, &e.Username, &e.EmployeeNumber, &e.HireDate, &e.JobTitle, &e.Department,
+		&e.Location, &e.EmploymentType, &e.WorkEmail, &e.WorkPhone, &e.ManagerId, &e.CreatedAt, &e.UpdatedAt)
+}
+
+//Returns what is wrong with an employee record, or an empty string
+func validate(e EmployeeModel) string {
+	if e.EmployeeNumber == "" {
+		return "employee_number is required"
+	}
+	if e.HireDate != "" {
+		if _, err := time.Parse("2006-01-02", e.HireDate); err != nil {
+			return "hire_date must be formatted YYYY-MM-DD"
+		}
+	}
+	if e.WorkEmail != "" {
+		if _, err := mail.ParseAddress(e.WorkEmail); err != nil {
+			return "work_email is not a valid email address"
+		}
+	}
+	return ""
+}
+
+//Both the primary key and the employee number are unique
+func conflictMessage(err *pq.Error) string {
+	if err.Constraint == "emp_tnt_num_key" {
+		return "Employee number already in use"
+	}
+	return "User already has an employee record"
+}
+
+//For creating the employee record of a user. Takes user_id, employee_number and the optional
+//hire_date, job_title, location, employment_type, work_email and work_phone
+func AddEmployee(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	employee := EmployeeModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&employee); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validate(employee); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Nothing is added if the user belongs to another tenant
+	stmt := `INSERT INTO employees(user_id, tenant_id, employee_number, hire_date, job_title,
+			location, employment_type, work_email, work_phone)
+		SELECT user_id, tenant_id, $2, NULLIF($3, '')::DATE, NULLIF($4, ''), NULLIF($5, ''),
+			NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, '')
+		FROM users WHERE user_id = $1 AND tenant_id = $9`
+	result, err := tx.Exec(stmt, employee.UserId, employee.EmployeeNumber, employee.HireDate, employee.JobTitle,
+		employee.Location, employee.EmploymentType, employee.WorkEmail, employee.WorkPhone, tenantId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: conflictMessage(err),
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "User not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//The new attributes can put the user in dynamic groups
+	err = RecordChanges(tx, employee.UserId, tenantId, principal.UserId, EmployeeModel{}, employee)
+	msg := ""
+	if err == nil {
+		msg, err = group.SyncUser(tx, employee.UserId)
+	}
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Employee record created",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all employee records
+func GetEmployees(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	data, err := queryEmployees(db, selectEmployee+` WHERE e.tenant_id = $1 ORDER BY e.employee_number`, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of employee objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the employee record of a user
+func GetEmployee(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	employee := EmployeeModel{}
+	row := db.QueryRow(selectEmployee+` WHERE e.user_id = $1 AND e.tenant_id = $2`, uint64(userId), middleware.TenantId(r))
+	err = scanEmployee(row, &employee)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return employee object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(employee)
+}
+
+//For editing the employee record of a user. Every field but manager_id is replaced, the
+//changed ones are written to the history
+func EditEmployee(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	employee := EmployeeModel{}
+	if err := json.NewDecoder(r.Body).Decode(&employee); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validate(employee); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	before := EmployeeModel{}
+	row := tx.QueryRow(selectEmployee+` WHERE e.user_id = $1 AND e.tenant_id = $2 FOR UPDATE OF e`, uint64(userId), tenantId)
+	err = scanEmployee(row, &before)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		stmt := `UPDATE employees SET employee_number = $2, hire_date = NULLIF($3, '')::DATE, job_title = NULLIF($4, ''),
+			location = NULLIF($5, ''), employment_type = NULLIF($6, ''),
+			work_email = NULLIF($7, ''), work_phone = NULLIF($8, ''), updated_at = NOW()
+			WHERE user_id = $1`
+		_, err = tx.Exec(stmt, uint64(userId), employee.EmployeeNumber, employee.HireDate, employee.JobTitle,
+			employee.Location, employee.EmploymentType, employee.WorkEmail, employee.WorkPhone)
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: conflictMessage(err),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		err = RecordChanges(tx, uint64(userId), tenantId, principal.UserId, before, employee)
+	}
+	//Changed attributes can move the user in and out of dynamic groups
+	msg := ""
+	if err == nil {
+		msg, err = group.SyncUser(tx, uint64(userId))
+	}
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Employee record updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For deleting the employee record of a user. The user and the history are kept, the
+//employees reporting to them are left without a manager
+func DeleteEmployee(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	before := EmployeeModel{}
+	row := tx.QueryRow(selectEmployee+` WHERE e.user_id = $1 AND e.tenant_id = $2 FOR UPDATE OF e`, uint64(userId), tenantId)
+	err = scanEmployee(row, &before)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM employees WHERE user_id = $1`, uint64(userId))
+	}
+	if err == nil {
+		err = RecordChanges(tx, uint64(userId), tenantId, principal.UserId, before, EmployeeModel{})
+	}
+	msg := ""
+	if err == nil {
+		msg, err = group.SyncUser(tx, uint64(userId))
+	}
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Employee record deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
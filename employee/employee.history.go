@@ -0,0 +1,109 @@
+package employee
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+//The fields of a record that are tracked, keyed by their json name
+func (e EmployeeModel) fields() map[string]string {
+	return map[string]string{
+		"employee_number": e.EmployeeNumber,
+		"hire_date":       e.HireDate,
+		"job_title":       e.JobTitle,
+		"location":        e.Location,
+		"employment_type": e.EmploymentType,
+		"work_email":      e.WorkEmail,
+		"work_phone":      e.WorkPhone,
+	}
+}
+
+//Writes one history row per field that differs between before and after. An empty
+//record stands for a record that did not exist yet, or no longer does
+func RecordChanges(tx *sql.Tx, userId, tenantId, changedBy uint64, before, after EmployeeModel) error {
+	old, changed := before.fields(), after.fields()
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if old[name] == changed[name] {
+			continue
+		}
+		if err := RecordChange(tx, userId, tenantId, changedBy, name, old[name], changed[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Writes one history row. Empty values are stored as null
+func RecordChange(tx *sql.Tx, userId, tenantId, changedBy uint64, field, oldValue, newValue string) error {
+	stmt := `INSERT INTO employee_history(user_id, tenant_id, field, old_value, new_value, changed_by)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)`
+	_, err := tx.Exec(stmt, userId, tenantId, field, oldValue, newValue, changedBy)
+	return err
+}
+
+//For fetching the change history of the employee record of a user, newest first
+func GetEmployeeHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT history_id, field, old_value, new_value, changed_by, changed_at FROM employee_history
+		WHERE user_id = $1 AND tenant_id = $2 ORDER BY history_id DESC`
+	rows, err := db.Query(stmt, uint64(userId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []HistoryModel{}
+	for rows.Next() {
+		h := HistoryModel{}
+		var changedBy sql.NullInt64
+		if err := rows.Scan(&h.HistoryId, &h.Field, &h.OldValue, &h.NewValue, &changedBy, &h.ChangedAt); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if changedBy.Valid {
+			id := uint64(changedBy.Int64)
+			h.ChangedBy = &id
+		}
+		data = append(data, h)
+	}
+	//If everything went well, return array of history objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
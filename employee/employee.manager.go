@@ -0,0 +1,173 @@
+package employee
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+//Walks up the reporting line from employee $1, including $1 itself. The path stops the walk
+//at the first employee seen twice, should a loop have made it into the data
+const chainCTE = `WITH RECURSIVE chain(user_id, depth, path) AS (
+		SELECT CAST($1 AS BIGINT), 0, ARRAY[CAST($1 AS BIGINT)]
+	UNION ALL
+		SELECT e.manager_id, c.depth + 1, c.path || CAST(e.manager_id AS BIGINT)
+		FROM employees e JOIN chain c ON e.user_id = c.user_id
+		WHERE e.manager_id IS NOT NULL AND e.manager_id <> ALL(c.path)
+	)`
+
+//Everyone below employee $1 in the reporting lines. Leave and timesheets are decided by the
+//employee's manager or anyone above them, so a skip-level manager can step in
+const ReportsCTE = `WITH RECURSIVE reports(user_id) AS (
+		SELECT user_id FROM employees WHERE manager_id = $1
+	UNION
+		SELECT e.user_id FROM employees e JOIN reports r ON e.manager_id = r.user_id
+	)`
+
+//Formats a manager id for the history. No manager is stored as null
+func managerValue(id *uint64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(*id, 10)
+}
+
+//For setting who an employee reports to. A null manager_id clears it. The manager needs an
+//employee record in the same tenant and the reporting line cannot loop back to the employee
+func SetManager(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	manager := ManagerModel{}
+	if err := json.NewDecoder(r.Body).Decode(&manager); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Two changes could each pass the loop check below and close a loop together, so the
+	//manager changes of a tenant are made one at a time
+	stmt := `SELECT pg_advisory_xact_lock($1)`
+	_, err = tx.Exec(stmt, tenantId)
+	var current sql.NullInt64
+	if err == nil {
+		stmt = `SELECT manager_id FROM employees WHERE user_id = $1 AND tenant_id = $2 FOR UPDATE`
+		err = tx.QueryRow(stmt, uint64(userId), tenantId).Scan(&current)
+	}
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && manager.ManagerId != nil {
+		var found bool
+		found, err = employeeExists(tx, *manager.ManagerId, tenantId)
+		if err == nil && !found {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Manager not found",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		//Walk up from the new manager, the employee must not be found on the way
+		var loops bool
+		if err == nil {
+			stmt = chainCTE + ` SELECT EXISTS(SELECT 1 FROM chain WHERE user_id = $2)`
+			err = tx.QueryRow(stmt, *manager.ManagerId, uint64(userId)).Scan(&loops)
+		}
+		if loops {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "An employee cannot report to themselves or to someone reporting to them",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	if err == nil {
+		stmt = `UPDATE employees SET manager_id = $2, updated_at = NOW() WHERE user_id = $1`
+		_, err = tx.Exec(stmt, uint64(userId), manager.ManagerId)
+	}
+	if err == nil {
+		var before *uint64
+		if current.Valid {
+			id := uint64(current.Int64)
+			before = &id
+		}
+		if old, changed := managerValue(before), managerValue(manager.ManagerId); old != changed {
+			err = RecordChange(tx, uint64(userId), tenantId, principal.UserId, "manager_id", old, changed)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Manager updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//Implemented by both *sql.DB and *sql.Tx
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func employeeExists(q rowQueryer, userId, tenantId uint64) (bool, error) {
+	var found bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM employees WHERE user_id = $1 AND tenant_id = $2)`
+	err := q.QueryRow(stmt, userId, tenantId).Scan(&found)
+	return found, err
+}
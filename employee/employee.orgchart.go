@@ -0,0 +1,263 @@
+package employee
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+//Walks down the reporting lines from employee $1, including $1 itself. Like chainCTE, the
+//path keeps a loop in the data from walking forever
+const subtreeCTE = `WITH RECURSIVE subtree(user_id, depth, path) AS (
+		SELECT CAST($1 AS BIGINT), 0, ARRAY[CAST($1 AS BIGINT)]
+	UNION ALL
+		SELECT e.user_id, s.depth + 1, s.path || CAST(e.user_id AS BIGINT)
+		FROM employees e JOIN subtree s ON e.manager_id = s.user_id
+		WHERE e.user_id <> ALL(s.path)
+	)`
+
+func queryEmployees(db *sql.DB, stmt string, args ...interface{}) ([]EmployeeModel, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	employees := []EmployeeModel{}
+	for rows.Next() {
+		employee := EmployeeModel{}
+		if err := scanEmployee(rows, &employee); err != nil {
+			return nil, err
+		}
+		employees = append(employees, employee)
+	}
+	return employees, rows.Err()
+}
+
+//Serves the employees stmt returns for the employee in the user_id req param. stmt takes
+//the user id as $1 and the tenant as $2
+func serveReportingLine(w http.ResponseWriter, r *http.Request, stmt string) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	found, err := employeeExists(db, uint64(userId), tenantId)
+	if err == nil && !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	data := []EmployeeModel{}
+	if err == nil {
+		data, err = queryEmployees(db, stmt, uint64(userId), tenantId)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of employee objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the employees reporting directly to an employee
+func GetDirectReports(w http.ResponseWriter, r *http.Request) {
+	serveReportingLine(w, r, selectEmployee+` WHERE e.manager_id = $1 AND e.tenant_id = $2 ORDER BY u.username`)
+}
+
+//For fetching the managers above an employee, the direct manager first
+func GetReportingChain(w http.ResponseWriter, r *http.Request) {
+	serveReportingLine(w, r, chainCTE+` `+selectEmployee+` JOIN chain c ON c.user_id = e.user_id
+		WHERE c.depth > 0 AND e.tenant_id = $2 ORDER BY c.depth`)
+}
+
+//For fetching everyone below an employee, level by level
+func GetReportingSubtree(w http.ResponseWriter, r *http.Request) {
+	serveReportingLine(w, r, subtreeCTE+` `+selectEmployee+` JOIN subtree s ON s.user_id = e.user_id
+		WHERE s.depth > 0 AND e.tenant_id = $2 ORDER BY s.depth, u.username`)
+}
+
+type orgRow struct {
+	node      OrgNodeModel
+	managerId sql.NullInt64
+}
+
+//Nests the rows under their managers. The roots are the rows whose manager is not among them
+func buildOrgChart(rows []orgRow) []OrgNodeModel {
+	present := map[uint64]bool{}
+	for _, row := range rows {
+		present[row.node.UserId] = true
+	}
+	reports := map[uint64][]orgRow{}
+	var roots []orgRow
+	for _, row := range rows {
+		if row.managerId.Valid && present[uint64(row.managerId.Int64)] {
+			manager := uint64(row.managerId.Int64)
+			reports[manager] = append(reports[manager], row)
+		} else {
+			roots = append(roots, row)
+		}
+	}
+	var nest func(row orgRow) OrgNodeModel
+	nest = func(row orgRow) OrgNodeModel {
+		node := row.node
+		node.Reports = []OrgNodeModel{}
+		for _, report := range reports[node.UserId] {
+			node.Reports = append(node.Reports, nest(report))
+		}
+		return node
+	}
+	chart := []OrgNodeModel{}
+	for _, root := range roots {
+		chart = append(chart, nest(root))
+	}
+	return chart
+}
+
+//Escapes a string for a quoted Graphviz label
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+//Renders the org chart as a Graphviz digraph with an edge from every manager to each report
+func writeDot(b *strings.Builder, nodes []OrgNodeModel) {
+	for _, node := range nodes {
+		label := node.Username
+		if node.JobTitle != "" {
+			label += "\n" + node.JobTitle
+		}
+		fmt.Fprintf(b, "\t%d [label=%s];\n", node.UserId, dotQuote(label))
+		for _, report := range node.Reports {
+			fmt.Fprintf(b, "\t%d -> %d;\n", node.UserId, report.UserId)
+		}
+		writeDot(b, node.Reports)
+	}
+}
+
+//For exporting the org chart. ?root= limits it to an employee and everyone below them,
+//?format=dot returns a Graphviz digraph instead of nested json
+func GetOrgChart(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "dot" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "format must be json or dot",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	const selectNode = `SELECT e.user_id, u.username, COALESCE(e.job_title, ''), COALESCE(d.department_name, ''), e.manager_id
+		FROM employees e JOIN users u ON u.user_id = e.user_id
+		LEFT JOIN employee_assignments a ON a.user_id = e.user_id
+			AND a.effective_from <= CURRENT_DATE AND (a.effective_to IS NULL OR a.effective_to >= CURRENT_DATE)
+		LEFT JOIN departments d ON d.department_id = a.department_id`
+	stmt := selectNode + ` WHERE e.tenant_id = $1 ORDER BY u.username`
+	args := []interface{}{tenantId}
+	if root := r.URL.Query().Get("root"); root != "" {
+		rootId, err := strconv.ParseUint(root, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "root must be a user id",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		stmt = subtreeCTE + ` ` + selectNode + ` JOIN subtree s ON s.user_id = e.user_id
+			WHERE e.tenant_id = $2 ORDER BY u.username`
+		args = []interface{}{rootId, tenantId}
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	var orgRows []orgRow
+	for rows.Next() {
+		row := orgRow{}
+		err := rows.Scan(&row.node.UserId, &row.node.Username, &row.node.JobTitle, &row.node.Department, &row.managerId)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		orgRows = append(orgRows, row)
+	}
+	if err := rows.Err(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if len(orgRows) == 0 && len(args) > 1 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	chart := buildOrgChart(orgRows)
+	if format == "dot" {
+		var b strings.Builder
+		b.WriteString("digraph orgchart {\n\tnode [shape=box];\n")
+		writeDot(&b, chart)
+		b.WriteString("}\n")
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(b.String()))
+		return
+	}
+	//If everything went well, return the nested org chart
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(chart)
+}
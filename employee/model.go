@@ -0,0 +1,46 @@
+package employee
+
+import "time"
+
+//The HR record of a user. hire_date is YYYY-MM-DD, empty fields are not set. manager_id
+//is only changed through the manager endpoint, department is read from the current
+//department assignment
+type EmployeeModel struct {
+	UserId         uint64    `json:"user_id"`
+	Username       string    `json:"username"`
+	EmployeeNumber string    `json:"employee_number"`
+	HireDate       string    `json:"hire_date"`
+	JobTitle       string    `json:"job_title"`
+	Department     string    `json:"department"`
+	Location       string    `json:"location"`
+	EmploymentType string    `json:"employment_type"`
+	WorkEmail      string    `json:"work_email"`
+	WorkPhone      string    `json:"work_phone"`
+	ManagerId      *uint64   `json:"manager_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+//A change to one field of an employee record. old_value is null when the field was set
+//for the first time, new_value when it was cleared
+type HistoryModel struct {
+	HistoryId uint64    `json:"id"`
+	Field     string    `json:"field"`
+	OldValue  *string   `json:"old_value"`
+	NewValue  *string   `json:"new_value"`
+	ChangedBy *uint64   `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type ManagerModel struct {
+	ManagerId *uint64 `json:"manager_id"`
+}
+
+//An employee with everyone reporting to them, for the org chart
+type OrgNodeModel struct {
+	UserId     uint64         `json:"user_id"`
+	Username   string         `json:"username"`
+	JobTitle   string         `json:"job_title"`
+	Department string         `json:"department"`
+	Reports    []OrgNodeModel `json:"reports"`
+}
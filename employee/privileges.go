@@ -0,0 +1,16 @@
+package employee
+
+import "hrm/catalog"
+
+//Privileges required by the employee routes
+var (
+	PrivCreateEmployee      = catalog.Declare("create_employee", "Create the employee record of a user")
+	PrivReadAllEmployees    = catalog.Declare("read_all_employees", "List all employee records")
+	PrivReadOneEmployee     = catalog.Declare("read_one_employee", "Read any employee record")
+	PrivReadOwnEmployee     = catalog.Declare("read_own_employee", "Read the caller's own employee record")
+	PrivReadDeptEmployees   = catalog.Declare("read_department_employees", "Read employee records in the caller's department or below it")
+	PrivModifyEmployee      = catalog.Declare("modify_employee", "Edit an employee record")
+	PrivDeleteEmployee      = catalog.Declare("delete_employee", "Delete an employee record")
+	PrivReadEmployeeHistory = catalog.Declare("read_employee_history", "Read the change history of employee records")
+	PrivReadOrgChart        = catalog.Declare("read_org_chart", "Read reporting lines and export the org chart")
+)
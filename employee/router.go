@@ -0,0 +1,55 @@
+package employee
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleEmployeeRoutes(r *mux.Router) {
+	//Endpoint for creating the employee record of a user
+	r.HandleFunc("/employees",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivCreateEmployee, AddEmployee))).Methods("POST")
+
+	//Endpoint for fetching all employee records
+	r.HandleFunc("/employees",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadAllEmployees, GetEmployees))).Methods("GET")
+
+	//Endpoint for fetching the employee record of a user
+	r.HandleFunc("/employees/{user_id}",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivReadOwnEmployee, Department: PrivReadDeptEmployees, Any: PrivReadOneEmployee,
+		}, GetEmployee))).Methods("GET")
+
+	//Endpoint for editing the employee record of a user
+	r.HandleFunc("/employees/{user_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyEmployee, EditEmployee))).Methods("PUT")
+
+	//Endpoint for deleting the employee record of a user. The user is kept
+	r.HandleFunc("/employees/{user_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDeleteEmployee, DeleteEmployee))).Methods("DELETE")
+
+	//Endpoint for fetching the change history of an employee record
+	r.HandleFunc("/employees/{user_id}/history",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadEmployeeHistory, GetEmployeeHistory))).Methods("GET")
+
+	//Endpoint for setting who an employee reports to
+	r.HandleFunc("/employees/{user_id}/manager",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyEmployee, SetManager))).Methods("PUT")
+
+	//Endpoint for fetching the direct reports of an employee
+	r.HandleFunc("/employees/{user_id}/reports",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadOrgChart, GetDirectReports))).Methods("GET")
+
+	//Endpoint for fetching the managers above an employee
+	r.HandleFunc("/employees/{user_id}/chain",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadOrgChart, GetReportingChain))).Methods("GET")
+
+	//Endpoint for fetching everyone below an employee
+	r.HandleFunc("/employees/{user_id}/subtree",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadOrgChart, GetReportingSubtree))).Methods("GET")
+
+	//Endpoint for exporting the org chart as json or Graphviz dot
+	r.HandleFunc("/orgchart",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadOrgChart, GetOrgChart))).Methods("GET")
+}
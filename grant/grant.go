@@ -0,0 +1,118 @@
+package grant
+
+import (
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+)
+
+//For fetching grants that expire within the next ?days= days (30 by default), soonest first
+func GetExpiringGrants(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	data := []GrantModel{}
+	days := 30
+	if q := r.URL.Query().Get("days"); q != "" {
+		d, err := strconv.Atoi(q)
+		if err != nil || d < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "days must be a positive integer",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		days = d
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT 'user_role', u.user_id, u.username, r.role_id, r.role_name, u.role_valid_from, u.role_valid_until
+		FROM users u JOIN roles r ON r.role_id = u.role_id
+		WHERE u.role_valid_until > NOW() AND u.role_valid_until <= NOW() + make_interval(days => $1)
+		AND u.tenant_id = $2
+	UNION ALL
+		SELECT 'role_privilege', r.role_id, r.role_name, p.privilege_id, p.privilege_name, rp.valid_from, rp.valid_until
+		FROM role_privileges rp JOIN roles r ON r.role_id = rp.role_id
+		JOIN privileges p ON p.privilege_id = rp.privilege_id
+		WHERE rp.valid_until > NOW() AND rp.valid_until <= NOW() + make_interval(days => $1)
+		AND rp.tenant_id = $2
+	UNION ALL
+		SELECT 'group_role', g.group_id, g.group_name, r.role_id, r.role_name, gr.valid_from, gr.valid_until
+		FROM group_roles gr JOIN groups g ON g.group_id = gr.group_id
+		JOIN roles r ON r.role_id = gr.role_id
+		WHERE gr.valid_until > NOW() AND gr.valid_until <= NOW() + make_interval(days => $1)
+		AND gr.tenant_id = $2
+	ORDER BY 7`
+	rows, err := db.Query(stmt, days, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		grant := GrantModel{}
+		err := rows.Scan(&grant.GrantType, &grant.SubjectId, &grant.SubjectName,
+			&grant.ObjectId, &grant.ObjectName, &grant.ValidFrom, &grant.ValidUntil)
+		if err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Unable to scan grant result set" + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, grant)
+	}
+	//If everything went well, return array of grants
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the most recent grant events, newest first
+func GetGrantEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	data := []GrantEventModel{}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT event_id, event_type, grant_type, subject_id, object_id, valid_until, created_at
+		FROM grant_events WHERE tenant_id = $1 ORDER BY event_id DESC LIMIT 500`
+	rows, err := db.Query(stmt, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		event := GrantEventModel{}
+		err := rows.Scan(&event.EventId, &event.EventType, &event.GrantType, &event.SubjectId,
+			&event.ObjectId, &event.ValidUntil, &event.CreatedAt)
+		if err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Unable to scan grant event result set" + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, event)
+	}
+	//If everything went well, return array of events
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
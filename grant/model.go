@@ -0,0 +1,25 @@
+package grant
+
+import "time"
+
+//A role or privilege grant limited to a validity window
+type GrantModel struct {
+	//user_role, role_privilege or group_role
+	GrantType   string     `json:"grant_type"`
+	SubjectId   uint64     `json:"subject_id"`
+	SubjectName string     `json:"subject_name"`
+	ObjectId    uint64     `json:"object_id"`
+	ObjectName  string     `json:"object_name"`
+	ValidFrom   *time.Time `json:"valid_from,omitempty"`
+	ValidUntil  time.Time  `json:"valid_until"`
+}
+
+type GrantEventModel struct {
+	EventId    uint64     `json:"id"`
+	EventType  string     `json:"event_type"`
+	GrantType  string     `json:"grant_type"`
+	SubjectId  uint64     `json:"subject_id"`
+	ObjectId   uint64     `json:"object_id"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,8 @@
+package grant
+
+import "hrm/catalog"
+
+//Privileges required by the grant routes
+var (
+	PrivReadGrants = catalog.Declare("read_grants", "List expiring grants and expiry events")
+)
@@ -0,0 +1,17 @@
+package grant
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleGrantRoutes(r *mux.Router) {
+	//Endpoint for listing grants that are about to expire
+	r.HandleFunc("/grants/expiring",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadGrants, GetExpiringGrants))).Methods("GET")
+
+	//Endpoint for listing grants revoked by the sweeper
+	r.HandleFunc("/grants/events",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadGrants, GetGrantEvents))).Methods("GET")
+}
@@ -0,0 +1,89 @@
+package grant
+
+import (
+	"database/sql"
+	"hrm/db"
+	"log"
+	"time"
+)
+
+//Each statement removes the expired grants of one type and returns
+//subject_id, object_id, valid_until and tenant_id of every grant it removed
+var expiredGrants = map[string]string{
+	"user_role": `WITH expired AS (
+			SELECT user_id, role_id, role_valid_until, tenant_id FROM users
+			WHERE role_id IS NOT NULL AND role_valid_until <= NOW() FOR UPDATE
+		)
+		UPDATE users u SET role_id = NULL, role_valid_from = NULL, role_valid_until = NULL
+		FROM expired e WHERE u.user_id = e.user_id
+		RETURNING e.user_id, e.role_id, e.role_valid_until, e.tenant_id`,
+	"role_privilege": `DELETE FROM role_privileges WHERE valid_until <= NOW()
+		RETURNING role_id, privilege_id, valid_until, tenant_id`,
+	"group_role": `DELETE FROM group_roles WHERE valid_until <= NOW()
+		RETURNING group_id, role_id, valid_until, tenant_id`,
+	//The resolver already ignores expired elevations, this only closes them
+	"role_activation": `UPDATE role_activations SET status = 'expired', ended_at = NOW()
+		WHERE status = 'active' AND expires_at <= NOW()
+		RETURNING user_id, role_id, expires_at, tenant_id`,
+	"group_membership": `DELETE FROM group_members WHERE expires_at <= NOW()
+		RETURNING user_id, group_id, expires_at, tenant_id`,
+}
+
+//Revokes expired grants every interval. Meant to run in its own goroutine for the life of the server
+func SweepExpiredGrants(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		db := db.ConnectDB()
+		count, err := sweep(db)
+		db.Close()
+		if err != nil {
+			log.Printf("grant sweeper: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("grant sweeper: revoked %d expired grants", count)
+		}
+	}
+}
+
+//Revokes all expired grants in one transaction and records an event for each
+func sweep(db *sql.DB) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	type revoked struct {
+		grantType                     string
+		subjectId, objectId, tenantId uint64
+		validUntil                    time.Time
+	}
+	var events []revoked
+	for grantType, stmt := range expiredGrants {
+		rows, err := tx.Query(stmt)
+		if err != nil {
+			return 0, err
+		}
+		for rows.Next() {
+			e := revoked{grantType: grantType}
+			if err := rows.Scan(&e.subjectId, &e.objectId, &e.validUntil, &e.tenantId); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			events = append(events, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+	}
+	stmt := `INSERT INTO grant_events(event_type, grant_type, subject_id, object_id, valid_until, tenant_id)
+		VALUES ('expired', $1, $2, $3, $4, $5)`
+	for _, e := range events {
+		if _, err := tx.Exec(stmt, e.grantType, e.subjectId, e.objectId, e.validUntil, e.tenantId); err != nil {
+			return 0, err
+		}
+	}
+	return len(events), tx.Commit()
+}
@@ -26,8 +26,8 @@ func AddNewGroup(w http.ResponseWriter, r *http.Request) {
 	//Call db conncetion
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `INSERT INTO groups(group_name, description) VALUES ($1, $2)`
-	_, err = db.Exec(stmt)
+	stmt := `INSERT INTO groups(group_name, description, tenant_id) VALUES ($1, $2, $3)`
+	_, err = db.Exec(stmt, group.GroupName, group.Description, middleware.TenantId(r))
 	//Checking for errors
 	if err, ok := err.(*pq.Error); ok {
 		//Check for duplicate data
@@ -38,14 +38,16 @@ func AddNewGroup(w http.ResponseWriter, r *http.Request) {
 				Message: "Group already exists",
 			}
 			json.NewEncoder(w).Encode(res)
+		} else {
+			//Checking for other errors
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
 		}
-	} else {
-		//Checking for other errors
-		res := middleware.Response{
-			Error:   true,
-			Message: err.Error(),
-		}
-		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//If everything went fine, return response
 	w.WriteHeader(http.StatusCreated)
@@ -71,8 +73,8 @@ func DeleteGroup(w http.ResponseWriter, r *http.Request) {
 	db := db.ConnectDB()
 	defer db.Close()
 	//Check if group has been assigned to user
-	grpStmt := `SELECT user_id FROM users WHERE group_id = $1 LIMIT 1`
-	row := db.QueryRow(grpStmt, groupId)
+	grpStmt := `SELECT user_id FROM group_members WHERE group_id = $1 AND tenant_id = $2 LIMIT 1`
+	row := db.QueryRow(grpStmt, groupId, middleware.TenantId(r))
 	var userId uint64
 
 	err = row.Scan(&userId)
@@ -95,8 +97,8 @@ func DeleteGroup(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(res)
 	}
 	//You can now delete group
-	stmt := `DELETE FROM groups WHERE id = $1`
-	result, err := db.Exec(stmt, groupId)
+	stmt := `DELETE FROM groups WHERE group_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, groupId, middleware.TenantId(r))
 	//Checking for errors
 	if err, ok := err.(*pq.Error); ok {
 		res := middleware.Response{
@@ -136,7 +138,7 @@ func GetGroup(w http.ResponseWriter, r *http.Request) {
 	group := GroupModel{}
 	//Convert req params to int
 	params := mux.Vars(r)
-	groupId, err := strconv.Atoi(params["id"])
+	groupId, err := strconv.Atoi(params["group_id"])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		res := middleware.Response{
@@ -148,9 +150,9 @@ func GetGroup(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT * FROM groups WHERE id = $1`
-	row := db.QueryRow(stmt, groupId)
-	err = row.Scan(stmt, &group.GroupId, &group.GroupName, &group.Description)
+	stmt := `SELECT group_id, group_name, COALESCE(description, '') FROM groups WHERE group_id = $1 AND tenant_id = $2`
+	row := db.QueryRow(stmt, groupId, middleware.TenantId(r))
+	err = row.Scan(&group.GroupId, &group.GroupName, &group.Description)
 	//Check for no data found error
 	if err == sql.ErrNoRows {
 		w.WriteHeader(http.StatusNotFound)
@@ -159,7 +161,8 @@ func GetGroup(w http.ResponseWriter, r *http.Request) {
 			Message: "Group not found",
 		}
 		json.NewEncoder(w).Encode(res)
-	} else {
+		return
+	} else if err != nil {
 		//For all other errors
 		w.WriteHeader(http.StatusInternalServerError)
 		res := middleware.Response{
@@ -167,6 +170,7 @@ func GetGroup(w http.ResponseWriter, r *http.Request) {
 			Message: err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//If everything was fine, return group object
 	w.WriteHeader(http.StatusOK)
@@ -179,8 +183,8 @@ func GetGroups(w http.ResponseWriter, r *http.Request) {
 	//Call db connecton
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT * FROM groups`
-	rows, err := db.Query(stmt)
+	stmt := `SELECT group_id, group_name, COALESCE(description, '') FROM groups WHERE tenant_id = $1`
+	rows, err := db.Query(stmt, middleware.TenantId(r))
 	//Checking for errors
 	if err, ok := err.(*pq.Error); ok {
 		//Check for no data found
@@ -246,8 +250,8 @@ func EditGroup(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `UPDATE groups SET group_name = $2 WHERE group_id = $1`
-	result, err := db.Exec(stmt, uint64(groupId))
+	stmt := `UPDATE groups SET group_name = $2 WHERE group_id = $1 AND tenant_id = $3`
+	result, err := db.Exec(stmt, uint64(groupId), group.GroupName, middleware.TenantId(r))
 	//Check for errors
 	if err, ok := err.(*pq.Error); ok {
 		res := middleware.Response{
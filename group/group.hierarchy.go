@@ -0,0 +1,410 @@
+package group
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//Walks the group hierarchy down from group $1. UNION stops the walk even if a cycle
+//slipped into group_hierarchy.
+const descendantsCTE = `WITH RECURSIVE descendants(group_id) AS (
+		SELECT CAST($1 AS BIGINT)
+	UNION
+		SELECT gh.child_group_id FROM group_hierarchy gh
+		JOIN descendants d ON gh.parent_group_id = d.group_id
+	)`
+
+//For nesting a group inside another one. Takes the group_name of the child
+func AddChildGroup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get parent group id from req params
+	params := mux.Vars(r)
+	parentId, err := strconv.Atoi(params["group_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Use group name of the child to get its group id
+	child := GroupModel{}
+	if err := json.NewDecoder(r.Body).Decode(&child); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT group_id FROM groups WHERE group_name = $1 AND tenant_id = $2`
+	err = db.QueryRow(stmt, child.GroupName, tenantId).Scan(&child.GroupId)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Group not found!",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Adding the edge creates a cycle if the parent is the child or one of its descendants
+	var loops bool
+	stmt = descendantsCTE + ` SELECT EXISTS(SELECT 1 FROM descendants WHERE group_id = $2)`
+	if err := db.QueryRow(stmt, child.GroupId, uint64(parentId)).Scan(&loops); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if loops {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Group hierarchy cannot contain a cycle",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Members below the child gain the roles of the parent, which may carry a platform privilege
+	err = middleware.CheckGrantableGroup(db, principal.UserId, uint64(parentId))
+	if err, ok := err.(middleware.UngrantableError); ok {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Every member below the child gains the parent's roles, so check them before committing
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//The parent must belong to the tenant, the child was looked up in it
+	stmt = `INSERT INTO group_hierarchy(parent_group_id, child_group_id, tenant_id)
+		SELECT group_id, $2, tenant_id FROM groups WHERE group_id = $1 AND tenant_id = $3`
+	result, err := tx.Exec(stmt, uint64(parentId), child.GroupId, tenantId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		//Check for duplicate data
+		if err.Code == "42701" || err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Group is already a child of this group",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			//For all other errors
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Parent group not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	members, err := memberIds(tx, child.GroupId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg, err := middleware.CheckStaticSoD(tx, members)
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Child group added successfully",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For taking a group out of its parent. Its members lose the parent's roles
+func RemoveChildGroup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get parent and child group ids from req params
+	params := mux.Vars(r)
+	parentId, err := strconv.Atoi(params["group_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	childId, err := strconv.Atoi(params["child_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM group_hierarchy WHERE parent_group_id = $1 AND child_group_id = $2 AND tenant_id = $3`
+	result, err := db.Exec(stmt, uint64(parentId), uint64(childId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Check if the delete operation was successful
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error returning rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Group is not a child of this group",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Child group removed successfully",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching a group with every group below it, nested. A group with several
+//parents in the tree shows up under each of them
+func GetGroupTree(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get group id from req params
+	params := mux.Vars(r)
+	groupId, err := strconv.Atoi(params["group_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	//Every edge below the group, the group itself comes with parent 0
+	stmt := `WITH RECURSIVE tree(group_id, parent_id) AS (
+			SELECT CAST($1 AS BIGINT), 0
+		UNION
+			SELECT gh.child_group_id, gh.parent_group_id FROM group_hierarchy gh
+			JOIN tree t ON gh.parent_group_id = t.group_id
+		)
+		SELECT t.group_id, t.parent_id, g.group_name, COALESCE(g.description, '')
+		FROM tree t JOIN groups g ON g.group_id = t.group_id
+		WHERE g.tenant_id = $2 ORDER BY g.group_name`
+	rows, err := db.Query(stmt, uint64(groupId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	var root *GroupTreeModel
+	children := map[uint64][]GroupTreeModel{}
+	for rows.Next() {
+		node := GroupTreeModel{Children: []GroupTreeModel{}}
+		var parentId uint64
+		if err := rows.Scan(&node.GroupId, &parentId, &node.GroupName, &node.Description); err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Error scanning result set",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if parentId == 0 {
+			root = &node
+		} else {
+			children[parentId] = append(children[parentId], node)
+		}
+	}
+	if root == nil {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Group not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went fine, return the nested groups
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(buildTree(*root, children))
+}
+
+//Attaches the children of node, then theirs. The hierarchy has no cycles so this ends
+func buildTree(node GroupTreeModel, children map[uint64][]GroupTreeModel) GroupTreeModel {
+	for _, child := range children[node.GroupId] {
+		node.Children = append(node.Children, buildTree(child, children))
+	}
+	return node
+}
+
+//For fetching every group a user belongs to, directly, through a membership rule or
+//through the groups containing those
+func GetUserGroups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	data := []MembershipModel{}
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `WITH RECURSIVE user_groups(group_id, depth) AS (
+			SELECT group_id, 0 FROM group_members WHERE user_id = $1 AND tenant_id = $2
+			AND (expires_at IS NULL OR expires_at > NOW())
+		UNION
+			SELECT group_id, 0 FROM group_rule_members WHERE user_id = $1 AND tenant_id = $2
+		UNION
+			SELECT gh.parent_group_id, ug.depth + 1 FROM group_hierarchy gh
+			JOIN user_groups ug ON gh.child_group_id = ug.group_id
+		)
+		SELECT g.group_id, g.group_name, MIN(ug.depth) FROM user_groups ug
+		JOIN groups g ON g.group_id = ug.group_id
+		GROUP BY g.group_id, g.group_name ORDER BY 3, 2`
+	rows, err := db.Query(stmt, uint64(userId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		membership := MembershipModel{}
+		if err := rows.Scan(&membership.GroupId, &membership.GroupName, &membership.Depth); err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Error scanning result set",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, membership)
+	}
+	//If everything went fine, return array of memberships
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
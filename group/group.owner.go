@@ -0,0 +1,90 @@
+package group
+
+import (
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//For setting the owner of a group. Roles can let group owners approve access requests
+//of the group's members. A null owner_id clears it
+func SetGroupOwner(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract group id from req params
+	params := mux.Vars(r)
+	groupId, err := strconv.Atoi(params["group_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	owner := OwnerModel{}
+	if err := json.NewDecoder(r.Body).Decode(&owner); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `UPDATE groups SET owner_id = $2 WHERE group_id = $1 AND tenant_id = $3`
+	result, err := db.Exec(stmt, uint64(groupId), owner.OwnerId, middleware.TenantId(r))
+	//Check for errors
+	if err, ok := err.(*pq.Error); ok {
+		//Owner must be an existing user
+		if err.Code == "23503" {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Owner not found",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//Check if any row was affected in the update operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to count rows affected in update operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Group not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Group owner updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -13,6 +13,27 @@ import (
 	"github.com/lib/pq"
 )
 
+//Returns the ids of the users in groupId or in any group below it, put there by hand or by a rule
+func memberIds(q middleware.Queryer, groupId uint64) ([]uint64, error) {
+	stmt := descendantsCTE + ` SELECT m.user_id FROM group_members m JOIN descendants d ON d.group_id = m.group_id
+		WHERE m.expires_at IS NULL OR m.expires_at > NOW()
+		UNION SELECT m.user_id FROM group_rule_members m JOIN descendants d ON d.group_id = m.group_id`
+	rows, err := q.Query(stmt, groupId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []uint64{}
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 //More than one role can be assigned to a group
 func AddRoleToGroup(w http.ResponseWriter, r *http.Request){
 	w.Header().Set("Content-Type", "application/json")
@@ -25,12 +46,22 @@ func AddRoleToGroup(w http.ResponseWriter, r *http.Request){
 			Message: "Unable to parse json",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if !role.IsValid() {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error: true,
+			Message: "valid_until must be after valid_from",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT role_id FROM group_roles WHERE role_name = $1`
-	row := db.QueryRow(stmt, role.RoleName)
+	stmt := `SELECT role_id FROM roles WHERE role_name = $1 AND tenant_id = $2`
+	row := db.QueryRow(stmt, role.RoleName, middleware.TenantId(r))
 	err := row.Scan(&role.RoleId)
 	//Check if role exists
 	if err == sql.ErrNoRows {
@@ -40,15 +71,17 @@ func AddRoleToGroup(w http.ResponseWriter, r *http.Request){
 			Message: "Role not found!!!",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//check for other errors
-	if err, ok := err.(*pq.Error); ok {
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		res := middleware.Response{
 			Error: true,
 			Message: "Internal server error" + err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Extract group_id from req params and convert to int
 	params := mux.Vars(r)
@@ -60,6 +93,7 @@ func AddRoleToGroup(w http.ResponseWriter, r *http.Request){
 			Message: "Internal server error" + err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Check if role has been assigned to the group already
 	stmt = `SELECT role_id FROM group_roles WHERE role_id = $1 AND group_id = $2`
@@ -67,13 +101,14 @@ func AddRoleToGroup(w http.ResponseWriter, r *http.Request){
 	var myRoleId uint64
 	err = row.Scan(&myRoleId)
 		//Check for other errors
-		if err != nil {
+		if err != nil && err != sql.ErrNoRows {
 			w.WriteHeader(http.StatusInternalServerError)
 			res := middleware.Response{
 				Error: true,
 				Message: "Internal server error" + err.Error(),
 			}
 			json.NewEncoder(w).Encode(res)
+			return
 		}
 	//Check if any row returned
 	if myRoleId != 0 {
@@ -83,18 +118,96 @@ func AddRoleToGroup(w http.ResponseWriter, r *http.Request){
 			Message: "Duplicate data!!! Role already assigned to group",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Tenant admins cannot give a group a role carrying a platform privilege
+	err = middleware.CheckGrantableRole(db, principal.UserId, role.RoleId)
+	if err, ok := err.(middleware.UngrantableError); ok {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:  true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:  true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
-	//Now, assigned role to group
-	stmt = `INSERT INTO group_roles(group_id, role_id) VALUES ($1, $2)`
-	_, err = db.Exec(stmt, uint64(groupId), uint64(role.RoleId)) 
+	//Now, assigned role to group, optionally limited to a window. Every member of the
+	//group gets the role, so the grant is checked against their separation of duties
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error: true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Nothing is added if the group belongs to another tenant
+	stmt = `INSERT INTO group_roles(group_id, role_id, valid_from, valid_until, tenant_id)
+		SELECT group_id, $2, $3, $4, tenant_id FROM groups WHERE group_id = $1 AND tenant_id = $5`
+	result, err := tx.Exec(stmt, uint64(groupId), uint64(role.RoleId), role.ValidFrom, role.ValidUntil, middleware.TenantId(r))
 	//Check for errors
-	if err, ok := err.(*pq.Error); ok {
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		res := middleware.Response{
 			Error: true,
 			Message: "Internal server error" + err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error: true,
+			Message: "Group not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	members, err := memberIds(tx, uint64(groupId))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error: true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg, err := middleware.CheckStaticSoD(tx, members)
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error: true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error: true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//If everything went fine, then return response
 	w.WriteHeader(http.StatusCreated)
@@ -116,12 +229,13 @@ func RemoveRoleFromGroup(w http.ResponseWriter, r *http.Request){
 			Message: "Unable to parse req body to json",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT role_id FROM roles WHERE role_name = $1`
-	row := db.QueryRow(stmt, role.RoleName)
+	stmt := `SELECT role_id FROM roles WHERE role_name = $1 AND tenant_id = $2`
+	row := db.QueryRow(stmt, role.RoleName, middleware.TenantId(r))
 	err := row.Scan(&role.RoleId)
 	//Check if role exists
 	if err == sql.ErrNoRows {
@@ -131,6 +245,7 @@ func RemoveRoleFromGroup(w http.ResponseWriter, r *http.Request){
 			Message: "Role not found!",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	if err, ok := err.(*pq.Error); ok {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -0,0 +1,504 @@
+package group
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"hrm/policy/rule"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+//Attributes a membership rule is evaluated against, the user.* names of rule.Attributes.
+//Users without an employee record only have user_id and username. user.department is the
+//name of the department the user is currently assigned to, user.status the lifecycle status
+const selectRuleUsers = `SELECT u.user_id, u.username, u.status, d.department_name, e.job_title, e.location, e.employment_type
+	FROM users u LEFT JOIN employees e ON e.user_id = u.user_id
+	LEFT JOIN employee_assignments a ON a.user_id = u.user_id
+		AND a.effective_from <= CURRENT_DATE AND (a.effective_to IS NULL OR a.effective_to >= CURRENT_DATE)
+	LEFT JOIN departments d ON d.department_id = a.department_id`
+
+type ruleUser struct {
+	MemberModel
+	attrs rule.Attributes
+}
+
+func queryRuleUsers(q middleware.Queryer, stmt string, args ...interface{}) ([]ruleUser, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []ruleUser{}
+	for rows.Next() {
+		u := ruleUser{}
+		var status string
+		var department, jobTitle, location, employmentType sql.NullString
+		err := rows.Scan(&u.UserId, &u.Username, &status, &department, &jobTitle, &location, &employmentType)
+		if err != nil {
+			return nil, err
+		}
+		u.attrs = rule.Attributes{
+			"user.user_id":  u.UserId,
+			"user.username": u.Username,
+			"user.status":   status,
+		}
+		//Unset attributes stay null for the rule
+		for name, value := range map[string]sql.NullString{
+			"user.department": department, "user.job_title": jobTitle,
+			"user.location": location, "user.employment_type": employmentType,
+		} {
+			if value.Valid {
+				u.attrs[name] = value.String
+			}
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+//Reports whether the rule holds for the user. A rule failing to evaluate, e.g. ordering
+//a string against a number, does not hold. Terminated users are in no dynamic group
+func matches(condition *rule.Rule, u ruleUser) bool {
+	if u.attrs["user.status"] == "terminated" {
+		return false
+	}
+	ok, err := condition.Eval(u.attrs)
+	return err == nil && ok
+}
+
+//Compares the rule members of groupId with the users of tenantId the condition holds for.
+//A nil condition means nobody should be a member
+func diffMembers(q middleware.Queryer, groupId, tenantId uint64, condition *rule.Rule) (PreviewModel, error) {
+	preview := PreviewModel{Join: []MemberModel{}, Leave: []MemberModel{}}
+	stmt := `SELECT u.user_id, u.username FROM group_rule_members m JOIN users u ON u.user_id = m.user_id
+		WHERE m.group_id = $1 ORDER BY u.username`
+	rows, err := q.Query(stmt, groupId)
+	if err != nil {
+		return preview, err
+	}
+	current := map[uint64]bool{}
+	var members []MemberModel
+	for rows.Next() {
+		m := MemberModel{}
+		if err := rows.Scan(&m.UserId, &m.Username); err != nil {
+			rows.Close()
+			return preview, err
+		}
+		current[m.UserId] = true
+		members = append(members, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return preview, err
+	}
+	matching := map[uint64]bool{}
+	if condition != nil {
+		users, err := queryRuleUsers(q, selectRuleUsers+` WHERE u.tenant_id = $1 ORDER BY u.username`, tenantId)
+		if err != nil {
+			return preview, err
+		}
+		for _, u := range users {
+			if !matches(condition, u) {
+				continue
+			}
+			matching[u.UserId] = true
+			if !current[u.UserId] {
+				preview.Join = append(preview.Join, u.MemberModel)
+			}
+		}
+	}
+	for _, m := range members {
+		if !matching[m.UserId] {
+			preview.Leave = append(preview.Leave, m)
+		}
+	}
+	return preview, nil
+}
+
+//Brings the rule members of groupId in line with its saved rule. Returns who joined and
+//left, the caller checks the joiners against separation of duties before committing
+func syncGroup(tx *sql.Tx, groupId uint64) (PreviewModel, error) {
+	var src sql.NullString
+	var tenantId uint64
+	stmt := `SELECT membership_rule, tenant_id FROM groups WHERE group_id = $1 FOR UPDATE`
+	if err := tx.QueryRow(stmt, groupId).Scan(&src, &tenantId); err != nil {
+		return PreviewModel{}, err
+	}
+	var condition *rule.Rule
+	if src.Valid {
+		var err error
+		if condition, err = rule.Parse(src.String); err != nil {
+			return PreviewModel{}, err
+		}
+	}
+	preview, err := diffMembers(tx, groupId, tenantId, condition)
+	if err != nil {
+		return preview, err
+	}
+	stmt = `INSERT INTO group_rule_members(group_id, user_id, tenant_id) VALUES ($1, $2, $3)`
+	for _, m := range preview.Join {
+		if _, err := tx.Exec(stmt, groupId, m.UserId, tenantId); err != nil {
+			return preview, err
+		}
+	}
+	stmt = `DELETE FROM group_rule_members WHERE group_id = $1 AND user_id = $2`
+	for _, m := range preview.Leave {
+		if _, err := tx.Exec(stmt, groupId, m.UserId); err != nil {
+			return preview, err
+		}
+	}
+	stmt = `UPDATE groups SET rule_evaluated_at = NOW() WHERE group_id = $1`
+	_, err = tx.Exec(stmt, groupId)
+	return preview, err
+}
+
+//Returns the ids of the members of a preview
+func joinedIds(members []MemberModel) []uint64 {
+	ids := make([]uint64, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.UserId)
+	}
+	return ids
+}
+
+//Re-evaluates every dynamic group of the user's tenant for userId, after the user's
+//attributes changed. Returns the reason the new memberships are rejected, if any
+func SyncUser(tx *sql.Tx, userId uint64) (string, error) {
+	users, err := queryRuleUsers(tx, selectRuleUsers+` WHERE u.user_id = $1`, userId)
+	if err != nil || len(users) == 0 {
+		return "", err
+	}
+	type dynamicGroup struct {
+		groupId, tenantId uint64
+		src               string
+	}
+	var groups []dynamicGroup
+	stmt := `SELECT g.group_id, g.tenant_id, g.membership_rule FROM groups g JOIN users u ON u.tenant_id = g.tenant_id
+		WHERE u.user_id = $1 AND g.membership_rule IS NOT NULL`
+	rows, err := tx.Query(stmt, userId)
+	if err != nil {
+		return "", err
+	}
+	for rows.Next() {
+		g := dynamicGroup{}
+		if err := rows.Scan(&g.groupId, &g.tenantId, &g.src); err != nil {
+			rows.Close()
+			return "", err
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	for _, g := range groups {
+		condition, err := rule.Parse(g.src)
+		if err != nil {
+			return "", err
+		}
+		if matches(condition, users[0]) {
+			stmt = `INSERT INTO group_rule_members(group_id, user_id, tenant_id) VALUES ($1, $2, $3)
+				ON CONFLICT DO NOTHING`
+			_, err = tx.Exec(stmt, g.groupId, userId, g.tenantId)
+		} else {
+			stmt = `DELETE FROM group_rule_members WHERE group_id = $1 AND user_id = $2`
+			_, err = tx.Exec(stmt, g.groupId, userId)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return middleware.CheckStaticSoD(tx, []uint64{userId})
+}
+
+//Re-evaluates every dynamic group every interval, catching users whose attributes changed
+//outside the API. Meant to run in its own goroutine for the life of the server
+func SyncDynamicGroups(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		db := db.ConnectDB()
+		count, err := syncAll(db)
+		db.Close()
+		if err != nil {
+			log.Printf("dynamic groups: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("dynamic groups: %d memberships changed", count)
+		}
+	}
+}
+
+//Syncs each dynamic group in its own transaction. A group whose new members would break
+//separation of duties is left as it was until the rule or the constraint changes
+func syncAll(db *sql.DB) (int, error) {
+	rows, err := db.Query(`SELECT group_id FROM groups WHERE membership_rule IS NOT NULL`)
+	if err != nil {
+		return 0, err
+	}
+	var groupIds []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		groupIds = append(groupIds, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, groupId := range groupIds {
+		n, err := syncOne(db, groupId)
+		if err != nil {
+			return count, err
+		}
+		count += n
+	}
+	return count, nil
+}
+
+func syncOne(db *sql.DB, groupId uint64) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	preview, err := syncGroup(tx, groupId)
+	if err != nil {
+		return 0, err
+	}
+	msg, err := middleware.CheckStaticSoD(tx, joinedIds(preview.Join))
+	if err != nil {
+		return 0, err
+	}
+	if msg != "" {
+		log.Printf("dynamic groups: group %d left unchanged: %s", groupId, msg)
+		return 0, nil
+	}
+	return len(preview.Join) + len(preview.Leave), tx.Commit()
+}
+
+//For setting the membership rule of a group, which makes it dynamic. The members are
+//updated at once. An empty rule makes the group static again and removes its rule members
+func SetMembershipRule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get group id from req params
+	params := mux.Vars(r)
+	groupId, err := strconv.Atoi(params["group_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	membership := RuleModel{}
+	if err := json.NewDecoder(r.Body).Decode(&membership); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if membership.Rule != "" {
+		if _, err := rule.Parse(membership.Rule); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Invalid rule: " + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	//Users put in the group by hand would be mixed with the rule members
+	var manual bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM group_members WHERE group_id = $1)`
+	if err := db.QueryRow(stmt, uint64(groupId)).Scan(&manual); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if manual && membership.Rule != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Group has members added by hand, remove them before setting a rule",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Users matching the rule get the roles of the group, which may carry a platform privilege
+	err = middleware.CheckGrantableGroup(db, principal.UserId, uint64(groupId))
+	if err, ok := err.(middleware.UngrantableError); ok {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	stmt = `UPDATE groups SET membership_rule = NULLIF($2, '') WHERE group_id = $1 AND tenant_id = $3`
+	result, err := tx.Exec(stmt, uint64(groupId), membership.Rule, middleware.TenantId(r))
+	if err == nil {
+		if count, _ := result.RowsAffected(); count == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Group not found",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	var preview PreviewModel
+	if err == nil {
+		preview, err = syncGroup(tx, uint64(groupId))
+	}
+	msg := ""
+	if err == nil {
+		msg, err = middleware.CheckStaticSoD(tx, joinedIds(preview.Join))
+	}
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error: false,
+		Message: "Rule saved, " + strconv.Itoa(len(preview.Join)) + " users joined and " +
+			strconv.Itoa(len(preview.Leave)) + " left",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For previewing a membership rule before it is saved: who would join and who would
+//leave the group. Nothing is changed
+func PreviewMembershipRule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get group id from req params
+	params := mux.Vars(r)
+	groupId, err := strconv.Atoi(params["group_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	membership := RuleModel{}
+	if err := json.NewDecoder(r.Body).Decode(&membership); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var condition *rule.Rule
+	if membership.Rule != "" {
+		if condition, err = rule.Parse(membership.Rule); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Invalid rule: " + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	found, err := middleware.InTenant(db, "groups", uint64(groupId), tenantId)
+	if err == nil && !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Group not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var preview PreviewModel
+	if err == nil {
+		preview, err = diffMembers(db, uint64(groupId), tenantId, condition)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return who would join and leave
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(preview)
+}
@@ -3,200 +3,384 @@ package group
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"hrm/db"
 	"hrm/middleware"
-	"strconv"
-
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/lib/pq"
 )
 
-//This is an update on user table. No need to check whether the user has the group assigned already or not
-func AddUserToGroup(w http.ResponseWriter, r *http.Request){
-	//Use group name to extact the group id of the group to be assigned to user
-	group := GroupModel{}
-	if err := json.NewDecoder(r.Body).Decode(&group); err != nil{
+//For putting a user in a group. Takes group_name and an optional expires_at. A user can be in
+//any number of groups, each membership is checked against the user's separation of duties
+func AddUserToGroup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract user_id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		res := middleware.Response{
-			Error: true,
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	member := AddMemberModel{}
+	if err := json.NewDecoder(r.Body).Decode(&member); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
 			Message: "Unable to parse req body to json",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
+	if member.ExpiresAt != nil && !member.ExpiresAt.After(time.Now()) {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "expires_at must be in the future",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	tenantId := middleware.TenantId(r)
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT group_id FROM groups WHERE group_name = $1`
-	row := db.QueryRow(stmt, group.GroupName)
-	//Check if any row is returned or not after scanning the return result
-	err := row.Scan(&group.GroupId)
+	//Use group name to extract the group id
+	var groupId uint64
+	var dynamic bool
+	stmt := `SELECT group_id, membership_rule IS NOT NULL FROM groups WHERE group_name = $1 AND tenant_id = $2`
+	err = db.QueryRow(stmt, member.GroupName, tenantId).Scan(&groupId, &dynamic)
 	if err == sql.ErrNoRows {
 		w.WriteHeader(http.StatusNotFound)
 		res := middleware.Response{
-			Error: true,
+			Error:   true,
+			Message: "Group not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Members of a dynamic group come from its rule only
+	if dynamic {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Membership of this group is defined by its rule",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Tenant admins cannot put anyone in a group whose roles carry a platform privilege
+	err = middleware.CheckGrantableGroup(db, principal.UserId, groupId)
+	if err, ok := err.(middleware.UngrantableError); ok {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//The user gets the roles of the group, so the membership is checked against the user's
+	//separation of duties before committing
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Nothing is added if the user belongs to another tenant
+	stmt = `INSERT INTO group_members(group_id, user_id, added_by, expires_at, tenant_id)
+		SELECT $1, user_id, $3, $4, tenant_id FROM users WHERE user_id = $2 AND tenant_id = $5`
+	result, err := tx.Exec(stmt, groupId, uint64(userId), principal.UserId, member.ExpiresAt, tenantId)
+	//Check for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "User is already a member of this group",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Internal server error" + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "User not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg, err := middleware.CheckStaticSoD(tx, []uint64{uint64(userId)})
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "User added to group",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching the users put in a group by hand, with who added them and when the membership expires
+func GetGroupMembers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get group id from req params
+	params := mux.Vars(r)
+	groupId, err := strconv.Atoi(params["group_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	found, err := middleware.InTenant(db, "groups", uint64(groupId), tenantId)
+	if err == nil && !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
 			Message: "Group not found",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	data := []GroupMemberModel{}
+	if err == nil {
+		data, err = queryGroupMembers(db, uint64(groupId))
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of member objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+func queryGroupMembers(db *sql.DB, groupId uint64) ([]GroupMemberModel, error) {
+	stmt := `SELECT m.user_id, u.username, m.added_by, m.added_at, m.expires_at
+		FROM group_members m JOIN users u ON u.user_id = m.user_id
+		WHERE m.group_id = $1 AND (m.expires_at IS NULL OR m.expires_at > NOW()) ORDER BY u.username`
+	rows, err := db.Query(stmt, groupId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := []GroupMemberModel{}
+	for rows.Next() {
+		m := GroupMemberModel{}
+		var addedBy sql.NullInt64
+		if err := rows.Scan(&m.UserId, &m.Username, &addedBy, &m.AddedAt, &m.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if addedBy.Valid {
+			id := uint64(addedBy.Int64)
+			m.AddedBy = &id
+		}
+		members = append(members, m)
 	}
-	
-//  user := user.UserModel{}
- //Extract user_id from request body
-  params := mux.Vars(r)
-  //Convert user id to int
-   userId, err := strconv.Atoi(params["user_id"])
-   if err != nil {
-	   w.WriteHeader(http.StatusBadRequest)
-	   res := middleware.Response{
-		   Error: true,
-		   Message: "Unable to convert req params to int",
-	   }
-	   json.NewEncoder(w).Encode(res)
-   }
-   //Now update user with the new group id
-   stmt = `UPDATE users SET group_id = $2 WHERE user_id = $1`
-   result, err := db.Exec(stmt, userId, group.GroupId)
-   //Check for errors
-   if err, ok := err.(*pq.Error); ok {
-	   w.WriteHeader(http.StatusInternalServerError)
-	   res := middleware.Response{
-		   Error: true,
-		   Message: "Internal server error" + err.Error(),
-	   }
-	   json.NewEncoder(w).Encode(res)
-   }
-   //Check if any row affected
-  if count, err := result.RowsAffected(); err != nil {
-	  w.WriteHeader(http.StatusExpectationFailed)
-	  res := middleware.Response{
-		  Error: true,
-		  Message: "Unable to count rows affected in update operation",
-	  }
-	  json.NewEncoder(w).Encode(res)
-  } else{
-	  if count == 0 {
-		  w.WriteHeader(http.StatusNotModified)
-		  res := middleware.Response{
-			  Error: true,
-			  Message: "Update operation NOT!!! successful. User probably dont exist",
-		  }
-		  json.NewEncoder(w).Encode(res)
-	  }
-  }
-  //If update operation was successful, return response
-  w.WriteHeader(http.StatusCreated)
-  res := middleware.Response{
-	  Error: false,
-	  Message: "User group object modified.",
-  }
-  json.NewEncoder(w).Encode(res)
+	return members, rows.Err()
 }
 
-//this is an update operation that sets group_id on users table to null
-func RemoveUserFromGroup(w http.ResponseWriter, r *http.Request){
-	//Extract user_d from req params
+//For taking a user out of one group. The user stays in every other group
+func RemoveUserFromGroup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract user_id and group_id from req params
 	params := mux.Vars(r)
-	//Convert req params to int
 	userId, err := strconv.Atoi(params["user_id"])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		res := middleware.Response{
-			Error: true,
+			Error:   true,
 			Message: "Unable to convert req params to int",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	groupId, err := strconv.Atoi(params["group_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `UPDATE users SET group_id = NULL WHERE user_id = $1`
-	result, err := db.Exec(stmt, userId)
+	stmt := `DELETE FROM group_members WHERE user_id = $1 AND group_id = $2 AND tenant_id = $3`
+	result, err := db.Exec(stmt, uint64(userId), uint64(groupId), middleware.TenantId(r))
 	//Check for errors
-	if err, ok := err.(*pq.Error); ok {
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		res := middleware.Response{
-			Error: true,
+			Error:   true,
 			Message: "Internal server error" + err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
-	//Check if any row was affected in the update operation
+	//Check if any row was affected by the delete operation
 	if count, err := result.RowsAffected(); err != nil {
 		w.WriteHeader(http.StatusExpectationFailed)
 		res := middleware.Response{
-			Error: true,
-			Message: "Unable to count rows affected by the update operation",
+			Error:   true,
+			Message: "Unable to count rows affected by the delete operation",
 		}
 		json.NewEncoder(w).Encode(res)
-	}else{
-		if count == 0 {
-			w.WriteHeader(http.StatusNotModified)
-			res := middleware.Response{
-				Error: true,
-				Message: "Update operation NOT!!! successful. User probably doesnt exist.",
-			}
-			json.NewEncoder(w).Encode(res)
-		}
-		//If everything went fine, return response
-		w.WriteHeader(http.StatusCreated)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
 		res := middleware.Response{
-			Error: false,
-			Message: "User removed from group successfully",
+			Error:   true,
+			Message: "User is not a member of this group",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
+	//If everything went fine, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "User removed from group successfully",
+	}
+	json.NewEncoder(w).Encode(res)
 }
 
-func RemoveAllUsersFromGroup(w http.ResponseWriter, r *http.Request){
+//For taking every user put in a group by hand out of it. The users themselves are kept
+func RemoveAllUsersFromGroup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 	//Extract group id from req params and convert to int
 	params := mux.Vars(r)
 	groupId, err := strconv.Atoi(params["group_id"])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		res := middleware.Response{
-			Error: true,
+			Error:   true,
 			Message: "Unable to convert req params to int",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
+	tenantId := middleware.TenantId(r)
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `DELETE FROM users WHERE group_id = $1`
-	result, err := db.Exec(stmt, groupId)
+	found, err := middleware.InTenant(db, "groups", uint64(groupId), tenantId)
+	if err == nil && !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Group not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var result sql.Result
+	if err == nil {
+		stmt := `DELETE FROM group_members WHERE group_id = $1 AND tenant_id = $2`
+		result, err = db.Exec(stmt, uint64(groupId), tenantId)
+	}
 	//Checking for errors
-	if err, ok := err.(*pq.Error); ok {
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		res := middleware.Response{
-			Error: true,
+			Error:   true,
 			Message: "Internal server error" + err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
-	if count, err := result.RowsAffected(); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	count, err := result.RowsAffected()
+	if err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
 		res := middleware.Response{
-			Error: true,
-			Message: "Error counting rows returned by the delete operation.",
+			Error:   true,
+			Message: "Error counting rows affected by the delete operation.",
 		}
 		json.NewEncoder(w).Encode(res)
-	}else {
-		if count == 0 {
-			w.WriteHeader(http.StatusNotModified)
-			res := middleware.Response{
-				Error: true,
-				Message: "No row affected by the delete operation!!!",
-			}
-			json.NewEncoder(w).Encode(res)
-		}
+		return
 	}
 	//If operation was successful, return response
 	w.WriteHeader(http.StatusOK)
 	res := middleware.Response{
-		Error: false,
-		Message: "Operation successful",
+		Error:   false,
+		Message: fmt.Sprintf("%d users removed from group", count),
 	}
 	json.NewEncoder(w).Encode(res)
-	
-}
\ No newline at end of file
+}
@@ -1,9 +1,61 @@
 package group
 
+import "time"
+
 type GroupModel struct{
 	UserId uint64 `json:"id"`
 	GroupName string `json:"group_name"`
 	Description string `json:"description"`
 	RoleId uint64 `json:"role_id"`
 	GroupId uint64 `json:"group_id"`
-}
\ No newline at end of file
+}
+
+type OwnerModel struct {
+	OwnerId *uint64 `json:"owner_id"`
+}
+
+//A group with the groups below it
+type GroupTreeModel struct {
+	GroupId     uint64           `json:"id"`
+	GroupName   string           `json:"group_name"`
+	Description string           `json:"description"`
+	Children    []GroupTreeModel `json:"children"`
+}
+
+//A group a user belongs to. Depth 0 is a group the user is in by hand or by rule, 1 a group containing it...
+type MembershipModel struct {
+	GroupId   uint64 `json:"group_id"`
+	GroupName string `json:"group_name"`
+	Depth     int    `json:"depth"`
+}
+
+//The membership rule of a dynamic group, e.g. user.department == "sales" and user.location in ["Lagos", "Abuja"]
+type RuleModel struct {
+	Rule string `json:"rule"`
+}
+
+type MemberModel struct {
+	UserId   uint64 `json:"user_id"`
+	Username string `json:"username"`
+}
+
+//Users who would join and leave a dynamic group under a rule
+type PreviewModel struct {
+	Join  []MemberModel `json:"join"`
+	Leave []MemberModel `json:"leave"`
+}
+
+//Body of a request putting a user in a group. A membership without expires_at never expires
+type AddMemberModel struct {
+	GroupName string     `json:"group_name"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+//A user put in a group by hand
+type GroupMemberModel struct {
+	UserId    uint64     `json:"user_id"`
+	Username  string     `json:"username"`
+	AddedBy   *uint64    `json:"added_by"`
+	AddedAt   time.Time  `json:"added_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
@@ -0,0 +1,15 @@
+package group
+
+import "hrm/catalog"
+
+//Privileges required by the group routes
+var (
+	PrivCreateGroup         = catalog.Declare("create_group", "Create a group")
+	PrivReadAllGroups       = catalog.Declare("read_all_groups", "List all groups")
+	PrivReadOneGroup        = catalog.Declare("read_one_group", "Read a group")
+	PrivModifyGroup         = catalog.Declare("modify_group", "Edit a group")
+	PrivDeleteGroup         = catalog.Declare("delete_group", "Delete a group")
+	PrivAddUserToGroup      = catalog.Declare("add_user_to_group", "Put a user in a group")
+	PrivRemoveUserFromGroup = catalog.Declare("remove_user_from_group", "Take users out of a group")
+	PrivAddRoleGroup        = catalog.Declare("add_role_group", "Grant a role to a group")
+)
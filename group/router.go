@@ -1 +1,77 @@
-package group
\ No newline at end of file
+package group
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleGroupRoutes(r *mux.Router) {
+	//Endpoint for creating a new group
+	r.HandleFunc("/groups",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivCreateGroup, AddNewGroup))).Methods("POST")
+
+	//Endpoint for fetching all groups
+	r.HandleFunc("/groups",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadAllGroups, GetGroups))).Methods("GET")
+
+	//Endpoint for fetching a single group by id
+	r.HandleFunc("/groups/{group_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadOneGroup, GetGroup))).Methods("GET")
+
+	//Endpoint for editing a group
+	r.HandleFunc("/groups/{group_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyGroup, EditGroup))).Methods("PUT")
+
+	//Endpoint for deleting a group
+	r.HandleFunc("/groups/{group_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDeleteGroup, DeleteGroup))).Methods("DELETE")
+
+	//Endpoint for granting a role to a group
+	r.HandleFunc("/groups/{group_id}/roles",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivAddRoleGroup, AddRoleToGroup))).Methods("POST")
+
+	//Endpoint for putting a user in a group
+	r.HandleFunc("/users/{user_id}/groups",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivAddUserToGroup, AddUserToGroup))).Methods("POST")
+
+	//Endpoint for taking a user out of one of their groups
+	r.HandleFunc("/users/{user_id}/groups/{group_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRemoveUserFromGroup, RemoveUserFromGroup))).Methods("DELETE")
+
+	//Endpoint for fetching the members of a group
+	r.HandleFunc("/groups/{group_id}/members",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadOneGroup, GetGroupMembers))).Methods("GET")
+
+	//Endpoint for taking every member out of a group
+	r.HandleFunc("/groups/{group_id}/members",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRemoveUserFromGroup, RemoveAllUsersFromGroup))).Methods("DELETE")
+
+	//Endpoint for setting the owner of a group
+	r.HandleFunc("/groups/{group_id}/owner",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyGroup, SetGroupOwner))).Methods("PUT")
+
+	//Endpoint for nesting a group inside another one
+	r.HandleFunc("/groups/{group_id}/children",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyGroup, AddChildGroup))).Methods("POST")
+
+	//Endpoint for taking a child group out of its parent
+	r.HandleFunc("/groups/{group_id}/children/{child_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyGroup, RemoveChildGroup))).Methods("DELETE")
+
+	//Endpoint for fetching a group with all the groups below it
+	r.HandleFunc("/groups/{group_id}/tree",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadOneGroup, GetGroupTree))).Methods("GET")
+
+	//Endpoint for fetching every group a user belongs to, directly or not
+	r.HandleFunc("/users/{user_id}/groups",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadOneGroup, GetUserGroups))).Methods("GET")
+
+	//Endpoint for setting the membership rule of a dynamic group
+	r.HandleFunc("/groups/{group_id}/rule",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyGroup, SetMembershipRule))).Methods("PUT")
+
+	//Endpoint for previewing who a membership rule would add and remove
+	r.HandleFunc("/groups/{group_id}/rule/preview",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyGroup, PreviewMembershipRule))).Methods("POST")
+}
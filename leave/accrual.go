@@ -0,0 +1,55 @@
+package leave
+
+import (
+	"database/sql"
+	"hrm/db"
+	"hrm/middleware"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//Credits every employee hired by today with the accrual of each leave type for the current
+//month or year, capped at max_balance. Users locked out ($1) or due to be terminated by today
+//accrue nothing. The unique index on accrual periods makes it safe to run as often as needed
+const accrue = `INSERT INTO leave_ledger(user_id, tenant_id, leave_type_id, days, kind, period)
+	SELECT e.user_id, e.tenant_id, t.leave_type_id,
+		CASE WHEN t.max_balance IS NULL THEN t.accrual_days
+			ELSE LEAST(t.accrual_days, t.max_balance - COALESCE(b.days, 0)) END,
+		'accrual', DATE_TRUNC(CASE t.accrual_method WHEN 'monthly' THEN 'month' ELSE 'year' END, CURRENT_DATE)::DATE
+	FROM leave_types t JOIN employees e ON e.tenant_id = t.tenant_id JOIN users u ON u.user_id = e.user_id
+	LEFT JOIN (SELECT user_id, leave_type_id, SUM(days) AS days FROM leave_ledger GROUP BY user_id, leave_type_id) b
+		ON b.user_id = e.user_id AND b.leave_type_id = t.leave_type_id
+	WHERE t.accrual_method <> 'none' AND t.accrual_days > 0 AND (e.hire_date IS NULL OR e.hire_date <= CURRENT_DATE)
+	AND (t.max_balance IS NULL OR COALESCE(b.days, 0) < t.max_balance)
+	AND NOT (u.status = ANY($1))
+	AND NOT EXISTS(SELECT 1 FROM user_status_changes c WHERE c.user_id = e.user_id AND c.state = 'scheduled'
+		AND c.to_status = 'terminated' AND c.effective_date <= CURRENT_DATE)
+	ON CONFLICT (user_id, leave_type_id, period) WHERE kind = 'accrual' DO NOTHING`
+
+//Runs the leave accrual every interval. Meant to run in its own goroutine for the life of the server
+func AccrueLeave(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		db := db.ConnectDB()
+		count, err := accrueAll(db)
+		db.Close()
+		if err != nil {
+			log.Printf("leave accrual: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("leave accrual: credited %d balances", count)
+		}
+	}
+}
+
+func accrueAll(db *sql.DB) (int64, error) {
+	result, err := db.Exec(accrue, pq.Array(middleware.LockedStatuses))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,234 @@
+package leave
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+//Balances of employee $1 for every leave type of tenant $2 that tracks one
+const selectBalances = `SELECT t.leave_type_id, t.leave_type_code,
+		COALESCE((SELECT SUM(l.days) FROM leave_ledger l WHERE l.user_id = $1 AND l.leave_type_id = t.leave_type_id), 0),
+		COALESCE((SELECT SUM(q.days) FROM leave_requests q
+			WHERE q.user_id = $1 AND q.leave_type_id = t.leave_type_id AND q.status = 'pending'), 0)
+	FROM leave_types t WHERE t.tenant_id = $2 AND t.tracks_balance`
+
+//Implemented by both *sql.DB and *sql.Tx
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func employeeExists(q rowQueryer, userId, tenantId uint64) (bool, error) {
+	var found bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM employees WHERE user_id = $1 AND tenant_id = $2)`
+	err := q.QueryRow(stmt, userId, tenantId).Scan(&found)
+	return found, err
+}
+
+//Returns the balance of an employee for one leave type, and the days pending requests hold of it
+func balanceOf(q rowQueryer, userId, tenantId, leaveTypeId uint64) (BalanceModel, error) {
+	b := BalanceModel{}
+	err := q.QueryRow(selectBalances+` AND t.leave_type_id = $3`, userId, tenantId, leaveTypeId).Scan(&b.LeaveTypeId,
+		&b.LeaveTypeCode, &b.Balance, &b.Pending)
+	b.Available = b.Balance - b.Pending
+	return b, err
+}
+
+//Writes the error response and returns false if the user_id req param is not an employee of the caller's tenant
+func employeeParam(w http.ResponseWriter, r *http.Request, db *sql.DB) (uint64, bool) {
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return 0, false
+	}
+	found, err := employeeExists(db, uint64(userId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return 0, false
+	}
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return 0, false
+	}
+	return uint64(userId), true
+}
+
+//For fetching the balances of an employee, one per leave type that tracks a balance
+func GetBalances(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	userId, ok := employeeParam(w, r, db)
+	if !ok {
+		return
+	}
+	rows, err := db.Query(selectBalances+` ORDER BY t.leave_type_code`, userId, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []BalanceModel{}
+	for rows.Next() {
+		b := BalanceModel{}
+		if err := rows.Scan(&b.LeaveTypeId, &b.LeaveTypeCode, &b.Balance, &b.Pending); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		b.Available = b.Balance - b.Pending
+		data = append(data, b)
+	}
+	//If everything went well, return array of balance objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching every change to the balances of an employee, the latest first
+func GetLedger(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	userId, ok := employeeParam(w, r, db)
+	if !ok {
+		return
+	}
+	stmt := `SELECT l.entry_id, l.leave_type_id, t.leave_type_code, l.days, l.kind, l.request_id,
+			COALESCE(l.note, ''), l.created_by, l.created_at
+		FROM leave_ledger l JOIN leave_types t ON t.leave_type_id = l.leave_type_id
+		WHERE l.user_id = $1 ORDER BY l.entry_id DESC`
+	rows, err := db.Query(stmt, userId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []LedgerEntryModel{}
+	for rows.Next() {
+		e := LedgerEntryModel{}
+		err := rows.Scan(&e.EntryId, &e.LeaveTypeId, &e.LeaveTypeCode, &e.Days, &e.Kind, &e.RequestId,
+			&e.Note, &e.CreatedBy, &e.CreatedAt)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, e)
+	}
+	//If everything went well, return array of ledger entries
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For correcting the balance of an employee. Takes leave_type_id, days and a note saying why
+func AddAdjustment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	adjustment := AdjustmentModel{}
+	if err := json.NewDecoder(r.Body).Decode(&adjustment); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if adjustment.Days == 0 || adjustment.Note == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "days other than 0 and a note are required",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	//Nothing is added if the employee or the leave type belong to another tenant
+	stmt := `INSERT INTO leave_ledger(user_id, tenant_id, leave_type_id, days, kind, note, created_by)
+		SELECT e.user_id, e.tenant_id, t.leave_type_id, $3, 'adjustment', $4, $5
+		FROM employees e JOIN leave_types t ON t.tenant_id = e.tenant_id
+		WHERE e.user_id = $1 AND t.leave_type_id = $2 AND t.tracks_balance AND e.tenant_id = $6`
+	result, err := db.Exec(stmt, uint64(userId), adjustment.LeaveTypeId, adjustment.Days, adjustment.Note,
+		principal.UserId, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Employee record or leave type with a balance not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Balance adjusted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,281 @@
+package leave
+
+import (
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const selectLeaveType = `SELECT leave_type_id, leave_type_code, leave_type_name, paid, tracks_balance,
+		accrual_method, accrual_days, max_balance, created_at
+	FROM leave_types`
+
+//Returns what is wrong with a leave type, or an empty string
+func validateLeaveType(t LeaveTypeModel) string {
+	if t.LeaveTypeCode == "" || t.LeaveTypeName == "" {
+		return "leave_type_code and leave_type_name are required"
+	}
+	switch t.AccrualMethod {
+	case "none":
+		if t.AccrualDays != 0 {
+			return "accrual_days must be 0 without an accrual method"
+		}
+	case "monthly", "yearly":
+		if !t.TracksBalance {
+			return "A leave type that does not track a balance cannot accrue"
+		}
+	default:
+		return "accrual_method must be none, monthly or yearly"
+	}
+	if t.AccrualDays < 0 || (t.MaxBalance != nil && *t.MaxBalance < 0) {
+		return "accrual_days and max_balance cannot be negative"
+	}
+	return ""
+}
+
+//For creating a leave type. Takes leave_type_code, leave_type_name, paid, tracks_balance and
+//the accrual policy. accrual_method defaults to none
+func AddLeaveType(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	leaveType := LeaveTypeModel{Paid: true, TracksBalance: true, AccrualMethod: "none"}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&leaveType); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateLeaveType(leaveType); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `INSERT INTO leave_types(tenant_id, leave_type_code, leave_type_name, paid, tracks_balance,
+			accrual_method, accrual_days, max_balance)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING leave_type_id`
+	err := db.QueryRow(stmt, middleware.TenantId(r), leaveType.LeaveTypeCode, leaveType.LeaveTypeName, leaveType.Paid,
+		leaveType.TracksBalance, leaveType.AccrualMethod, leaveType.AccrualDays, leaveType.MaxBalance).Scan(&leaveType.LeaveTypeId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Leave type code already in use",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Leave type created with id " + strconv.FormatUint(leaveType.LeaveTypeId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all leave types
+func GetLeaveTypes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	rows, err := db.Query(selectLeaveType+` WHERE tenant_id = $1 ORDER BY leave_type_code`, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []LeaveTypeModel{}
+	for rows.Next() {
+		t := LeaveTypeModel{}
+		err := rows.Scan(&t.LeaveTypeId, &t.LeaveTypeCode, &t.LeaveTypeName, &t.Paid, &t.TracksBalance,
+			&t.AccrualMethod, &t.AccrualDays, &t.MaxBalance, &t.CreatedAt)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, t)
+	}
+	//If everything went well, return array of leave type objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For editing a leave type. Every field is replaced. Balances already accrued are kept
+func EditLeaveType(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get leave type id from req params
+	params := mux.Vars(r)
+	leaveTypeId, err := strconv.Atoi(params["leave_type_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	leaveType := LeaveTypeModel{Paid: true, TracksBalance: true, AccrualMethod: "none"}
+	if err := json.NewDecoder(r.Body).Decode(&leaveType); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateLeaveType(leaveType); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `UPDATE leave_types SET leave_type_code = $2, leave_type_name = $3, paid = $4, tracks_balance = $5,
+		accrual_method = $6, accrual_days = $7, max_balance = $8 WHERE leave_type_id = $1 AND tenant_id = $9`
+	result, err := db.Exec(stmt, uint64(leaveTypeId), leaveType.LeaveTypeCode, leaveType.LeaveTypeName, leaveType.Paid,
+		leaveType.TracksBalance, leaveType.AccrualMethod, leaveType.AccrualDays, leaveType.MaxBalance, middleware.TenantId(r))
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Leave type code already in use",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Leave type not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Leave type updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For deleting a leave type that was never requested or credited
+func DeleteLeaveType(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get leave type id from req params
+	params := mux.Vars(r)
+	leaveTypeId, err := strconv.Atoi(params["leave_type_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM leave_types WHERE leave_type_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(leaveTypeId), middleware.TenantId(r))
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23503" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Leave type has requests or balances",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//Check if any row was affected by the delete operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Leave type not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Leave type deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
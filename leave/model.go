@@ -0,0 +1,70 @@
+package leave
+
+import "time"
+
+//A kind of leave. accrual_method is none, monthly or yearly, accrual_days are credited
+//once per period up to max_balance. Types that do not track a balance cannot accrue
+type LeaveTypeModel struct {
+	LeaveTypeId   uint64    `json:"id"`
+	LeaveTypeCode string    `json:"leave_type_code"`
+	LeaveTypeName string    `json:"leave_type_name"`
+	Paid          bool      `json:"paid"`
+	TracksBalance bool      `json:"tracks_balance"`
+	AccrualMethod string    `json:"accrual_method"`
+	AccrualDays   float64   `json:"accrual_days"`
+	MaxBalance    *float64  `json:"max_balance"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+//The balance of an employee for a leave type. pending is held by requests not decided yet
+type BalanceModel struct {
+	LeaveTypeId   uint64  `json:"leave_type_id"`
+	LeaveTypeCode string  `json:"leave_type_code"`
+	Balance       float64 `json:"balance"`
+	Pending       float64 `json:"pending"`
+	Available     float64 `json:"available"`
+}
+
+//A change to a balance. kind is accrual, adjustment, taken or reversal
+type LedgerEntryModel struct {
+	EntryId       uint64    `json:"id"`
+	LeaveTypeId   uint64    `json:"leave_type_id"`
+	LeaveTypeCode string    `json:"leave_type_code"`
+	Days          float64   `json:"days"`
+	Kind          string    `json:"kind"`
+	RequestId     *uint64   `json:"request_id,omitempty"`
+	Note          string    `json:"note,omitempty"`
+	CreatedBy     *uint64   `json:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+//A manual correction of a balance. Negative days take days away
+type AdjustmentModel struct {
+	LeaveTypeId uint64  `json:"leave_type_id"`
+	Days        float64 `json:"days"`
+	Note        string  `json:"note"`
+}
+
+//A request for leave. start_date and end_date are YYYY-MM-DD and both inclusive
+type LeaveRequestModel struct {
+	RequestId     uint64  `json:"id"`
+	UserId        uint64  `json:"user_id"`
+	Username      string  `json:"username"`
+	LeaveTypeId   uint64  `json:"leave_type_id"`
+	LeaveTypeCode string  `json:"leave_type_code"`
+	StartDate     string  `json:"start_date"`
+	EndDate       string  `json:"end_date"`
+	Days          float64 `json:"days"`
+	Reason        string  `json:"reason"`
+	//pending, approved, rejected or cancelled
+	Status       string     `json:"status"`
+	DecidedBy    *uint64    `json:"decided_by,omitempty"`
+	DecisionNote string     `json:"decision_note,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+}
+
+//Optional body of approve, reject and cancel
+type DecisionModel struct {
+	Note string `json:"note"`
+}
@@ -0,0 +1,15 @@
+package leave
+
+import "hrm/catalog"
+
+//Privileges required by the leave routes
+var (
+	PrivManageLeaveTypes = catalog.Declare("manage_leave_types", "Create, edit and delete leave types")
+	PrivReadLeaveTypes   = catalog.Declare("read_leave_types", "List leave types")
+	PrivReadAllLeave     = catalog.Declare("read_all_leave", "Read the leave balances and requests of any employee")
+	PrivReadDeptLeave    = catalog.Declare("read_department_leave", "Read the leave balances of employees in the caller's department or below it")
+	PrivReadOwnLeave     = catalog.Declare("read_own_leave", "Read the caller's own leave balances")
+	PrivAdjustLeave      = catalog.Declare("adjust_leave_balance", "Correct the leave balance of an employee")
+	PrivRequestLeave     = catalog.Declare("request_leave", "Request leave and follow the caller's own requests")
+	PrivDecideLeave      = catalog.Declare("decide_leave", "Approve or reject leave requests of employees reporting to the caller")
+)
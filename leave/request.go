@@ -0,0 +1,511 @@
+package leave
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/calendar"
+	"hrm/db"
+	"hrm/employee"
+	"hrm/middleware"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const selectLeaveRequest = `SELECT q.request_id, q.user_id, u.username, q.leave_type_id, t.leave_type_code,
+		TO_CHAR(q.start_date, 'YYYY-MM-DD'), TO_CHAR(q.end_date, 'YYYY-MM-DD'), q.days, COALESCE(q.reason, ''),
+		q.status, q.decided_by, COALESCE(q.decision_note, ''), q.created_at, q.decided_at
+	FROM leave_requests q JOIN users u ON u.user_id = q.user_id
+	JOIN leave_types t ON t.leave_type_id = q.leave_type_id`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanLeaveRequest(row scanner, q *LeaveRequestModel) error {
+	return row.Scan(&q.RequestId, &q.UserId, &q.Username, &q.LeaveTypeId, &q.LeaveTypeCode, &q.StartDate,
+		&q.EndDate, &q.Days, &q.Reason, &q.Status, &q.DecidedBy, &q.DecisionNote, &q.CreatedAt, &q.DecidedAt)
+}
+
+func queryLeaveRequests(db *sql.DB, stmt string, args ...interface{}) ([]LeaveRequestModel, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	requests := []LeaveRequestModel{}
+	for rows.Next() {
+		request := LeaveRequestModel{}
+		if err := scanLeaveRequest(rows, &request); err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+//Returns the period of a request, or what is wrong with it
+func parsePeriod(q LeaveRequestModel) (time.Time, time.Time, string) {
+	start, err := time.Parse("2006-01-02", q.StartDate)
+	if err != nil {
+		return start, start, "start_date must be formatted YYYY-MM-DD"
+	}
+	end, err := time.Parse("2006-01-02", q.EndDate)
+	if err != nil {
+		return start, end, "end_date must be formatted YYYY-MM-DD"
+	}
+	if end.Before(start) {
+		return start, end, "end_date cannot be before start_date"
+	}
+	return start, end, ""
+}
+
+//For requesting leave for the caller. Takes leave_type_id, start_date, end_date and an
+//optional reason. The period may not overlap another pending or approved request and
+//must be covered by the available balance if the leave type tracks one
+func AddLeaveRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	request := LeaveRequestModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	start, end, msg := parsePeriod(request)
+	if msg == "" && end.After(start.AddDate(1, 0, 0)) {
+		msg = "Leave cannot be requested for more than a year at once"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Locking the employee keeps concurrent requests from overlapping or overdrawing the balance
+	var managerId sql.NullInt64
+	stmt := `SELECT manager_id FROM employees WHERE user_id = $1 AND tenant_id = $2 FOR UPDATE`
+	err = tx.QueryRow(stmt, principal.UserId, principal.HomeTenantId).Scan(&managerId)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You have no employee record",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && !managerId.Valid {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You have no manager to approve the request",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Only the working days of the employee's calendar count
+	if err == nil {
+		var period calendar.WorkingDaysModel
+		period, err = calendar.WorkingDays(tx, principal.UserId, start, end)
+		request.Days = period.WorkingDays
+	}
+	if err == nil && request.Days == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "The period has no working days",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var tracksBalance bool
+	if err == nil {
+		stmt = `SELECT tracks_balance FROM leave_types WHERE leave_type_id = $1 AND tenant_id = $2`
+		err = tx.QueryRow(stmt, request.LeaveTypeId, principal.HomeTenantId).Scan(&tracksBalance)
+		if err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Leave type not found",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	var overlaps bool
+	if err == nil {
+		stmt = `SELECT EXISTS(SELECT 1 FROM leave_requests WHERE user_id = $1 AND status IN ('pending', 'approved')
+			AND start_date <= $3::DATE AND end_date >= $2::DATE)`
+		err = tx.QueryRow(stmt, principal.UserId, request.StartDate, request.EndDate).Scan(&overlaps)
+	}
+	if overlaps {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "The period overlaps another leave request",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && tracksBalance {
+		var balance BalanceModel
+		balance, err = balanceOf(tx, principal.UserId, principal.HomeTenantId, request.LeaveTypeId)
+		if err == nil && balance.Available < request.Days {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Insufficient balance, " + strconv.FormatFloat(balance.Available, 'f', -1, 64) + " days available",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	if err == nil {
+		stmt = `INSERT INTO leave_requests(user_id, tenant_id, leave_type_id, start_date, end_date, days, reason)
+			VALUES ($1, $2, $3, $4::DATE, $5::DATE, $6, NULLIF($7, '')) RETURNING request_id`
+		err = tx.QueryRow(stmt, principal.UserId, principal.HomeTenantId, request.LeaveTypeId, request.StartDate,
+			request.EndDate, request.Days, request.Reason).Scan(&request.RequestId)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Leave request " + strconv.FormatUint(request.RequestId, 10) + " submitted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all leave requests, newest first. ?status= and ?user_id= narrow it down
+func GetLeaveRequests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	status := r.URL.Query().Get("status")
+	var userId int64
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Unable to convert user_id to int",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		userId = id
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectLeaveRequest + ` WHERE ($1 = '' OR q.status = $1) AND ($2 = 0 OR q.user_id = $2) AND q.tenant_id = $3
+		ORDER BY q.request_id DESC`
+	data, err := queryLeaveRequests(db, stmt, status, userId, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of leave request objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the caller's own leave requests, newest first
+func GetMyLeaveRequests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	data, err := queryLeaveRequests(db, selectLeaveRequest+` WHERE q.user_id = $1 ORDER BY q.request_id DESC`, principal.UserId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of leave request objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the pending requests of everyone reporting to the caller, oldest first
+func GetLeaveInbox(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := employee.ReportsCTE + ` ` + selectLeaveRequest + ` JOIN reports rp ON rp.user_id = q.user_id
+		WHERE q.status = 'pending' AND q.tenant_id = $2 ORDER BY q.request_id`
+	data, err := queryLeaveRequests(db, stmt, principal.UserId, principal.TenantId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of leave request objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching a single leave request
+func GetLeaveRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	request := LeaveRequestModel{}
+	//Extract request id from req params
+	params := mux.Vars(r)
+	requestId, err := strconv.Atoi(params["request_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	row := db.QueryRow(selectLeaveRequest+` WHERE q.request_id = $1 AND q.tenant_id = $2`, uint64(requestId), middleware.TenantId(r))
+	err = scanLeaveRequest(row, &request)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Leave request not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return leave request object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(request)
+}
+
+//For approving a pending request. The days are taken from the balance, which must still cover them
+func ApproveLeaveRequest(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "approved")
+}
+
+//For rejecting a pending request
+func RejectLeaveRequest(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "rejected")
+}
+
+//For withdrawing the caller's own request. Approved leave can be cancelled until it starts,
+//the days go back to the balance
+func CancelLeaveRequest(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "cancelled")
+}
+
+//Moves a request to status. Cancelling is for the requester, approving and rejecting for
+//anyone above the requester in the reporting lines
+func decide(w http.ResponseWriter, r *http.Request, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract request id from req params
+	params := mux.Vars(r)
+	requestId, err := strconv.Atoi(params["request_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	decision := DecisionModel{}
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//An impersonating admin must not decide in someone else's name
+	if principal.Impersonated() && status != "cancelled" {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Leave requests cannot be decided under impersonation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Lock the request so two managers cannot decide it at the same time
+	request := LeaveRequestModel{RequestId: uint64(requestId)}
+	var started, tracksBalance bool
+	stmt := `SELECT q.user_id, q.leave_type_id, q.status, q.days, q.start_date <= CURRENT_DATE, t.tracks_balance
+		FROM leave_requests q JOIN leave_types t ON t.leave_type_id = q.leave_type_id
+		WHERE q.request_id = $1 AND q.tenant_id = $2 FOR UPDATE OF q`
+	err = tx.QueryRow(stmt, request.RequestId, principal.TenantId).Scan(&request.UserId, &request.LeaveTypeId,
+		&request.Status, &request.Days, &started, &tracksBalance)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Leave request not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	allowed := false
+	if err == nil {
+		if status == "cancelled" {
+			allowed = request.UserId == principal.UserId
+		} else {
+			stmt = employee.ReportsCTE + ` SELECT EXISTS(SELECT 1 FROM reports WHERE user_id = $2)`
+			err = tx.QueryRow(stmt, principal.UserId, request.UserId).Scan(&allowed)
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if !allowed {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You are not allowed to decide this request",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg := ""
+	if status == "cancelled" && request.Status == "approved" && started {
+		msg = "Leave that has started cannot be cancelled"
+	} else if request.Status != "pending" && !(status == "cancelled" && request.Status == "approved") {
+		msg = "Leave request is already " + request.Status
+	}
+	if msg == "" && status == "approved" && tracksBalance {
+		var balance BalanceModel
+		balance, err = balanceOf(tx, request.UserId, principal.TenantId, request.LeaveTypeId)
+		if err == nil && balance.Balance < request.Days {
+			msg = "Insufficient balance, " + strconv.FormatFloat(balance.Balance, 'f', -1, 64) + " days left"
+		}
+	}
+	if err == nil && msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Approved days leave the balance, cancelling approved leave puts them back
+	if err == nil && tracksBalance && (status == "approved" || request.Status == "approved") {
+		kind, days := "taken", -request.Days
+		if status == "cancelled" {
+			kind, days = "reversal", request.Days
+		}
+		stmt = `INSERT INTO leave_ledger(user_id, tenant_id, leave_type_id, days, kind, request_id, created_by)
+			SELECT user_id, tenant_id, leave_type_id, $2, $3, request_id, $4 FROM leave_requests WHERE request_id = $1`
+		_, err = tx.Exec(stmt, request.RequestId, days, kind, principal.UserId)
+	}
+	if err == nil {
+		stmt = `UPDATE leave_requests SET status = $2, decided_by = $3, decision_note = NULLIF($4, ''),
+			decided_at = NOW() WHERE request_id = $1`
+		_, err = tx.Exec(stmt, request.RequestId, status, principal.UserId, decision.Note)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Leave request " + status,
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,73 @@
+package leave
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleLeaveRoutes(r *mux.Router) {
+	//Endpoint for creating a leave type
+	r.HandleFunc("/leave-types",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageLeaveTypes, AddLeaveType))).Methods("POST")
+
+	//Endpoint for fetching all leave types
+	r.HandleFunc("/leave-types",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadLeaveTypes, GetLeaveTypes))).Methods("GET")
+
+	//Endpoint for editing a leave type
+	r.HandleFunc("/leave-types/{leave_type_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageLeaveTypes, EditLeaveType))).Methods("PUT")
+
+	//Endpoint for deleting a leave type
+	r.HandleFunc("/leave-types/{leave_type_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageLeaveTypes, DeleteLeaveType))).Methods("DELETE")
+
+	//Endpoint for fetching the leave balances of an employee
+	r.HandleFunc("/employees/{user_id}/leave-balances",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivReadOwnLeave, Department: PrivReadDeptLeave, Any: PrivReadAllLeave,
+		}, GetBalances))).Methods("GET")
+
+	//Endpoint for fetching every change to the leave balances of an employee
+	r.HandleFunc("/employees/{user_id}/leave-ledger",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivReadOwnLeave, Department: PrivReadDeptLeave, Any: PrivReadAllLeave,
+		}, GetLedger))).Methods("GET")
+
+	//Endpoint for correcting the leave balance of an employee
+	r.HandleFunc("/employees/{user_id}/leave-adjustments",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivAdjustLeave, AddAdjustment))).Methods("POST")
+
+	//Endpoint for requesting leave for yourself
+	r.HandleFunc("/leave-requests",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRequestLeave, AddLeaveRequest))).Methods("POST")
+
+	//Endpoint for fetching all leave requests. ?status= and ?user_id= narrow it down
+	r.HandleFunc("/leave-requests",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadAllLeave, GetLeaveRequests))).Methods("GET")
+
+	//Endpoint for fetching the caller's own leave requests
+	r.HandleFunc("/leave-requests/mine",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRequestLeave, GetMyLeaveRequests))).Methods("GET")
+
+	//Endpoint for fetching the pending requests of employees reporting to the caller
+	r.HandleFunc("/leave-requests/inbox",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDecideLeave, GetLeaveInbox))).Methods("GET")
+
+	//Endpoint for fetching a single leave request
+	r.HandleFunc("/leave-requests/{request_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadAllLeave, GetLeaveRequest))).Methods("GET")
+
+	//Endpoint for approving a leave request
+	r.HandleFunc("/leave-requests/{request_id}/approve",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDecideLeave, ApproveLeaveRequest))).Methods("POST")
+
+	//Endpoint for rejecting a leave request
+	r.HandleFunc("/leave-requests/{request_id}/reject",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDecideLeave, RejectLeaveRequest))).Methods("POST")
+
+	//Endpoint for withdrawing your own leave request
+	r.HandleFunc("/leave-requests/{request_id}/cancel",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRequestLeave, CancelLeaveRequest))).Methods("POST")
+}
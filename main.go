@@ -2,17 +2,49 @@ package main
 
 import (
 	"fmt"
+	"hrm/catalog"
 	"hrm/db"
+	"hrm/document"
+	"hrm/grant"
+	"hrm/group"
+	"hrm/leave"
+	"hrm/performance"
 	"hrm/router"
+	"hrm/user"
+	"hrm/workflow"
 	"log"
 	"net/http"
+	"time"
 )
 
 func main() {
 	//Bringing in all the routes
 	r := router.Router()
 	//DB connection
-	db.ConnectDB() 
+	conn := db.ConnectDB()
+	//Make sure every privilege the routes require is declared and present in the database
+	if _, err := catalog.Sync(conn); err != nil {
+		log.Fatal(err)
+	}
+	conn.Close()
+	//Fail fast on a misconfigured document store rather than on the first upload
+	if _, err := document.OpenStore(); err != nil {
+		log.Fatal(err)
+	}
+	//Terminated users go through the offboarding workflow of their job title and department
+	user.OnTermination(workflow.StartOffboarding)
+	//Terminated users no longer answer the reviews of others
+	user.OnTermination(performance.DropAssignments)
+	//Revoke role and privilege grants once their valid_until has passed
+	go grant.SweepExpiredGrants(time.Minute)
+	//Re-evaluate the membership rules of dynamic groups
+	go group.SyncDynamicGroups(15 * time.Minute)
+	//Credit the monthly and yearly accruals of leave types
+	go leave.AccrueLeave(time.Hour)
+	//Apply the lifecycle status changes scheduled for today
+	go user.ApplyStatusChanges(time.Hour)
+	//Remove the contents of deleted documents from the document store
+	go document.PurgeDocuments(time.Minute)
 	
  	log.Fatal(http.ListenAndServe(":9000", r))
     fmt.Printf("Running")
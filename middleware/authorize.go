@@ -1,84 +1,262 @@
 package middleware
 
 import (
+	"database/sql"
 	"encoding/json"
+	"hrm/catalog"
 	"hrm/db"
 	"net/http"
+	"strconv"
 
-	"github.com/lib/pq"
+	"github.com/gorilla/mux"
 )
-type privModel struct {
-	PrivilegeName string 
+
+//Lets the holder act on any tenant by sending X-Tenant-Id
+var PrivCrossTenant = catalog.DeclarePlatform("cross_tenant_admin", "Act on any tenant with the X-Tenant-Id header")
+
+//Privileges checked by IsAuthorizeScoped, from the narrowest to the widest.
+//An empty field means that scope does not apply to the route.
+type Scope struct {
+	//Acting on the caller's own record
+	Own string
+	//Acting on a record of a user in the caller's group
+	Group string
+	//Acting on a record of a user in the caller's department or a department below it
+	Department string
+	//Acting on any record
+	Any string
+}
+
+//The privilege a denial is reported against: the narrowest one the caller holds, whose scope
+//was checked and did not cover the target, or else the widest one the route accepts
+func (s Scope) denied(privileges []string) string {
+	for _, privilege := range []string{s.Own, s.Group, s.Department} {
+		if privilege != "" && contains(privileges, privilege) {
+			return privilege
+		}
+	}
+	for _, privilege := range []string{s.Any, s.Department, s.Group, s.Own} {
+		if privilege != "" {
+			return privilege
+		}
+	}
+	return ""
+}
+
+//Writes the error response and returns ok=false if the caller's privileges cannot be loaded
+func loadPrivileges(w http.ResponseWriter, r *http.Request, db *sql.DB) (Principal, []string, bool) {
+	//Extract the principal from the context of the jwt middleware
+	principal, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		res := Response{
+			Error:   true,
+			Message: "Unable to extract permission info",
+		}
+		json.NewEncoder(w).Encode(res)
+		return principal, nil, false
+	}
+	//The tenants of the token and of the request must exist and be active
+	var active bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM tenants WHERE tenant_id = $1 AND active)
+		AND EXISTS(SELECT 1 FROM tenants WHERE tenant_id = $2 AND active)`
+	if err := db.QueryRow(stmt, principal.HomeTenantId, principal.TenantId).Scan(&active); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return principal, nil, false
+	}
+	if !active {
+		w.WriteHeader(http.StatusForbidden)
+		res := Response{
+			Error:   true,
+			Message: "Tenant not found or inactive",
+		}
+		json.NewEncoder(w).Encode(res)
+		return principal, nil, false
+	}
+	//Check the database for privileges currently granted to the user, directly, through the
+	//user's groups or through inherited roles, less those of roles in a dynamic SoD conflict
+	priviliges, err := EffectivePrivileges(db, principal.UserId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return principal, nil, false
+	}
+	if len(priviliges) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := Response{
+			Error:   true,
+			Message: "No permission info found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return principal, nil, false
+	}
+	//Only cross-tenant admins act on a tenant other than their own
+	if principal.TenantId != principal.HomeTenantId && !contains(priviliges, PrivCrossTenant) {
+		w.WriteHeader(http.StatusForbidden)
+		res := Response{
+			Error:   true,
+			Message: "Unauthorized: acting on another tenant requires " + PrivCrossTenant,
+		}
+		json.NewEncoder(w).Encode(res)
+		return principal, nil, false
+	}
+	return principal, priviliges, true
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusUnauthorized)
+	res := Response{
+		Error:   true,
+		Message: "Unauthorized",
+	}
+	json.NewEncoder(w).Encode(res)
 }
 
 func IsAuthorize(allowedPrivilege string, next http.HandlerFunc) http.HandlerFunc {
+	//Routes are registered at startup, catalog.Sync then checks every privilege is declared
+	catalog.Reference(allowedPrivilege)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		data := []privModel{}
-		//Extract Roleid from the context of the jwt middleware
-		roleId, ok := r.Context().Value("role_id").(int)
+		db := db.ConnectDB()
+		defer db.Close()
+		principal, priviliges, ok := loadPrivileges(w, r, db)
 		if !ok {
+			return
+		}
+		r = r.WithContext(withPrivileges(r.Context(), priviliges))
+		//Check if privileges slice contain privilege allowed for the this endpoint
+		condition := contains(priviliges, allowedPrivilege)
+		if !condition {
+			explainHeader(w, r, db, principal, priviliges, allowedPrivilege, "no role grants "+allowedPrivilege)
+			unauthorized(w)
+			return
+		}
+		//Attribute based policies can still deny what the role allows
+		if !checkPolicies(w, r, db, principal, priviliges, allowedPrivilege) {
+			return
+		}
+		explainHeader(w, r, db, principal, priviliges, allowedPrivilege, "")
+		next.ServeHTTP(w, r)
+
+	})
+}
+
+//Like IsAuthorize, but for routes acting on the user identified by the user_id route variable.
+//The caller passes if they hold scope.Any, or scope.Department and the target is currently
+//assigned to the caller's department or one below it, or scope.Group and the target is in
+//their group, or scope.Own and the target is themselves.
+func IsAuthorizeScoped(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	for _, privilege := range []string{scope.Own, scope.Group, scope.Department, scope.Any} {
+		if privilege != "" {
+			catalog.Reference(privilege)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		db := db.ConnectDB()
+		defer db.Close()
+		principal, priviliges, ok := loadPrivileges(w, r, db)
+		if !ok {
+			return
+		}
+		r = r.WithContext(withPrivileges(r.Context(), priviliges))
+		if scope.Any != "" && contains(priviliges, scope.Any) {
+			if checkPolicies(w, r, db, principal, priviliges, scope.Any) {
+				explainHeader(w, r, db, principal, priviliges, scope.Any, "")
+				next.ServeHTTP(w, r)
+			}
+			return
+		}
+		targetId, err := strconv.ParseUint(mux.Vars(r)["user_id"], 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
 			res := Response{
 				Error:   true,
-				Message: "Unable to extract permission info",
+				Message: "Unable to convert req params to int",
 			}
 			json.NewEncoder(w).Encode(res)
+			return
 		}
-		//Check the database for privileges assigned to this role
-		db := db.ConnectDB()
-		defer db.Close()
-		stmt := `SELECT privilege_name FROM privileges
-					WHERE privilege_name
-					IN
-		SELECT privilege_id FROM role_privileges WHERE role_id = $1`
-		rows, err := db.Query(stmt, uint64(roleId))
-		
-		//Check for all errors
-		if err, ok := err.(*pq.Error); ok {
-			if err.Code == "P0002" || err.Code == "02000" {
-				w.WriteHeader(http.StatusNotFound)
-				res := Response{
-					Error:   true,
-					Message: "No permission info found",
-				}
-				json.NewEncoder(w).Encode(res)
-			}else {
+		if scope.Own != "" && contains(priviliges, scope.Own) && targetId == principal.UserId {
+			if checkPolicies(w, r, db, principal, priviliges, scope.Own) {
+				explainHeader(w, r, db, principal, priviliges, scope.Own, "")
+				next.ServeHTTP(w, r)
+			}
+			return
+		}
+		if scope.Group != "" && contains(priviliges, scope.Group) {
+			sameGroup, err := inSameGroup(db, principal.UserId, targetId)
+			if err != nil {
 				w.WriteHeader(http.StatusInternalServerError)
 				res := Response{
-					Error: true,
+					Error:   true,
 					Message: "Internal server error" + err.Error(),
 				}
 				json.NewEncoder(w).Encode(res)
+				return
+			}
+			if sameGroup {
+				if checkPolicies(w, r, db, principal, priviliges, scope.Group) {
+					explainHeader(w, r, db, principal, priviliges, scope.Group, "")
+					next.ServeHTTP(w, r)
+				}
+				return
 			}
 		}
-		defer rows.Close()
-		for rows.Next() {
-			pName := privModel{}
-			err := rows.Scan(&pName.PrivilegeName)
+		if scope.Department != "" && contains(priviliges, scope.Department) {
+			below, err := inDepartment(db, principal.UserId, targetId)
 			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
 				res := Response{
 					Error:   true,
-					Message: "Unable to scan permission variables",
+					Message: "Internal server error" + err.Error(),
 				}
 				json.NewEncoder(w).Encode(res)
+				return
 			}
-			data = append(data, pName)
-			//Convert above data struct to a slice of string
-			var priviliges []string
-			for _, v := range data {
-				priviliges = append(priviliges, v.PrivilegeName)
-			}
-			//Check if privileges slice contain privilege allowed for the this endpoint
-			condition := contains(priviliges, allowedPrivilege)
-			if !condition  {
-				res := Response{
-					Error:   true,
-					Message: "Unauthorized",
+			if below {
+				if checkPolicies(w, r, db, principal, priviliges, scope.Department) {
+					explainHeader(w, r, db, principal, priviliges, scope.Department, "")
+					next.ServeHTTP(w, r)
 				}
-				json.NewEncoder(w).Encode(res)
+				return
 			}
-
 		}
-		next.ServeHTTP(w, r)
-
+		explainHeader(w, r, db, principal, priviliges, scope.denied(priviliges), "no role grants a privilege whose scope covers user "+mux.Vars(r)["user_id"])
+		unauthorized(w)
 	})
 }
+
+//Two users are in the same group when they are currently members of at least one common group,
+//directly, through a group rule or through nested groups
+func inSameGroup(db *sql.DB, userId, otherId uint64) (bool, error) {
+	stmt := `WITH RECURSIVE ` + UserGroupsCTE(`user_id IN ($1, $2)`) + `
+		SELECT EXISTS(SELECT 1 FROM user_groups a JOIN user_groups b ON a.group_id = b.group_id
+			WHERE a.user_id = $1 AND b.user_id = $2)`
+	var same bool
+	err := db.QueryRow(stmt, userId, otherId).Scan(&same)
+	return same, err
+}
+
+//A user is in the department of another when their current assignment is to the other's
+//current department or to a department below it
+func inDepartment(db *sql.DB, userId, otherId uint64) (bool, error) {
+	stmt := `WITH RECURSIVE current(user_id, department_id) AS (
+			SELECT user_id, department_id FROM employee_assignments
+			WHERE effective_from <= CURRENT_DATE AND (effective_to IS NULL OR effective_to >= CURRENT_DATE)
+		), below(department_id) AS (
+			SELECT department_id FROM current WHERE user_id = $1
+		UNION
+			SELECT d.department_id FROM departments d JOIN below b ON d.parent_department_id = b.department_id
+		)
+		SELECT EXISTS(SELECT 1 FROM current c JOIN below b ON b.department_id = c.department_id WHERE c.user_id = $2)`
+	var below bool
+	err := db.QueryRow(stmt, userId, otherId).Scan(&below)
+	return below, err
+}
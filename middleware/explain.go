@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"database/sql"
+	"hrm/catalog"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+//Callers holding this privilege can ask for the X-Authz-Explain response header
+//by sending X-Authz-Debug on any protected request
+var PrivExplainAuthz = catalog.Declare("explain_authz", "Explain authorization decisions")
+
+//Same walk as userRolesExceptCTE, but keeps the path that led to each group and role. The path
+//checks replace the UNION dedup, which no longer applies once rows carry their path.
+const userRolePathsCTE = `WITH RECURSIVE user_groups(group_id, path) AS (
+		SELECT g.group_id, ARRAY['user:' || u.username, 'group:' || g.group_name]
+		FROM group_members m JOIN users u ON u.user_id = m.user_id JOIN groups g ON g.group_id = m.group_id
+		WHERE m.user_id = $1 AND (m.expires_at IS NULL OR m.expires_at > NOW())
+	UNION
+		SELECT g.group_id, ARRAY['user:' || u.username, 'rule:' || g.group_name]
+		FROM group_rule_members m JOIN users u ON u.user_id = m.user_id JOIN groups g ON g.group_id = m.group_id
+		WHERE m.user_id = $1
+	UNION
+		SELECT p.group_id, ug.path || ('group:' || p.group_name)
+		FROM group_hierarchy gh JOIN user_groups ug ON gh.child_group_id = ug.group_id
+		JOIN groups p ON p.group_id = gh.parent_group_id
+		WHERE NOT ('group:' || p.group_name) = ANY(ug.path)
+	), effective_roles(role_id, path) AS (
+		SELECT u.role_id, ARRAY['user:' || u.username, 'role:' || r.role_name]
+		FROM users u JOIN roles r ON r.role_id = u.role_id
+		WHERE u.user_id = $1
+		AND (u.role_valid_from IS NULL OR u.role_valid_from <= NOW())
+		AND (u.role_valid_until IS NULL OR u.role_valid_until > NOW())
+		AND u.role_id <> ALL($3::BIGINT[])
+	UNION
+		SELECT gr.role_id, ug.path || ('role:' || r.role_name)
+		FROM group_roles gr JOIN user_groups ug ON ug.group_id = gr.group_id
+		JOIN roles r ON r.role_id = gr.role_id
+		WHERE (gr.valid_from IS NULL OR gr.valid_from <= NOW())
+		AND (gr.valid_until IS NULL OR gr.valid_until > NOW())
+		AND gr.role_id <> ALL($3::BIGINT[])
+	UNION
+		SELECT a.role_id, ARRAY['user:' || u.username, 'elevation:' || r.role_name]
+		FROM role_activations a JOIN users u ON u.user_id = a.user_id JOIN roles r ON r.role_id = a.role_id
+		WHERE a.user_id = $1 AND a.status = 'active' AND a.expires_at > NOW()
+		AND a.role_id <> ALL($3::BIGINT[])
+	UNION
+		SELECT rh.child_role_id, er.path || ('role:' || r.role_name)
+		FROM role_hierarchy rh JOIN effective_roles er ON rh.parent_role_id = er.role_id
+		JOIN roles r ON r.role_id = rh.child_role_id
+		WHERE NOT ('role:' || r.role_name) = ANY(er.path)
+		AND rh.child_role_id <> ALL($3::BIGINT[])
+	)`
+
+//Returns every path through which userId currently holds privilege, e.g.
+//[user:alice group:hr role:hr_manager role:hr_staff privilege:read_all_users].
+//Paths through roles in a dynamic separation-of-duties conflict are left out, as
+//loadPrivileges drops their privileges. No path means the user does not get the privilege.
+func ExplainPrivilege(db *sql.DB, userId uint64, privilege string) ([][]string, error) {
+	_, conflicting, err := dynamicConflicts(db, userId)
+	if err != nil {
+		return nil, err
+	}
+	stmt := userRolePathsCTE + ` SELECT er.path || ('privilege:' || p.privilege_name)
+		FROM effective_roles er JOIN role_privileges rp ON rp.role_id = er.role_id
+		JOIN privileges p ON p.privilege_id = rp.privilege_id
+		WHERE p.privilege_name = $2
+		AND (rp.valid_from IS NULL OR rp.valid_from <= NOW())
+		AND (rp.valid_until IS NULL OR rp.valid_until > NOW())
+		ORDER BY array_length(er.path, 1)`
+	rows, err := db.Query(stmt, userId, privilege, pq.Array(int64s(conflicting)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var paths [][]string
+	for rows.Next() {
+		var path []string
+		if err := rows.Scan(pq.Array(&path)); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+//Returns why userId does not get a privilege they hold only through roles in a dynamic
+//separation-of-duties conflict, or "" if the privilege was not dropped for a conflict
+func SoDDropReason(db *sql.DB, userId uint64, privilege string) (string, error) {
+	violations, conflicting, err := dynamicConflicts(db, userId)
+	if err != nil || len(violations) == 0 {
+		return "", err
+	}
+	held, err := UserPrivileges(db, userId)
+	if err != nil || !contains(held, privilege) {
+		return "", err
+	}
+	kept, err := UserPrivilegesExcept(db, userId, conflicting)
+	if err != nil || contains(kept, privilege) {
+		return "", err
+	}
+	var names []string
+	for _, v := range violations {
+		names = append(names, v.ConstraintName)
+	}
+	return "only roles in conflict under " + strings.Join(names, ", ") + " grant " + privilege, nil
+}
+
+//Sets X-Authz-Explain when the caller sent X-Authz-Debug and holds explain_authz.
+//An empty reason means the request was allowed and the derivation paths are listed instead.
+func explainHeader(w http.ResponseWriter, r *http.Request, db *sql.DB, principal Principal, privileges []string, privilege, reason string) {
+	if r.Header.Get("X-Authz-Debug") == "" || !contains(privileges, PrivExplainAuthz) {
+		return
+	}
+	if reason != "" {
+		//A privilege the caller lacks may have been dropped for a separation-of-duties conflict
+		if !contains(privileges, privilege) {
+			if dropped, err := SoDDropReason(db, principal.UserId, privilege); err == nil && dropped != "" {
+				reason = dropped
+			}
+		}
+		w.Header().Set("X-Authz-Explain", "deny "+privilege+": "+reason)
+		return
+	}
+	paths, err := ExplainPrivilege(db, principal.UserId, privilege)
+	if err != nil {
+		w.Header().Set("X-Authz-Explain", "allow "+privilege+": "+err.Error())
+		return
+	}
+	for _, path := range paths {
+		w.Header().Add("X-Authz-Explain", "allow "+privilege+": "+strings.Join(path, " > "))
+	}
+}
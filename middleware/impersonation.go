@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"database/sql"
+	"hrm/db"
+	"log"
+	"net/http"
+)
+
+//Remembers the status written by the handler so it can be audited
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+//Records an impersonation event against both the admin and the impersonated user
+func RecordImpersonation(db *sql.DB, principal Principal, event, method, path string, status int) error {
+	stmt := `INSERT INTO impersonation_audit(actor_id, subject_id, event, method, path, status, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := db.Exec(stmt, principal.ActorId, principal.UserId, event, method, path, status, principal.TenantId)
+	return err
+}
+
+//Runs next for a request made under an impersonation token, then writes it to the audit trail.
+//Both identities are also echoed back so clients can tell they are not acting as themselves.
+func auditImpersonated(w http.ResponseWriter, r *http.Request, principal Principal, next http.HandlerFunc) {
+	w.Header().Set("X-Impersonated-By", principal.ActorUsername)
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	next(rec, r)
+	db := db.ConnectDB()
+	defer db.Close()
+	if err := RecordImpersonation(db, principal, "request", r.Method, r.URL.Path, rec.status); err != nil {
+		log.Printf("impersonation audit: %d as %d %s %s: %v", principal.ActorId, principal.UserId, r.Method, r.URL.Path, err)
+	}
+}
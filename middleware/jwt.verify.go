@@ -1,11 +1,11 @@
 package middleware
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/joho/godotenv"
@@ -41,13 +41,48 @@ func JwtVerify(next func(http.ResponseWriter, *http.Request)) http.HandlerFunc {
 				return MySigningKey, nil
 			})
 			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
 				fmt.Fprint(w, err.Error())
+				return
 			}
 
 			if token.Valid {
-				type context_key string
-  ctx := context.WithValue(r.Context(), context_key("role_id"), token)
-   next(w, r.WithContext(ctx))
+				//Store who is calling in the context for the authorization middlewares
+				principal, ok := principalFromClaims(token.Claims.(jwt.MapClaims))
+				if !ok {
+					w.WriteHeader(http.StatusUnauthorized)
+					fmt.Fprintf(w, "Unauthorized!")
+					return
+				}
+				//Cross-tenant admins pick the tenant to act on, IsAuthorize checks they may
+				if tenant := r.Header.Get("X-Tenant-Id"); tenant != "" {
+					tenantId, err := strconv.ParseUint(tenant, 10, 64)
+					if err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						fmt.Fprintf(w, "Invalid X-Tenant-Id")
+						return
+					}
+					principal.TenantId = tenantId
+				}
+				//Locked out accounts and revoked tokens are turned away before anything runs
+				issuedAt, _ := token.Claims.(jwt.MapClaims)["iat"].(float64)
+				msg, err := tokenRevoked(principal, int64(issuedAt))
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					fmt.Fprint(w, err.Error())
+					return
+				}
+				if msg != "" {
+					w.WriteHeader(http.StatusUnauthorized)
+					fmt.Fprint(w, msg)
+					return
+				}
+				ctx := WithPrincipal(r.Context(), principal)
+				if principal.Impersonated() {
+					auditImpersonated(w, r.WithContext(ctx), principal, next)
+					return
+				}
+				next(w, r.WithContext(ctx))
 			}
 
 		} else {
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"hrm/db"
+)
+
+//Lifecycle statuses of users who are locked out. Suspended and terminated users cannot
+//authenticate and the tokens they hold are rejected
+var LockedStatuses = []string{"suspended", "terminated"}
+
+//Reports whether a user in the given lifecycle status is locked out
+func StatusLocked(status string) bool {
+	return contains(LockedStatuses, status)
+}
+
+//Returns why a token issued at issuedAt can no longer be used by principal, or an empty
+//string. Tokens stop working once the user, or the admin impersonating them, is locked out
+//or no longer exists, and when they were issued before the user's tokens were revoked
+func tokenRevoked(principal Principal, issuedAt int64) (string, error) {
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT user_id, status, COALESCE(tokens_valid_after > TO_TIMESTAMP($3)::TIMESTAMP, FALSE)
+		FROM users WHERE user_id IN ($1, $2)`
+	rows, err := db.Query(stmt, principal.UserId, principal.ActorId, issuedAt)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	found := map[uint64]bool{}
+	for rows.Next() {
+		var userId uint64
+		var status string
+		var revoked bool
+		if err := rows.Scan(&userId, &status, &revoked); err != nil {
+			return "", err
+		}
+		found[userId] = true
+		who := "Account"
+		if userId == principal.ActorId {
+			who = "Impersonating account"
+		}
+		if StatusLocked(status) {
+			return who + " is " + status, nil
+		}
+		if revoked {
+			return "Token revoked", nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if !found[principal.UserId] || (principal.Impersonated() && !found[principal.ActorId]) {
+		return "Account not found", nil
+	}
+	return "", nil
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"hrm/policy/rule"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+//Loads the attributes policy conditions can refer to
+func policyAttributes(db *sql.DB, r *http.Request, principal Principal, action string) (rule.Attributes, error) {
+	now := time.Now()
+	attrs := rule.Attributes{
+		"action":             action,
+		"principal.user_id":  principal.UserId,
+		"principal.username": principal.Username,
+		"principal.role_id":  principal.RoleId,
+		"env.hour":           now.Hour(),
+		"env.minute":         now.Minute(),
+		"env.weekday":        int(now.Weekday()),
+		"env.date":           now.Format("2006-01-02"),
+		"env.ip":             clientIP(r),
+		"env.method":         r.Method,
+		"env.path":           r.URL.Path,
+
+		//Lets policies deny actions made under impersonation
+		"principal.impersonated": principal.Impersonated(),
+		"principal.actor_id":     principal.ActorId,
+		"principal.tenant_id":    principal.TenantId,
+	}
+	//Every group the user is in, directly, by rule or through a child group
+	stmt := `WITH RECURSIVE ` + userGroupsCTE + ` SELECT group_id FROM user_groups`
+	groupIds, err := queryIds(db, stmt, principal.UserId)
+	if err != nil {
+		return nil, err
+	}
+	attrs["principal.group_ids"] = groupIds
+	//Routes acting on a user expose that user as the resource
+	if targetId, err := strconv.ParseUint(mux.Vars(r)["user_id"], 10, 64); err == nil {
+		attrs["resource.type"] = "user"
+		attrs["resource.user_id"] = targetId
+		attrs["resource.own"] = targetId == principal.UserId
+		targetGroupIds, err := queryIds(db, stmt, targetId)
+		if err != nil {
+			return nil, err
+		}
+		attrs["resource.group_ids"] = targetGroupIds
+	}
+	return attrs, nil
+}
+
+//Proxies whose X-Forwarded-For is believed, from TRUSTED_PROXIES: addresses or CIDR ranges
+//separated by commas. Without it the header is ignored
+var trustedProxies = parseProxies(os.Getenv("TRUSTED_PROXIES"))
+
+func parseProxies(value string) []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("middleware: ignoring trusted proxy %q: %v", entry, err)
+			continue
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies
+}
+
+func isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//Returns the address the request came from. Clients can send any X-Forwarded-For, so it
+//is only read behind a trusted proxy, from the right: the client is the last hop the
+//trusted proxies did not add themselves
+func clientIP(r *http.Request) string {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+	if !isTrustedProxy(client) {
+		return client
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		client = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return client
+}
+
+//Runs after the role check. A matching deny policy always denies. If permit policies
+//apply to the action, at least one of them has to match. No applicable policy means allow.
+//Returns the reason when the request is denied.
+func EvaluatePolicies(db *sql.DB, r *http.Request, principal Principal, action string) (bool, string, error) {
+	stmt := `SELECT p.policy_name, v.effect, v.condition FROM policies p
+		JOIN policy_versions v ON v.policy_id = p.policy_id AND v.version = p.current_version
+		WHERE p.active AND p.tenant_id = $2 AND ($1 = ANY(v.actions) OR '*' = ANY(v.actions))`
+	rows, err := db.Query(stmt, action, principal.TenantId)
+	if err != nil {
+		return false, "", err
+	}
+	defer rows.Close()
+	type applicable struct {
+		name, effect, condition string
+	}
+	var policies []applicable
+	for rows.Next() {
+		p := applicable{}
+		if err := rows.Scan(&p.name, &p.effect, &p.condition); err != nil {
+			return false, "", err
+		}
+		policies = append(policies, p)
+	}
+	if err := rows.Err(); err != nil {
+		return false, "", err
+	}
+	if len(policies) == 0 {
+		return true, "", nil
+	}
+	attrs, err := policyAttributes(db, r, principal, action)
+	if err != nil {
+		return false, "", err
+	}
+	permits, permitted := 0, false
+	for _, p := range policies {
+		condition, err := rule.Parse(p.condition)
+		if err != nil {
+			return false, "", fmt.Errorf("policy %s: %v", p.name, err)
+		}
+		matched, err := condition.Eval(attrs)
+		if err != nil {
+			return false, "", fmt.Errorf("policy %s: %v", p.name, err)
+		}
+		if p.effect == "deny" && matched {
+			return false, "denied by policy " + p.name, nil
+		}
+		if p.effect == "permit" {
+			permits++
+			permitted = permitted || matched
+		}
+	}
+	if permits > 0 && !permitted {
+		return false, "no permit policy matched for " + action, nil
+	}
+	return true, "", nil
+}
+
+//Writes the error response and returns false if a policy denies the request
+func checkPolicies(w http.ResponseWriter, r *http.Request, db *sql.DB, principal Principal, privileges []string, action string) bool {
+	allowed, reason, err := EvaluatePolicies(db, r, principal, action)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return false
+	}
+	if !allowed {
+		explainHeader(w, r, db, principal, privileges, action, reason)
+		w.WriteHeader(http.StatusForbidden)
+		res := Response{
+			Error:   true,
+			Message: "Unauthorized: " + reason,
+		}
+		json.NewEncoder(w).Encode(res)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+//The authenticated caller of a request, built by JwtVerify from the token claims
+type Principal struct {
+	UserId   uint64
+	Username string
+	RoleId   uint64
+	//Set when an admin is impersonating the user above, from the act claim of the token.
+	//Authorization is done as the user, audit records both.
+	ActorId       uint64
+	ActorUsername string
+	//Tenant of the user, from the tenant claim of the token
+	HomeTenantId uint64
+	//Tenant the request acts on. The home tenant unless the caller picked another one
+	//with X-Tenant-Id, which IsAuthorize only allows with cross_tenant_admin
+	TenantId uint64
+}
+
+//Reports whether the request is made under an impersonation token
+func (p Principal) Impersonated() bool {
+	return p.ActorId != 0
+}
+
+type contextKey string
+
+const principalKey contextKey = "principal"
+
+const privilegesKey contextKey = "privileges"
+
+//Reads the claims written by GenerateJWT
+func principalFromClaims(claims jwt.MapClaims) (Principal, bool) {
+	p := Principal{}
+	username, ok := claims["email"].(string)
+	if !ok {
+		return p, false
+	}
+	p.Username = username
+	userId, ok := claims["userId"].(string)
+	if !ok {
+		return p, false
+	}
+	id, err := strconv.ParseUint(userId, 10, 64)
+	if err != nil {
+		return p, false
+	}
+	p.UserId = id
+	//Every token is issued for one tenant
+	tenantId, ok := claims["tenantId"].(string)
+	if !ok {
+		return p, false
+	}
+	p.HomeTenantId, err = strconv.ParseUint(tenantId, 10, 64)
+	if err != nil {
+		return p, false
+	}
+	p.TenantId = p.HomeTenantId
+	//A user without a role is still a valid principal, it just holds no privileges
+	if roleId, ok := claims["roleId"].(string); ok && roleId != "" {
+		id, err := strconv.ParseUint(roleId, 10, 64)
+		if err != nil {
+			return p, false
+		}
+		p.RoleId = id
+	}
+	//Impersonation tokens name the admin acting as the user in the act claim
+	if act, ok := claims["act"].(map[string]interface{}); ok {
+		actorId, ok := act["sub"].(string)
+		if !ok {
+			return p, false
+		}
+		id, err := strconv.ParseUint(actorId, 10, 64)
+		if err != nil || id == p.UserId {
+			return p, false
+		}
+		p.ActorId = id
+		p.ActorUsername, _ = act["email"].(string)
+	}
+	return p, true
+}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+//Returns the principal stored in the request context by JwtVerify
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey).(Principal)
+	return p, ok
+}
+
+func withPrivileges(ctx context.Context, privileges []string) context.Context {
+	return context.WithValue(ctx, privilegesKey, privileges)
+}
+
+//Returns the privileges the caller gets, as loaded by IsAuthorize. Handlers refining the
+//route's decision check these rather than the database, so they agree with the middleware
+func PrivilegesFromContext(ctx context.Context) []string {
+	privileges, _ := ctx.Value(privilegesKey).([]string)
+	return privileges
+}
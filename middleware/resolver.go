@@ -0,0 +1,163 @@
+package middleware
+
+import "github.com/lib/pq"
+
+//Walks the role hierarchy down from roleId. UNION (not UNION ALL) stops the walk
+//even if a cycle slipped into role_hierarchy.
+const effectiveRolesCTE = `WITH RECURSIVE effective_roles(role_id) AS (
+		SELECT CAST($1 AS BIGINT)
+	UNION
+		SELECT rh.child_role_id FROM role_hierarchy rh
+		JOIN effective_roles er ON rh.parent_role_id = er.role_id
+	)`
+
+//Walks the group hierarchy up from the unexpired memberships of the users matching cond, a
+//condition on user_id, and the dynamic groups whose rule holds for them. A member of a group is
+//a member of every group containing it. Goes into a WITH RECURSIVE as user_groups(user_id, group_id).
+func UserGroupsCTE(cond string) string {
+	return `user_groups(user_id, group_id) AS (
+		SELECT user_id, group_id FROM group_members
+		WHERE (` + cond + `) AND (expires_at IS NULL OR expires_at > NOW())
+	UNION
+		SELECT user_id, group_id FROM group_rule_members WHERE (` + cond + `)
+	UNION
+		SELECT ug.user_id, gh.parent_group_id FROM group_hierarchy gh
+		JOIN user_groups ug ON gh.child_group_id = ug.group_id
+	)`
+}
+
+//The groups of user $1
+var userGroupsCTE = UserGroupsCTE("user_id = $1")
+
+//Same walk, starting from the role assigned to user $1, the roles of the user's groups and
+//the roles the user has elevated into. Grants outside their validity window are ignored,
+//NULL bounds are open ended.
+var userRolesCTE = `WITH RECURSIVE ` + userGroupsCTE + `, effective_roles(role_id) AS (
+		SELECT role_id FROM users WHERE user_id = $1 AND role_id IS NOT NULL
+		AND (role_valid_from IS NULL OR role_valid_from <= NOW())
+		AND (role_valid_until IS NULL OR role_valid_until > NOW())
+	UNION
+		SELECT gr.role_id FROM group_roles gr JOIN user_groups ug ON ug.group_id = gr.group_id
+		WHERE (gr.valid_from IS NULL OR gr.valid_from <= NOW())
+		AND (gr.valid_until IS NULL OR gr.valid_until > NOW())
+	UNION
+		SELECT role_id FROM role_activations
+		WHERE user_id = $1 AND status = 'active' AND expires_at > NOW()
+	UNION
+		SELECT rh.child_role_id FROM role_hierarchy rh
+		JOIN effective_roles er ON rh.parent_role_id = er.role_id
+	)`
+
+//Same walk as userRolesCTE, except that it does not enter the roles in $2 nor reach what
+//they inherit through them
+var userRolesExceptCTE = `WITH RECURSIVE ` + userGroupsCTE + `, effective_roles(role_id) AS (
+		SELECT role_id FROM users WHERE user_id = $1 AND role_id IS NOT NULL
+		AND (role_valid_from IS NULL OR role_valid_from <= NOW())
+		AND (role_valid_until IS NULL OR role_valid_until > NOW())
+		AND role_id <> ALL($2::BIGINT[])
+	UNION
+		SELECT gr.role_id FROM group_roles gr JOIN user_groups ug ON ug.group_id = gr.group_id
+		WHERE (gr.valid_from IS NULL OR gr.valid_from <= NOW())
+		AND (gr.valid_until IS NULL OR gr.valid_until > NOW())
+		AND gr.role_id <> ALL($2::BIGINT[])
+	UNION
+		SELECT role_id FROM role_activations
+		WHERE user_id = $1 AND status = 'active' AND expires_at > NOW()
+		AND role_id <> ALL($2::BIGINT[])
+	UNION
+		SELECT rh.child_role_id FROM role_hierarchy rh
+		JOIN effective_roles er ON rh.parent_role_id = er.role_id
+		WHERE rh.child_role_id <> ALL($2::BIGINT[])
+	)`
+
+const privilegesOfEffectiveRoles = ` SELECT DISTINCT p.privilege_name FROM privileges p
+		JOIN role_privileges rp ON rp.privilege_id = p.privilege_id
+		JOIN effective_roles er ON er.role_id = rp.role_id
+		WHERE (rp.valid_from IS NULL OR rp.valid_from <= NOW())
+		AND (rp.valid_until IS NULL OR rp.valid_until > NOW())`
+
+//Returns the ids of roleId and every role it inherits from
+func EffectiveRoles(q Queryer, roleId uint64) ([]uint64, error) {
+	stmt := effectiveRolesCTE + ` SELECT role_id FROM effective_roles`
+	return queryIds(q, stmt, roleId)
+}
+
+//Returns the ids of every role currently in force for userId: the role assigned
+//to the user, the roles of the user's groups, active elevations and everything they inherit from
+func UserRoles(q Queryer, userId uint64) ([]uint64, error) {
+	stmt := userRolesCTE + ` SELECT role_id FROM effective_roles`
+	return queryIds(q, stmt, userId)
+}
+
+//Returns the names of all privileges granted to roleId, including the ones
+//inherited from its child roles
+func RolePrivileges(q Queryer, roleId uint64) ([]string, error) {
+	return queryNames(q, effectiveRolesCTE+privilegesOfEffectiveRoles, roleId)
+}
+
+//Returns the names of all privileges currently in force for userId
+func UserPrivileges(q Queryer, userId uint64) ([]string, error) {
+	return queryNames(q, userRolesCTE+privilegesOfEffectiveRoles, userId)
+}
+
+//Returns the names of the privileges in force for userId that do not come from the roles in
+//roleIds, the roles the user holds in breach of a dynamic separation-of-duties constraint
+func UserPrivilegesExcept(q Queryer, userId uint64, roleIds []uint64) ([]string, error) {
+	return queryNames(q, userRolesExceptCTE+privilegesOfEffectiveRoles, userId, pq.Array(int64s(roleIds)))
+}
+
+//Returns the privileges userId gets right now: those in force, less the ones of the roles the
+//user holds in breach of a dynamic separation-of-duties constraint. Refusing every request would
+//also lock the user out of the routes that resolve the conflict, so only those are dropped
+func EffectivePrivileges(q Queryer, userId uint64) ([]string, error) {
+	_, conflicting, err := dynamicConflicts(q, userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflicting) == 0 {
+		return UserPrivileges(q, userId)
+	}
+	return UserPrivilegesExcept(q, userId, conflicting)
+}
+
+func int64s(ids []uint64) []int64 {
+	values := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, int64(id))
+	}
+	return values
+}
+
+func queryIds(q Queryer, stmt string, args ...interface{}) ([]uint64, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func queryNames(q Queryer, stmt string, args ...interface{}) ([]string, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
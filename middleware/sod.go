@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+//Satisfied by both *sql.DB and *sql.Tx, so grants can be checked before they are committed
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+//A user holding more roles of a separation-of-duties constraint than it allows
+type Violation struct {
+	ConstraintName string   `json:"constraint_name"`
+	Kind           string   `json:"kind"`
+	UserId         uint64   `json:"user_id"`
+	Username       string   `json:"username"`
+	Roles          []string `json:"roles"`
+	RoleIds        []uint64 `json:"role_ids"`
+	MaxRoles       int      `json:"max_roles"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s would hold %s but %s allows at most %d of them",
+		v.Username, strings.Join(v.Roles, ", "), v.ConstraintName, v.MaxRoles)
+}
+
+//Roles held per user, through the user's role, the user's groups, elevation and the role
+//hierarchy. $1 limits the users (NULL for all), $2 limits the walk to grants currently in
+//force: active elevations then count, otherwise every role the user is eligible for does.
+var heldRolesCTE = `WITH RECURSIVE ` + UserGroupsCTE(`$1::BIGINT[] IS NULL OR user_id = ANY($1)`) + `, held(user_id, role_id) AS (
+		SELECT user_id, role_id FROM users
+		WHERE role_id IS NOT NULL AND ($1::BIGINT[] IS NULL OR user_id = ANY($1))
+		AND (NOT $2 OR ((role_valid_from IS NULL OR role_valid_from <= NOW())
+		AND (role_valid_until IS NULL OR role_valid_until > NOW())))
+	UNION
+		SELECT ug.user_id, gr.role_id FROM group_roles gr JOIN user_groups ug ON ug.group_id = gr.group_id
+		WHERE (NOT $2 OR ((gr.valid_from IS NULL OR gr.valid_from <= NOW())
+		AND (gr.valid_until IS NULL OR gr.valid_until > NOW())))
+	UNION
+		SELECT user_id, role_id FROM role_eligibilities
+		WHERE NOT $2 AND ($1::BIGINT[] IS NULL OR user_id = ANY($1))
+	UNION
+		SELECT user_id, role_id FROM role_activations
+		WHERE $2 AND status = 'active' AND expires_at > NOW() AND ($1::BIGINT[] IS NULL OR user_id = ANY($1))
+	UNION
+		SELECT h.user_id, rh.child_role_id FROM role_hierarchy rh
+		JOIN held h ON rh.parent_role_id = h.role_id
+	)`
+
+//Returns the violations of constraints of the given kind for userIds, nil meaning all users.
+//Static constraints count every grant, including ones not yet or no longer in force,
+//dynamic constraints only count the grants in force right now.
+func SoDViolations(q Queryer, kind string, userIds []uint64) ([]Violation, error) {
+	return sodViolations(q, 0, kind, userIds)
+}
+
+//Returns the violations of constraints of the given kind by all users of tenantId
+func TenantSoDViolations(q Queryer, tenantId uint64, kind string) ([]Violation, error) {
+	return sodViolations(q, tenantId, kind, nil)
+}
+
+//tenantId 0 means any tenant. Users only hold roles of their own tenant, so
+//constraints never span tenants
+func sodViolations(q Queryer, tenantId uint64, kind string, userIds []uint64) ([]Violation, error) {
+	var ids []int64
+	if userIds != nil {
+		ids = make([]int64, 0, len(userIds))
+		for _, id := range userIds {
+			ids = append(ids, int64(id))
+		}
+	}
+	stmt := heldRolesCTE + ` SELECT c.constraint_name, c.kind, h.user_id, u.username,
+			array_agg(DISTINCT r.role_name), array_agg(DISTINCT r.role_id), c.max_roles
+		FROM sod_constraints c
+		JOIN sod_constraint_roles cr ON cr.constraint_id = c.constraint_id
+		JOIN held h ON h.role_id = cr.role_id
+		JOIN roles r ON r.role_id = h.role_id
+		JOIN users u ON u.user_id = h.user_id
+		WHERE c.kind = $3 AND ($4 = 0 OR c.tenant_id = $4)
+		GROUP BY c.constraint_id, c.constraint_name, c.kind, c.max_roles, h.user_id, u.username
+		HAVING COUNT(DISTINCT h.role_id) > c.max_roles`
+	rows, err := q.Query(stmt, pq.Array(ids), kind == "dynamic", kind, tenantId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var violations []Violation
+	for rows.Next() {
+		v := Violation{}
+		var roleIds []int64
+		err := rows.Scan(&v.ConstraintName, &v.Kind, &v.UserId, &v.Username, pq.Array(&v.Roles),
+			pq.Array(&roleIds), &v.MaxRoles)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range roleIds {
+			v.RoleIds = append(v.RoleIds, uint64(id))
+		}
+		violations = append(violations, v)
+	}
+	return violations, rows.Err()
+}
+
+//Returns the dynamic violations of userId and the roles involved in them. The user does not
+//get the privileges of these roles while the conflict lasts
+func dynamicConflicts(q Queryer, userId uint64) ([]Violation, []uint64, error) {
+	violations, err := SoDViolations(q, "dynamic", []uint64{userId})
+	if err != nil {
+		return nil, nil, err
+	}
+	var roleIds []uint64
+	for _, v := range violations {
+		roleIds = append(roleIds, v.RoleIds...)
+	}
+	return violations, roleIds, nil
+}
+
+//Called by every grant path inside the transaction making the grant. Returns a message
+//describing the first static violation for userIds, or "" if the grant is acceptable.
+func CheckStaticSoD(q Queryer, userIds []uint64) (string, error) {
+	if len(userIds) == 0 {
+		return "", nil
+	}
+	violations, err := SoDViolations(q, "static", userIds)
+	if err != nil || len(violations) == 0 {
+		return "", err
+	}
+	return "Separation of duties: " + violations[0].String(), nil
+}
+
+//Returns the ids of the users holding roleId directly, through their group or by inheritance
+func UsersHoldingRole(q Queryer, roleId uint64) ([]uint64, error) {
+	stmt := heldRolesCTE + ` SELECT DISTINCT user_id FROM held WHERE role_id = $3`
+	rows, err := q.Query(stmt, pq.Array([]int64(nil)), false, roleId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	userIds := []uint64{}
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIds = append(userIds, id)
+	}
+	return userIds, rows.Err()
+}
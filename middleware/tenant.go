@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"hrm/catalog"
+	"net/http"
+)
+
+//Id column of each table whose rows InTenant can look up
+var tenantTables = map[string]string{
+	"users":  "user_id",
+	"roles":  "role_id",
+	"groups": "group_id",
+}
+
+//Returns the tenant the request acts on. Every query of a handler behind JwtVerify
+//is limited to it
+func TenantId(r *http.Request) uint64 {
+	p, _ := PrincipalFromContext(r.Context())
+	return p.TenantId
+}
+
+//Reports whether the row of table with the given id belongs to the tenant. Handlers use it
+//before linking rows by id, so a grant never crosses tenants
+func InTenant(q Queryer, table string, id, tenantId uint64) (bool, error) {
+	stmt := `SELECT 1 FROM ` + table + ` WHERE ` + tenantTables[table] + ` = $1 AND tenant_id = $2`
+	rows, err := q.Query(stmt, id, tenantId)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+//Returns the first of privileges userId may not link to a role, or an empty string. Platform
+//privileges reach across tenants, so only cross-tenant admins grant them
+func UngrantablePrivilege(q Queryer, userId uint64, privileges []string) (string, error) {
+	for _, name := range privileges {
+		if !catalog.IsPlatform(name) {
+			continue
+		}
+		held, err := EffectivePrivileges(q, userId)
+		if err != nil || contains(held, PrivCrossTenant) {
+			return "", err
+		}
+		return name, nil
+	}
+	return "", nil
+}
+
+//Returned by the grant paths when the granter may not hand out the named platform privilege
+type UngrantableError string
+
+func (e UngrantableError) Error() string {
+	return "Only holders of " + PrivCrossTenant + " may grant " + string(e)
+}
+
+//Returns an UngrantableError if roleId, or a role it inherits from, carries a privilege
+//userId may not grant. Checked by every path giving a user a role
+func CheckGrantableRole(q Queryer, userId, roleId uint64) error {
+	privileges, err := RolePrivileges(q, roleId)
+	if err != nil {
+		return err
+	}
+	return ungrantable(q, userId, privileges)
+}
+
+//Same as CheckGrantableRole for the roles a member of groupId gets: those of the group and of
+//the groups containing it, whatever their validity window. Checked by every path putting users
+//in a group
+func CheckGrantableGroup(q Queryer, userId, groupId uint64) error {
+	stmt := `WITH RECURSIVE ancestors(group_id) AS (
+			SELECT CAST($1 AS BIGINT)
+		UNION
+			SELECT gh.parent_group_id FROM group_hierarchy gh JOIN ancestors a ON gh.child_group_id = a.group_id
+		), effective_roles(role_id) AS (
+			SELECT gr.role_id FROM group_roles gr JOIN ancestors a ON a.group_id = gr.group_id
+		UNION
+			SELECT rh.child_role_id FROM role_hierarchy rh
+			JOIN effective_roles er ON rh.parent_role_id = er.role_id
+		)` + privilegesOfEffectiveRoles
+	privileges, err := queryNames(q, stmt, groupId)
+	if err != nil {
+		return err
+	}
+	return ungrantable(q, userId, privileges)
+}
+
+func ungrantable(q Queryer, userId uint64, privileges []string) error {
+	name, err := UngrantablePrivilege(q, userId, privileges)
+	if err == nil && name != "" {
+		return UngrantableError(name)
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"log"
 	"os"
+	"strconv"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
@@ -10,7 +11,7 @@ import (
 )
 
 //Authentication function will call this middleware for generating jwt token
-func GenerateJWT(username, roleId string) (string, error) {
+func GenerateJWT(userId, username, roleId, tenantId string) (string, error) {
 	// load .env file
 	err := godotenv.Load()
 if err != nil{
@@ -21,8 +22,12 @@ if err != nil{
 	claims := Token.Claims.(jwt.MapClaims)
 
 	claims["authorized"] = true
+	claims["userId"] = userId
 	claims["email"] = username
 	claims["roleId"] = roleId
+	claims["tenantId"] = tenantId
+	//Compared with users.tokens_valid_after to revoke the token
+	claims["iat"] = time.Now().Unix()
 	claims["exp"] = time.Now().Add(time.Minute * 30).Unix()
 
 	tokenString, err := Token.SignedString(mySigningKey)
@@ -32,3 +37,27 @@ if err != nil{
 	return tokenString, nil
 }
 
+//Issues a token for actor to act as target until ttl runs out. The target is the subject,
+//the actor goes in the act claim as in RFC 8693 token exchange.
+func GenerateImpersonationJWT(target, actor Principal, ttl time.Duration) (string, error) {
+	Token := jwt.New(jwt.SigningMethodHS256)
+	claims := Token.Claims.(jwt.MapClaims)
+
+	userId := strconv.FormatUint(target.UserId, 10)
+	claims["authorized"] = true
+	claims["sub"] = userId
+	claims["userId"] = userId
+	claims["email"] = target.Username
+	claims["tenantId"] = strconv.FormatUint(target.HomeTenantId, 10)
+	if target.RoleId != 0 {
+		claims["roleId"] = strconv.FormatUint(target.RoleId, 10)
+	}
+	claims["act"] = map[string]interface{}{
+		"sub":   strconv.FormatUint(actor.UserId, 10),
+		"email": actor.Username,
+	}
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(ttl).Unix()
+
+	return Token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
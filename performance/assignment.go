@@ -0,0 +1,557 @@
+package performance
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//The due date of assignment a in cycle c, by its kind
+const dueDate = `CASE a.kind WHEN 'self' THEN c.self_due WHEN 'peer' THEN c.peer_due ELSE c.manager_due END`
+
+const selectAssignment = `SELECT a.assignment_id, a.review_id, c.cycle_id, c.cycle_name, r.user_id, u.username,
+		a.reviewer_id, a.kind, a.status, TO_CHAR(` + dueDate + `, 'YYYY-MM-DD'),
+		a.status = 'pending' AND CURRENT_DATE > ` + dueDate + `, a.submitted_at
+	FROM performance_assignments a JOIN performance_reviews r ON r.review_id = a.review_id
+	JOIN performance_cycles c ON c.cycle_id = r.cycle_id JOIN users u ON u.user_id = r.user_id`
+
+func queryAssignments(q queryer, stmt string, args ...interface{}) ([]AssignmentModel, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	assignments := []AssignmentModel{}
+	for rows.Next() {
+		a := AssignmentModel{}
+		err := rows.Scan(&a.AssignmentId, &a.ReviewId, &a.CycleId, &a.CycleName, &a.UserId, &a.Username,
+			&a.ReviewerId, &a.Kind, &a.Status, &a.DueDate, &a.Overdue, &a.SubmittedAt)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, a)
+	}
+	return assignments, rows.Err()
+}
+
+//Returns the sections of kind in a template, in order
+func loadSections(q queryer, templateId uint64, kind string) ([]SectionModel, error) {
+	stmt := `SELECT section_id, kind, title, COALESCE(description, ''), rated FROM performance_template_sections
+		WHERE template_id = $1 AND kind = $2 ORDER BY position`
+	rows, err := q.Query(stmt, templateId, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sections := []SectionModel{}
+	for rows.Next() {
+		s := SectionModel{}
+		if err := rows.Scan(&s.SectionId, &s.Kind, &s.Title, &s.Description, &s.Rated); err != nil {
+			return nil, err
+		}
+		sections = append(sections, s)
+	}
+	return sections, rows.Err()
+}
+
+//Returns the answers of an assignment in the order of their sections
+func loadAnswers(q queryer, assignmentId uint64) ([]AnswerModel, error) {
+	stmt := `SELECT ans.section_id, ans.rating, COALESCE(ans.comment, '') FROM performance_answers ans
+		JOIN performance_template_sections s ON s.section_id = ans.section_id
+		WHERE ans.assignment_id = $1 ORDER BY s.position`
+	rows, err := q.Query(stmt, assignmentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	answers := []AnswerModel{}
+	for rows.Next() {
+		a := AnswerModel{}
+		if err := rows.Scan(&a.SectionId, &a.Rating, &a.Comment); err != nil {
+			return nil, err
+		}
+		answers = append(answers, a)
+	}
+	return answers, rows.Err()
+}
+
+//Returns what is wrong with the answers to sections on a scale of levels ratings, or an empty
+//string. Submitted answers must rate every rated section
+func validateAnswers(answers []AnswerModel, sections []SectionModel, levels int, submit bool) string {
+	byId := map[uint64]SectionModel{}
+	for _, s := range sections {
+		byId[s.SectionId] = s
+	}
+	rated := map[uint64]bool{}
+	for _, answer := range answers {
+		section, ok := byId[answer.SectionId]
+		if !ok {
+			return "Section " + strconv.FormatUint(answer.SectionId, 10) + " is not one this reviewer answers"
+		}
+		if _, seen := rated[answer.SectionId]; seen {
+			return "Section " + strconv.FormatUint(answer.SectionId, 10) + " is answered twice"
+		}
+		if answer.Rating != nil && !section.Rated {
+			return "Section " + strconv.FormatUint(answer.SectionId, 10) + " is not rated, it only takes a comment"
+		}
+		if answer.Rating != nil && (*answer.Rating < 1 || *answer.Rating > levels) {
+			return "rating must be between 1 and " + strconv.Itoa(levels)
+		}
+		rated[answer.SectionId] = answer.Rating != nil
+	}
+	if submit {
+		for _, s := range sections {
+			if s.Rated && !rated[s.SectionId] {
+				return "Every rated section needs a rating before submitting"
+			}
+		}
+	}
+	return ""
+}
+
+//For fetching the caller's assignments in open cycles, the soonest due first
+func GetMyAssignments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectAssignment + ` WHERE a.reviewer_id = $1 AND a.tenant_id = $2 AND c.status = 'open'
+		ORDER BY a.status DESC, ` + dueDate + `, a.assignment_id`
+	data, err := queryAssignments(db, stmt, principal.UserId, principal.TenantId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of assignment objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching one of the caller's assignments with the sections to answer and the answers so far
+func GetAssignment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get assignment id from req params
+	params := mux.Vars(r)
+	assignmentId, err := strconv.Atoi(params["assignment_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	assignments, err := queryAssignments(db, selectAssignment+` WHERE a.assignment_id = $1 AND a.reviewer_id = $2 AND a.tenant_id = $3`,
+		uint64(assignmentId), principal.UserId, principal.TenantId)
+	if err == nil && len(assignments) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Assignment not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var a AssignmentModel
+	if err == nil {
+		a = assignments[0]
+		var templateId uint64
+		err = db.QueryRow(`SELECT template_id FROM performance_cycles WHERE cycle_id = $1`, a.CycleId).Scan(&templateId)
+		if err == nil {
+			a.Sections, err = loadSections(db, templateId, a.Kind)
+		}
+	}
+	if err == nil {
+		a.Answers, err = loadAnswers(db, a.AssignmentId)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return the assignment object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(a)
+}
+
+//For saving the caller's answers to an assignment, replacing those saved before, and with
+//submit handing them in. Answers can change until submitted, while the cycle is open and
+//the due date has not passed
+func SaveAnswers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get assignment id from req params
+	params := mux.Vars(r)
+	assignmentId, err := strconv.Atoi(params["assignment_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	body := AnswersModel{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	var kind, status, cycleStatus, due string
+	var templateId uint64
+	var pastDue bool
+	var levels int
+	stmt := `SELECT a.kind, a.status, c.status, c.template_id, CURRENT_DATE > ` + dueDate + `,
+			TO_CHAR(` + dueDate + `, 'YYYY-MM-DD'), CARDINALITY(s.labels)
+		FROM performance_assignments a JOIN performance_reviews r ON r.review_id = a.review_id
+		JOIN performance_cycles c ON c.cycle_id = r.cycle_id
+		JOIN performance_templates t ON t.template_id = c.template_id JOIN rating_scales s ON s.scale_id = t.scale_id
+		WHERE a.assignment_id = $1 AND a.reviewer_id = $2 AND a.tenant_id = $3 FOR UPDATE OF a`
+	err = tx.QueryRow(stmt, uint64(assignmentId), principal.UserId, principal.TenantId).Scan(&kind, &status,
+		&cycleStatus, &templateId, &pastDue, &due, &levels)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Assignment not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg := ""
+	switch {
+	case err != nil:
+	case cycleStatus != "open":
+		msg = "Review cycle is " + cycleStatus
+	case status == "submitted":
+		msg = "Answers are already submitted"
+	case pastDue:
+		msg = "Answers were due on " + due
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var sections []SectionModel
+	if err == nil {
+		sections, err = loadSections(tx, templateId, kind)
+	}
+	if err == nil {
+		msg = validateAnswers(body.Answers, sections, levels, body.Submit)
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM performance_answers WHERE assignment_id = $1`, uint64(assignmentId))
+	}
+	stmt = `INSERT INTO performance_answers(assignment_id, section_id, rating, comment) VALUES ($1, $2, $3, NULLIF($4, ''))`
+	for _, answer := range body.Answers {
+		if err != nil {
+			break
+		}
+		_, err = tx.Exec(stmt, uint64(assignmentId), answer.SectionId, answer.Rating, answer.Comment)
+	}
+	if err == nil && body.Submit {
+		stmt = `UPDATE performance_assignments SET status = 'submitted', submitted_at = NOW() WHERE assignment_id = $1`
+		_, err = tx.Exec(stmt, uint64(assignmentId))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	message := "Answers saved"
+	if body.Submit {
+		message = "Answers submitted"
+	}
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: message,
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For adding a peer or manager reviewer to a review of an open cycle, e.g. for employees
+//without a manager or to replace a peer
+func AddReviewer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get review id from req params
+	params := mux.Vars(r)
+	reviewId, err := strconv.Atoi(params["review_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	reviewer := ReviewerModel{}
+	if err := json.NewDecoder(r.Body).Decode(&reviewer); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if reviewer.Kind != "peer" && reviewer.Kind != "manager" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "kind must be peer or manager",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	var userId, templateId uint64
+	var cycleStatus string
+	stmt := `SELECT r.user_id, c.template_id, c.status FROM performance_reviews r
+		JOIN performance_cycles c ON c.cycle_id = r.cycle_id
+		WHERE r.review_id = $1 AND r.tenant_id = $2 FOR UPDATE OF r`
+	err = tx.QueryRow(stmt, uint64(reviewId), tenantId).Scan(&userId, &templateId, &cycleStatus)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Review not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var active, hasSections bool
+	if err == nil {
+		stmt = `SELECT EXISTS(SELECT 1 FROM employees e JOIN users u ON u.user_id = e.user_id
+			WHERE e.user_id = $1 AND e.tenant_id = $2 AND u.status IN ('active', 'on_leave'))`
+		err = tx.QueryRow(stmt, reviewer.ReviewerId, tenantId).Scan(&active)
+	}
+	if err == nil {
+		stmt = `SELECT EXISTS(SELECT 1 FROM performance_template_sections WHERE template_id = $1 AND kind = $2)`
+		err = tx.QueryRow(stmt, templateId, reviewer.Kind).Scan(&hasSections)
+	}
+	if err == nil && !active {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Reviewer not found or not active",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg := ""
+	switch {
+	case err != nil:
+	case cycleStatus != "open":
+		msg = "Review cycle is " + cycleStatus
+	case reviewer.ReviewerId == userId:
+		msg = "Employees answer their own review as self"
+	case !hasSections:
+		msg = "The template of the cycle has no " + reviewer.Kind + " sections"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var assignmentId uint64
+	if err == nil {
+		stmt = `INSERT INTO performance_assignments(review_id, tenant_id, reviewer_id, kind) VALUES ($1, $2, $3, $4)
+			RETURNING assignment_id`
+		err = tx.QueryRow(stmt, uint64(reviewId), tenantId, reviewer.ReviewerId, reviewer.Kind).Scan(&assignmentId)
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Already a " + reviewer.Kind + " reviewer of this review",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Reviewer added with assignment id " + strconv.FormatUint(assignmentId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For removing a peer or manager reviewer who has not submitted yet. Self reviews stay
+func RemoveReviewer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get review and assignment ids from req params
+	params := mux.Vars(r)
+	reviewId, err := strconv.Atoi(params["review_id"])
+	assignmentId, err2 := strconv.Atoi(params["assignment_id"])
+	if err != nil || err2 != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	var kind, status, cycleStatus string
+	stmt := `WITH found AS (
+			SELECT a.assignment_id, a.kind, a.status, c.status AS cycle_status FROM performance_assignments a
+			JOIN performance_reviews r ON r.review_id = a.review_id JOIN performance_cycles c ON c.cycle_id = r.cycle_id
+			WHERE a.assignment_id = $1 AND a.review_id = $2 AND a.tenant_id = $3 FOR UPDATE OF a
+		), deleted AS (
+			DELETE FROM performance_assignments a USING found f WHERE a.assignment_id = f.assignment_id
+				AND f.kind <> 'self' AND f.status = 'pending' AND f.cycle_status = 'open'
+		)
+		SELECT kind, status, cycle_status FROM found`
+	err = db.QueryRow(stmt, uint64(assignmentId), uint64(reviewId), middleware.TenantId(r)).Scan(&kind, &status, &cycleStatus)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Reviewer not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg := ""
+	switch {
+	case kind == "self":
+		msg = "Self reviews cannot be removed"
+	case status != "pending":
+		msg = "The reviewer has already submitted"
+	case cycleStatus != "open":
+		msg = "Review cycle is " + cycleStatus
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Reviewer removed",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//Drops the pending peer and manager assignments of a user being terminated, in cycles not
+//closed yet. Reviews of the user themselves are kept. Registered with user.OnTermination
+func DropAssignments(tx *sql.Tx, userId, tenantId uint64, effectiveDate string) error {
+	stmt := `DELETE FROM performance_assignments a USING performance_reviews r, performance_cycles c
+		WHERE a.review_id = r.review_id AND c.cycle_id = r.cycle_id AND a.reviewer_id = $1 AND a.tenant_id = $2
+			AND a.kind <> 'self' AND a.status = 'pending' AND c.status <> 'closed'`
+	_, err := tx.Exec(stmt, userId, tenantId)
+	return err
+}
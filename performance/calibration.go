@@ -0,0 +1,170 @@
+package performance
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//Running totals of the ratings of one department
+type departmentTotals struct {
+	summary                       DepartmentSummaryModel
+	selfSum, managerSum, finalSum float64
+	selfN, managerN, finalN       int
+	counts                        []int
+}
+
+func average(sum float64, n int) *float64 {
+	if n == 0 {
+		return nil
+	}
+	avg := math.Round(sum/float64(n)*100) / 100
+	return &avg
+}
+
+//For fetching the calibration summary of a launched cycle: per department, how many reviews
+//were submitted and calibrated, the average ratings and how the ratings are distributed over
+//the scale. Reviews of employees without a department come last
+func GetCalibration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get cycle id from req params
+	params := mux.Vars(r)
+	cycleId, err := strconv.Atoi(params["cycle_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	var labels []string
+	stmt := `SELECT s.labels FROM performance_cycles c JOIN performance_templates t ON t.template_id = c.template_id
+		JOIN rating_scales s ON s.scale_id = t.scale_id WHERE c.cycle_id = $1 AND c.tenant_id = $2`
+	err = db.QueryRow(stmt, uint64(cycleId), middleware.TenantId(r)).Scan(pq.Array(&labels))
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Review cycle not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var rows *sql.Rows
+	if err == nil {
+		stmt = `SELECT r.department_id, COALESCE(d.department_name, ''),
+				EXISTS(SELECT 1 FROM performance_assignments a WHERE a.review_id = r.review_id
+					AND a.kind = 'self' AND a.status = 'submitted'),
+				EXISTS(SELECT 1 FROM performance_assignments a WHERE a.review_id = r.review_id
+					AND a.kind = 'manager' AND a.status = 'submitted'),
+				` + averageRating("self") + `, ` + averageRating("manager") + `, r.final_rating, r.calibrated_at IS NOT NULL
+			FROM performance_reviews r LEFT JOIN departments d ON d.department_id = r.department_id
+			WHERE r.cycle_id = $1 ORDER BY d.department_name NULLS LAST, r.department_id`
+		rows, err = db.Query(stmt, uint64(cycleId))
+	}
+	var order []*departmentTotals
+	if err == nil {
+		defer rows.Close()
+		byDepartment := map[uint64]*departmentTotals{}
+		var none *departmentTotals
+		for rows.Next() {
+			var departmentId *uint64
+			var departmentName string
+			var selfSubmitted, managerSubmitted, calibrated bool
+			var self, manager, final *float64
+			err = rows.Scan(&departmentId, &departmentName, &selfSubmitted, &managerSubmitted, &self, &manager,
+				&final, &calibrated)
+			if err != nil {
+				break
+			}
+			var totals *departmentTotals
+			if departmentId == nil {
+				totals = none
+			} else {
+				totals = byDepartment[*departmentId]
+			}
+			if totals == nil {
+				totals = &departmentTotals{counts: make([]int, len(labels))}
+				totals.summary.DepartmentId = departmentId
+				totals.summary.DepartmentName = departmentName
+				if departmentId == nil {
+					none = totals
+				} else {
+					byDepartment[*departmentId] = totals
+				}
+				order = append(order, totals)
+			}
+			totals.summary.Reviews++
+			if selfSubmitted {
+				totals.summary.SelfSubmitted++
+			}
+			if managerSubmitted {
+				totals.summary.ManagerSubmitted++
+			}
+			if calibrated {
+				totals.summary.Calibrated++
+			}
+			if self != nil {
+				totals.selfSum += *self
+				totals.selfN++
+			}
+			if manager != nil {
+				totals.managerSum += *manager
+				totals.managerN++
+			}
+			if final != nil {
+				totals.finalSum += *final
+				totals.finalN++
+			}
+			//The final rating counts once set, the manager rating until then
+			rating := final
+			if rating == nil {
+				rating = manager
+			}
+			if rating != nil && len(labels) > 0 {
+				position := int(math.Round(*rating))
+				position = int(math.Max(1, math.Min(float64(len(labels)), float64(position))))
+				totals.counts[position-1]++
+			}
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	data := []DepartmentSummaryModel{}
+	for _, totals := range order {
+		s := totals.summary
+		s.AvgSelfRating = average(totals.selfSum, totals.selfN)
+		s.AvgManagerRating = average(totals.managerSum, totals.managerN)
+		s.AvgFinalRating = average(totals.finalSum, totals.finalN)
+		s.Distribution = []RatingCountModel{}
+		for i, label := range labels {
+			s.Distribution = append(s.Distribution, RatingCountModel{Rating: i + 1, Label: label, Count: totals.counts[i]})
+		}
+		data = append(data, s)
+	}
+	//If everything went well, return array of department summary objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,611 @@
+package performance
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const selectCycle = `SELECT cycle_id, cycle_name, template_id, department_id, TO_CHAR(period_start, 'YYYY-MM-DD'),
+		TO_CHAR(period_end, 'YYYY-MM-DD'), TO_CHAR(self_due, 'YYYY-MM-DD'), TO_CHAR(peer_due, 'YYYY-MM-DD'),
+		TO_CHAR(manager_due, 'YYYY-MM-DD'), peer_count, status, created_by, created_at, launched_at, closed_at
+	FROM performance_cycles`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCycle(row scanner, c *CycleModel) error {
+	return row.Scan(&c.CycleId, &c.CycleName, &c.TemplateId, &c.DepartmentId, &c.PeriodStart, &c.PeriodEnd,
+		&c.SelfDue, &c.PeerDue, &c.ManagerDue, &c.PeerCount, &c.Status, &c.CreatedBy, &c.CreatedAt,
+		&c.LaunchedAt, &c.ClosedAt)
+}
+
+//Where a cycle can go from each status. Opening a draft launches it
+var cycleTransitions = map[string][]string{
+	"draft":       {"open"},
+	"open":        {"calibration"},
+	"calibration": {"open", "closed"},
+}
+
+func canMove(from, to string) bool {
+	for _, status := range cycleTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+//Returns what is wrong with a cycle, or an empty string
+func validateCycle(c CycleModel) string {
+	if c.CycleName == "" {
+		return "cycle_name is required"
+	}
+	dates := []struct{ name, value string }{
+		{"period_start", c.PeriodStart}, {"period_end", c.PeriodEnd},
+		{"self_due", c.SelfDue}, {"peer_due", c.PeerDue}, {"manager_due", c.ManagerDue},
+	}
+	for _, date := range dates {
+		if _, err := time.Parse("2006-01-02", date.value); err != nil {
+			return date.name + " must be a date as YYYY-MM-DD"
+		}
+	}
+	//Dates as YYYY-MM-DD compare as strings
+	if c.PeriodEnd < c.PeriodStart {
+		return "period_end cannot be before period_start"
+	}
+	if c.PeerCount < 0 || c.PeerCount > 10 {
+		return "peer_count must be between 0 and 10"
+	}
+	return ""
+}
+
+//Returns the reason the template or department of a cycle cannot be used in tenantId, or an empty string
+func checkCycleReferences(q queryer, c CycleModel, tenantId uint64) (string, error) {
+	var found bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM performance_templates WHERE template_id = $1 AND tenant_id = $2)`
+	if err := q.QueryRow(stmt, c.TemplateId, tenantId).Scan(&found); err != nil || !found {
+		return "Template not found", err
+	}
+	if c.DepartmentId != nil {
+		stmt := `SELECT EXISTS(SELECT 1 FROM departments WHERE department_id = $1 AND tenant_id = $2)`
+		if err := q.QueryRow(stmt, *c.DepartmentId, tenantId).Scan(&found); err != nil || !found {
+			return "Department not found", err
+		}
+	}
+	return "", nil
+}
+
+//Reports whether an edit changes what a launched cycle was launched with
+func changesLaunched(before, after CycleModel) bool {
+	sameDepartment := (before.DepartmentId == nil && after.DepartmentId == nil) ||
+		(before.DepartmentId != nil && after.DepartmentId != nil && *before.DepartmentId == *after.DepartmentId)
+	return before.TemplateId != after.TemplateId || !sameDepartment || before.PeriodStart != after.PeriodStart ||
+		before.PeriodEnd != after.PeriodEnd || before.PeerCount != after.PeerCount
+}
+
+//Creates the reviews of a cycle being launched, one per active employee it covers who was
+//hired by the end of its period, with a reviewer for each kind of section its template has:
+//the employee, their manager and peer_count of the employees sharing their manager, picked
+//at random. Returns the number of reviews
+func launchCycle(tx *sql.Tx, c CycleModel, tenantId uint64) (int64, error) {
+	var self, manager, peer bool
+	stmt := `SELECT COALESCE(BOOL_OR(kind = 'self'), FALSE), COALESCE(BOOL_OR(kind = 'manager'), FALSE),
+			COALESCE(BOOL_OR(kind = 'peer'), FALSE)
+		FROM performance_template_sections WHERE template_id = $1`
+	if err := tx.QueryRow(stmt, c.TemplateId).Scan(&self, &manager, &peer); err != nil {
+		return 0, err
+	}
+	stmt = `WITH RECURSIVE below(department_id) AS (
+			SELECT CAST($3 AS BIGINT)
+		UNION
+			SELECT d.department_id FROM departments d JOIN below b ON d.parent_department_id = b.department_id
+		)
+		INSERT INTO performance_reviews(cycle_id, tenant_id, user_id, manager_id, department_id)
+		SELECT $1, $2, e.user_id, e.manager_id, a.department_id
+		FROM employees e JOIN users u ON u.user_id = e.user_id
+		LEFT JOIN employee_assignments a ON a.user_id = e.user_id
+			AND a.effective_from <= CURRENT_DATE AND (a.effective_to IS NULL OR a.effective_to >= CURRENT_DATE)
+		WHERE e.tenant_id = $2 AND u.status IN ('active', 'on_leave')
+			AND (e.hire_date IS NULL OR e.hire_date <= $4::DATE)
+			AND ($3::BIGINT IS NULL OR a.department_id IN (SELECT department_id FROM below))`
+	result, err := tx.Exec(stmt, c.CycleId, tenantId, c.DepartmentId, c.PeriodEnd)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err == nil && self {
+		stmt = `INSERT INTO performance_assignments(review_id, tenant_id, reviewer_id, kind)
+			SELECT review_id, tenant_id, user_id, 'self' FROM performance_reviews WHERE cycle_id = $1`
+		_, err = tx.Exec(stmt, c.CycleId)
+	}
+	if err == nil && manager {
+		stmt = `INSERT INTO performance_assignments(review_id, tenant_id, reviewer_id, kind)
+			SELECT r.review_id, r.tenant_id, r.manager_id, 'manager' FROM performance_reviews r
+			JOIN users m ON m.user_id = r.manager_id
+			WHERE r.cycle_id = $1 AND m.status IN ('active', 'on_leave')`
+		_, err = tx.Exec(stmt, c.CycleId)
+	}
+	if err == nil && peer && c.PeerCount > 0 {
+		stmt = `INSERT INTO performance_assignments(review_id, tenant_id, reviewer_id, kind)
+			SELECT r.review_id, r.tenant_id, p.user_id, 'peer' FROM performance_reviews r
+			CROSS JOIN LATERAL (
+				SELECT e.user_id FROM employees e JOIN users u ON u.user_id = e.user_id
+				WHERE e.manager_id = r.manager_id AND e.user_id <> r.user_id AND u.status IN ('active', 'on_leave')
+				ORDER BY RANDOM() LIMIT $2
+			) p
+			WHERE r.cycle_id = $1`
+		_, err = tx.Exec(stmt, c.CycleId, c.PeerCount)
+	}
+	return count, err
+}
+
+//Gives the reviews of a cycle being closed that were not calibrated the average of their
+//manager's ratings as final rating
+func settleCycle(tx *sql.Tx, cycleId uint64) error {
+	stmt := `UPDATE performance_reviews r SET final_rating = m.rating
+		FROM (
+			SELECT a.review_id, ROUND(AVG(ans.rating), 2) AS rating FROM performance_assignments a
+			JOIN performance_answers ans ON ans.assignment_id = a.assignment_id
+			WHERE a.kind = 'manager' AND a.status = 'submitted' GROUP BY a.review_id
+		) m
+		WHERE r.review_id = m.review_id AND r.cycle_id = $1 AND r.final_rating IS NULL`
+	_, err := tx.Exec(stmt, cycleId)
+	return err
+}
+
+//For creating a review cycle, as a draft. Takes cycle_name, template_id, the optional
+//department_id, the period, the due dates of the self, peer and manager answers and peer_count
+func AddCycle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	cycle := CycleModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&cycle); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateCycle(cycle); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	msg, err := checkCycleReferences(db, cycle, tenantId)
+	if err == nil && msg != "" {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		stmt := `INSERT INTO performance_cycles(tenant_id, cycle_name, template_id, department_id, period_start,
+				period_end, self_due, peer_due, manager_due, peer_count, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING cycle_id`
+		err = db.QueryRow(stmt, tenantId, cycle.CycleName, cycle.TemplateId, cycle.DepartmentId, cycle.PeriodStart,
+			cycle.PeriodEnd, cycle.SelfDue, cycle.PeerDue, cycle.ManagerDue, cycle.PeerCount, principal.UserId).Scan(&cycle.CycleId)
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Cycle name already in use",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Review cycle created with id " + strconv.FormatUint(cycle.CycleId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all review cycles, the latest period first. ?status= narrows it down
+func GetCycles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectCycle + ` WHERE tenant_id = $1 AND ($2 = '' OR status = $2) ORDER BY period_end DESC, cycle_id DESC`
+	rows, err := db.Query(stmt, middleware.TenantId(r), r.URL.Query().Get("status"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []CycleModel{}
+	for rows.Next() {
+		c := CycleModel{}
+		if err := scanCycle(rows, &c); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, c)
+	}
+	//If everything went well, return array of cycle objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching a single review cycle
+func GetCycle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get cycle id from req params
+	params := mux.Vars(r)
+	cycleId, err := strconv.Atoi(params["cycle_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	c := CycleModel{}
+	err = scanCycle(db.QueryRow(selectCycle+` WHERE cycle_id = $1 AND tenant_id = $2`, uint64(cycleId), middleware.TenantId(r)), &c)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Review cycle not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return the cycle object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(c)
+}
+
+//For editing a review cycle. Every field is replaced. Once launched only the name and due
+//dates can change, which is how deadlines are extended; closed cycles cannot change
+func EditCycle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get cycle id from req params
+	params := mux.Vars(r)
+	cycleId, err := strconv.Atoi(params["cycle_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	cycle := CycleModel{}
+	if err := json.NewDecoder(r.Body).Decode(&cycle); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateCycle(cycle); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	before := CycleModel{}
+	err = scanCycle(tx.QueryRow(selectCycle+` WHERE cycle_id = $1 AND tenant_id = $2 FOR UPDATE`, uint64(cycleId), tenantId), &before)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Review cycle not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg := ""
+	if err == nil && before.Status == "closed" {
+		msg = "Review cycle is closed"
+	} else if err == nil && before.Status != "draft" && changesLaunched(before, cycle) {
+		msg = "Only the name and due dates of a launched cycle can change"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		msg, err = checkCycleReferences(tx, cycle, tenantId)
+	}
+	if err == nil && msg != "" {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		stmt := `UPDATE performance_cycles SET cycle_name = $2, template_id = $3, department_id = $4, period_start = $5,
+				period_end = $6, self_due = $7, peer_due = $8, manager_due = $9, peer_count = $10
+			WHERE cycle_id = $1`
+		_, err = tx.Exec(stmt, uint64(cycleId), cycle.CycleName, cycle.TemplateId, cycle.DepartmentId, cycle.PeriodStart,
+			cycle.PeriodEnd, cycle.SelfDue, cycle.PeerDue, cycle.ManagerDue, cycle.PeerCount)
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Cycle name already in use",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Review cycle updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For deleting a review cycle that was never launched
+func DeleteCycle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get cycle id from req params
+	params := mux.Vars(r)
+	cycleId, err := strconv.Atoi(params["cycle_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	var status string
+	stmt := `WITH found AS (
+			SELECT cycle_id, status FROM performance_cycles WHERE cycle_id = $1 AND tenant_id = $2 FOR UPDATE
+		), deleted AS (
+			DELETE FROM performance_cycles c USING found f WHERE c.cycle_id = f.cycle_id AND f.status = 'draft'
+		)
+		SELECT status FROM found`
+	err = db.QueryRow(stmt, uint64(cycleId), middleware.TenantId(r)).Scan(&status)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Review cycle not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if status != "draft" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Only draft cycles can be deleted, this one is " + status,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Review cycle deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For moving a review cycle on. Opening a draft launches it: the reviews and reviewers are
+//created then. Calibration ends the submissions, closing settles the final ratings and
+//shows the reviews to the employees
+func SetCycleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get cycle id from req params
+	params := mux.Vars(r)
+	cycleId, err := strconv.Atoi(params["cycle_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	change := CycleStatusModel{}
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if change.Status != "open" && change.Status != "calibration" && change.Status != "closed" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "status must be open, calibration or closed",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	c := CycleModel{}
+	err = scanCycle(tx.QueryRow(selectCycle+` WHERE cycle_id = $1 AND tenant_id = $2 FOR UPDATE`, uint64(cycleId), tenantId), &c)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Review cycle not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && !canMove(c.Status, change.Status) {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "A " + c.Status + " cycle cannot become " + change.Status,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	message := "Review cycle is now " + change.Status
+	if err == nil && c.Status == "draft" {
+		var count int64
+		count, err = launchCycle(tx, c, tenantId)
+		message = "Review cycle launched with " + strconv.FormatInt(count, 10) + " reviews"
+	}
+	if err == nil && change.Status == "closed" {
+		err = settleCycle(tx, c.CycleId)
+	}
+	if err == nil {
+		stmt := `UPDATE performance_cycles SET status = $2,
+				launched_at = CASE WHEN status = 'draft' THEN NOW() ELSE launched_at END,
+				closed_at = CASE WHEN $2 = 'closed' THEN NOW() ELSE closed_at END
+			WHERE cycle_id = $1`
+		_, err = tx.Exec(stmt, c.CycleId, change.Status)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: message,
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,144 @@
+package performance
+
+import "time"
+
+//A rating scale. A rating is the position of its label, 1 for the first
+type ScaleModel struct {
+	ScaleId   uint64    `json:"id"`
+	ScaleName string    `json:"scale_name"`
+	Labels    []string  `json:"labels"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+//A section of a template. kind is self, manager or peer: who answers it. Sections that are
+//not rated only take a comment
+type SectionModel struct {
+	SectionId   uint64 `json:"id"`
+	Kind        string `json:"kind"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Rated       bool   `json:"rated"`
+}
+
+//A review template. Rated sections are rated on the scale of scale_id
+type TemplateModel struct {
+	TemplateId   uint64         `json:"id"`
+	TemplateName string         `json:"template_name"`
+	ScaleId      uint64         `json:"scale_id"`
+	Sections     []SectionModel `json:"sections"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+//A review cycle. Dates are YYYY-MM-DD. department_id limits it to the employees of that
+//department and those below it. status is draft, open, calibration or closed
+type CycleModel struct {
+	CycleId      uint64     `json:"id"`
+	CycleName    string     `json:"cycle_name"`
+	TemplateId   uint64     `json:"template_id"`
+	DepartmentId *uint64    `json:"department_id"`
+	PeriodStart  string     `json:"period_start"`
+	PeriodEnd    string     `json:"period_end"`
+	SelfDue      string     `json:"self_due"`
+	PeerDue      string     `json:"peer_due"`
+	ManagerDue   string     `json:"manager_due"`
+	PeerCount    int        `json:"peer_count"`
+	Status       string     `json:"status"`
+	CreatedBy    *uint64    `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LaunchedAt   *time.Time `json:"launched_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+}
+
+//Body of moving a cycle on: open launches a draft, calibration ends the submissions, closed
+//settles the final ratings. A cycle in calibration can be opened again
+type CycleStatusModel struct {
+	Status string `json:"status"`
+}
+
+//An answer to one section. rating is null for sections that are not rated
+type AnswerModel struct {
+	SectionId uint64 `json:"section_id"`
+	Rating    *int   `json:"rating"`
+	Comment   string `json:"comment"`
+}
+
+//Who answers which sections of a review. reviewer_id is left out of peer answers the
+//caller may not attribute. due_date is YYYY-MM-DD
+type AssignmentModel struct {
+	AssignmentId uint64         `json:"id"`
+	ReviewId     uint64         `json:"review_id"`
+	CycleId      uint64         `json:"cycle_id"`
+	CycleName    string         `json:"cycle_name"`
+	UserId       uint64         `json:"user_id"`
+	Username     string         `json:"username"`
+	ReviewerId   *uint64        `json:"reviewer_id,omitempty"`
+	Kind         string         `json:"kind"`
+	Status       string         `json:"status"`
+	DueDate      string         `json:"due_date"`
+	Overdue      bool           `json:"overdue"`
+	SubmittedAt  *time.Time     `json:"submitted_at"`
+	Sections     []SectionModel `json:"sections,omitempty"`
+	Answers      []AnswerModel  `json:"answers,omitempty"`
+}
+
+//Body of saving the answers of an assignment. The answers replace those it had; submit
+//hands them in for good
+type AnswersModel struct {
+	Answers []AnswerModel `json:"answers"`
+	Submit  bool          `json:"submit"`
+}
+
+//The review of an employee in a cycle. self_rating and manager_rating are the average
+//ratings of the submitted self and manager answers
+type ReviewModel struct {
+	ReviewId        uint64            `json:"id"`
+	CycleId         uint64            `json:"cycle_id"`
+	CycleName       string            `json:"cycle_name"`
+	CycleStatus     string            `json:"cycle_status"`
+	UserId          uint64            `json:"user_id"`
+	Username        string            `json:"username"`
+	ManagerId       *uint64           `json:"manager_id"`
+	DepartmentId    *uint64           `json:"department_id"`
+	SelfRating      *float64          `json:"self_rating"`
+	ManagerRating   *float64          `json:"manager_rating"`
+	FinalRating     *float64          `json:"final_rating"`
+	CalibrationNote string            `json:"calibration_note,omitempty"`
+	CalibratedBy    *uint64           `json:"calibrated_by,omitempty"`
+	CalibratedAt    *time.Time        `json:"calibrated_at,omitempty"`
+	Assignments     []AssignmentModel `json:"assignments,omitempty"`
+}
+
+//Body of adding a reviewer to a review. kind is peer or manager
+type ReviewerModel struct {
+	ReviewerId uint64 `json:"reviewer_id"`
+	Kind       string `json:"kind"`
+}
+
+//Body of calibrating a review. final_rating is on the scale of the cycle's template
+type CalibrationModel struct {
+	FinalRating float64 `json:"final_rating"`
+	Note        string  `json:"note"`
+}
+
+//How many reviews of a department ended up with a rating, rounded to the nearest label
+type RatingCountModel struct {
+	Rating int    `json:"rating"`
+	Label  string `json:"label"`
+	Count  int    `json:"count"`
+}
+
+//The calibration summary of the reviews of one department in a cycle, as departments were
+//at launch. Averages are null without ratings. distribution counts the final ratings, or the
+//manager ratings of reviews not calibrated yet
+type DepartmentSummaryModel struct {
+	DepartmentId     *uint64            `json:"department_id"`
+	DepartmentName   string             `json:"department_name"`
+	Reviews          int                `json:"reviews"`
+	SelfSubmitted    int                `json:"self_submitted"`
+	ManagerSubmitted int                `json:"manager_submitted"`
+	Calibrated       int                `json:"calibrated"`
+	AvgSelfRating    *float64           `json:"avg_self_rating"`
+	AvgManagerRating *float64           `json:"avg_manager_rating"`
+	AvgFinalRating   *float64           `json:"avg_final_rating"`
+	Distribution     []RatingCountModel `json:"distribution"`
+}
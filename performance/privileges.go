@@ -0,0 +1,17 @@
+package performance
+
+import "hrm/catalog"
+
+//Privileges required by the performance review routes
+var (
+	PrivManageTemplates  = catalog.Declare("manage_performance_templates", "Create, edit and delete rating scales and performance review templates")
+	PrivReadTemplates    = catalog.Declare("read_performance_templates", "List rating scales and performance review templates")
+	PrivManageCycles     = catalog.Declare("manage_performance_cycles", "Create, launch, move on and delete review cycles and change their reviewers")
+	PrivReadCycles       = catalog.Declare("read_performance_cycles", "List review cycles")
+	PrivSubmitReviews    = catalog.Declare("submit_performance_reviews", "Answer the reviews the caller is a reviewer of")
+	PrivReadOwnReviews   = catalog.Declare("read_own_performance_reviews", "Read the caller's own reviews once their cycle is closed")
+	PrivReadDeptReviews  = catalog.Declare("read_department_performance_reviews", "Read the reviews of employees in the caller's department or below it")
+	PrivReadAllReviews   = catalog.Declare("read_all_performance_reviews", "Read the reviews of any employee")
+	PrivReadPeerNames    = catalog.Declare("read_peer_reviewer_names", "See who gave the peer answers of the reviews the caller reads")
+	PrivCalibrateReviews = catalog.Declare("calibrate_performance_reviews", "Read calibration summaries and set the final ratings of reviews")
+)
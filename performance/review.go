@@ -0,0 +1,297 @@
+package performance
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+//The average rating of the submitted answers of a kind to review r
+func averageRating(kind string) string {
+	return `(SELECT ROUND(AVG(ans.rating), 2) FROM performance_assignments a
+		JOIN performance_answers ans ON ans.assignment_id = a.assignment_id
+		WHERE a.review_id = r.review_id AND a.kind = '` + kind + `' AND a.status = 'submitted')`
+}
+
+var selectReview = `SELECT r.review_id, c.cycle_id, c.cycle_name, c.status, r.user_id, u.username, r.manager_id,
+		r.department_id, ` + averageRating("self") + `, ` + averageRating("manager") + `, r.final_rating,
+		COALESCE(r.calibration_note, ''), r.calibrated_by, r.calibrated_at
+	FROM performance_reviews r JOIN performance_cycles c ON c.cycle_id = r.cycle_id JOIN users u ON u.user_id = r.user_id`
+
+func queryReviews(q queryer, stmt string, args ...interface{}) ([]ReviewModel, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	reviews := []ReviewModel{}
+	for rows.Next() {
+		rv := ReviewModel{}
+		err := rows.Scan(&rv.ReviewId, &rv.CycleId, &rv.CycleName, &rv.CycleStatus, &rv.UserId, &rv.Username,
+			&rv.ManagerId, &rv.DepartmentId, &rv.SelfRating, &rv.ManagerRating, &rv.FinalRating,
+			&rv.CalibrationNote, &rv.CalibratedBy, &rv.CalibratedAt)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, rv)
+	}
+	return reviews, rows.Err()
+}
+
+//Reports whether privileges include privilege
+func holds(privileges []string, privilege string) bool {
+	for _, p := range privileges {
+		if p == privilege {
+			return true
+		}
+	}
+	return false
+}
+
+//For fetching the reviews of a cycle with their reviewers, without answers
+func GetCycleReviews(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get cycle id from req params
+	params := mux.Vars(r)
+	cycleId, err := strconv.Atoi(params["cycle_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	var found bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM performance_cycles WHERE cycle_id = $1 AND tenant_id = $2)`
+	err = db.QueryRow(stmt, uint64(cycleId), tenantId).Scan(&found)
+	if err == nil && !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Review cycle not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var data []ReviewModel
+	var assignments []AssignmentModel
+	if err == nil {
+		data, err = queryReviews(db, selectReview+` WHERE r.cycle_id = $1 ORDER BY u.username`, uint64(cycleId))
+	}
+	if err == nil {
+		stmt = selectAssignment + ` WHERE r.cycle_id = $1 ORDER BY a.kind DESC, a.assignment_id`
+		assignments, err = queryAssignments(db, stmt, uint64(cycleId))
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	byReview := map[uint64][]AssignmentModel{}
+	for _, a := range assignments {
+		byReview[a.ReviewId] = append(byReview[a.ReviewId], a)
+	}
+	for i := range data {
+		data[i].Assignments = byReview[data[i].ReviewId]
+	}
+	//If everything went well, return array of review objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the reviews of an employee with the answers given, the latest cycle first.
+//Employees see their own reviews once the cycle is closed, with the submitted answers only
+//and without who gave the peer answers or how the review was calibrated. Others see the
+//reviews of launched cycles; the peer reviewers only with read_peer_reviewer_names
+func GetEmployeeReviews(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	own := principal.UserId == uint64(userId)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	peerNames := !own && holds(middleware.PrivilegesFromContext(r.Context()), PrivReadPeerNames)
+	statuses := `('open', 'calibration', 'closed')`
+	if own {
+		statuses = `('closed')`
+	}
+	var data []ReviewModel
+	var assignments []AssignmentModel
+	if err == nil {
+		stmt := selectReview + ` WHERE r.user_id = $1 AND r.tenant_id = $2 AND c.status IN ` + statuses + `
+			ORDER BY c.period_end DESC, c.cycle_id DESC`
+		data, err = queryReviews(db, stmt, uint64(userId), principal.TenantId)
+	}
+	if err == nil {
+		stmt := selectAssignment + ` WHERE r.user_id = $1 AND r.tenant_id = $2 AND c.status IN ` + statuses + `
+			AND ($3 = FALSE OR a.status = 'submitted') ORDER BY a.kind DESC, a.assignment_id`
+		assignments, err = queryAssignments(db, stmt, uint64(userId), principal.TenantId, own)
+	}
+	for i := 0; err == nil && i < len(assignments); i++ {
+		a := &assignments[i]
+		a.Answers, err = loadAnswers(db, a.AssignmentId)
+		if a.Kind == "peer" && !peerNames {
+			a.ReviewerId = nil
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	byReview := map[uint64][]AssignmentModel{}
+	for _, a := range assignments {
+		byReview[a.ReviewId] = append(byReview[a.ReviewId], a)
+	}
+	for i := range data {
+		data[i].Assignments = byReview[data[i].ReviewId]
+		if own {
+			data[i].CalibrationNote = ""
+			data[i].CalibratedBy = nil
+		}
+	}
+	//If everything went well, return array of review objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For setting the final rating of a review while its cycle is in calibration, with an
+//optional note on why. Nobody calibrates their own review
+func CalibrateReview(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get review id from req params
+	params := mux.Vars(r)
+	reviewId, err := strconv.Atoi(params["review_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	calibration := CalibrationModel{}
+	if err := json.NewDecoder(r.Body).Decode(&calibration); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	var userId uint64
+	var cycleStatus string
+	var levels int
+	stmt := `SELECT r.user_id, c.status, CARDINALITY(s.labels) FROM performance_reviews r
+		JOIN performance_cycles c ON c.cycle_id = r.cycle_id
+		JOIN performance_templates t ON t.template_id = c.template_id JOIN rating_scales s ON s.scale_id = t.scale_id
+		WHERE r.review_id = $1 AND r.tenant_id = $2 FOR UPDATE OF r`
+	err = tx.QueryRow(stmt, uint64(reviewId), principal.TenantId).Scan(&userId, &cycleStatus, &levels)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Review not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && (calibration.FinalRating < 1 || calibration.FinalRating > float64(levels)) {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "final_rating must be between 1 and " + strconv.Itoa(levels),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg := ""
+	switch {
+	case err != nil:
+	case cycleStatus != "calibration":
+		msg = "Review cycle is " + cycleStatus
+	case userId == principal.UserId:
+		msg = "Nobody calibrates their own review"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		stmt = `UPDATE performance_reviews SET final_rating = ROUND($2::NUMERIC, 2), calibration_note = NULLIF($3, ''),
+			calibrated_by = $4, calibrated_at = NOW() WHERE review_id = $1`
+		_, err = tx.Exec(stmt, uint64(reviewId), calibration.FinalRating, calibration.Note, principal.UserId)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Review calibrated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
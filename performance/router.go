@@ -0,0 +1,103 @@
+package performance
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandlePerformanceRoutes(r *mux.Router) {
+	//Endpoint for creating a rating scale
+	r.HandleFunc("/rating-scales",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTemplates, AddScale))).Methods("POST")
+
+	//Endpoint for fetching all rating scales
+	r.HandleFunc("/rating-scales",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadTemplates, GetScales))).Methods("GET")
+
+	//Endpoint for deleting a rating scale
+	r.HandleFunc("/rating-scales/{scale_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTemplates, DeleteScale))).Methods("DELETE")
+
+	//Endpoint for creating a review template
+	r.HandleFunc("/performance-templates",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTemplates, AddTemplate))).Methods("POST")
+
+	//Endpoint for fetching all review templates
+	r.HandleFunc("/performance-templates",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadTemplates, GetTemplates))).Methods("GET")
+
+	//Endpoint for fetching a single review template
+	r.HandleFunc("/performance-templates/{template_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadTemplates, GetTemplate))).Methods("GET")
+
+	//Endpoint for editing a review template
+	r.HandleFunc("/performance-templates/{template_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTemplates, EditTemplate))).Methods("PUT")
+
+	//Endpoint for deleting a review template
+	r.HandleFunc("/performance-templates/{template_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTemplates, DeleteTemplate))).Methods("DELETE")
+
+	//Endpoint for creating a review cycle
+	r.HandleFunc("/performance-cycles",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCycles, AddCycle))).Methods("POST")
+
+	//Endpoint for fetching all review cycles
+	r.HandleFunc("/performance-cycles",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadCycles, GetCycles))).Methods("GET")
+
+	//Endpoint for fetching a single review cycle
+	r.HandleFunc("/performance-cycles/{cycle_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadCycles, GetCycle))).Methods("GET")
+
+	//Endpoint for editing a review cycle
+	r.HandleFunc("/performance-cycles/{cycle_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCycles, EditCycle))).Methods("PUT")
+
+	//Endpoint for deleting a draft review cycle
+	r.HandleFunc("/performance-cycles/{cycle_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCycles, DeleteCycle))).Methods("DELETE")
+
+	//Endpoint for launching, calibrating and closing a review cycle
+	r.HandleFunc("/performance-cycles/{cycle_id}/status",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCycles, SetCycleStatus))).Methods("POST")
+
+	//Endpoint for fetching the reviews of a cycle
+	r.HandleFunc("/performance-cycles/{cycle_id}/reviews",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadAllReviews, GetCycleReviews))).Methods("GET")
+
+	//Endpoint for fetching the calibration summary of a cycle
+	r.HandleFunc("/performance-cycles/{cycle_id}/calibration",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivCalibrateReviews, GetCalibration))).Methods("GET")
+
+	//Endpoint for adding a reviewer to a review
+	r.HandleFunc("/performance-reviews/{review_id}/reviewers",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCycles, AddReviewer))).Methods("POST")
+
+	//Endpoint for removing a reviewer from a review
+	r.HandleFunc("/performance-reviews/{review_id}/reviewers/{assignment_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageCycles, RemoveReviewer))).Methods("DELETE")
+
+	//Endpoint for setting the final rating of a review
+	r.HandleFunc("/performance-reviews/{review_id}/calibration",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivCalibrateReviews, CalibrateReview))).Methods("PUT")
+
+	//Endpoint for fetching the caller's reviews to answer
+	r.HandleFunc("/performance-assignments/mine",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivSubmitReviews, GetMyAssignments))).Methods("GET")
+
+	//Endpoint for fetching one of the caller's reviews to answer
+	r.HandleFunc("/performance-assignments/{assignment_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivSubmitReviews, GetAssignment))).Methods("GET")
+
+	//Endpoint for saving or submitting the answers to a review
+	r.HandleFunc("/performance-assignments/{assignment_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivSubmitReviews, SaveAnswers))).Methods("PUT")
+
+	//Endpoint for fetching the reviews of an employee
+	r.HandleFunc("/employees/{user_id}/performance-reviews",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivReadOwnReviews, Department: PrivReadDeptReviews, Any: PrivReadAllReviews,
+		}, GetEmployeeReviews))).Methods("GET")
+}
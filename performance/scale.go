@@ -0,0 +1,188 @@
+package performance
+
+import (
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//Returns what is wrong with a rating scale, or an empty string
+func validateScale(s ScaleModel) string {
+	if s.ScaleName == "" {
+		return "scale_name is required"
+	}
+	if len(s.Labels) < 2 || len(s.Labels) > 10 {
+		return "A scale has 2 to 10 labels"
+	}
+	for _, label := range s.Labels {
+		if label == "" {
+			return "Labels cannot be empty"
+		}
+	}
+	return ""
+}
+
+//For creating a rating scale. Takes scale_name and labels, from the lowest rating to the
+//highest. Scales cannot be edited, ratings given on them would change meaning
+func AddScale(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	scale := ScaleModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&scale); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateScale(scale); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `INSERT INTO rating_scales(tenant_id, scale_name, labels) VALUES ($1, $2, $3) RETURNING scale_id`
+	err := db.QueryRow(stmt, middleware.TenantId(r), scale.ScaleName, pq.Array(scale.Labels)).Scan(&scale.ScaleId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Scale name already in use",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Rating scale created with id " + strconv.FormatUint(scale.ScaleId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all rating scales
+func GetScales(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT scale_id, scale_name, labels, created_at FROM rating_scales WHERE tenant_id = $1 ORDER BY scale_name`
+	rows, err := db.Query(stmt, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []ScaleModel{}
+	for rows.Next() {
+		s := ScaleModel{}
+		if err := rows.Scan(&s.ScaleId, &s.ScaleName, pq.Array(&s.Labels), &s.CreatedAt); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, s)
+	}
+	//If everything went well, return array of scale objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For deleting a rating scale no template uses
+func DeleteScale(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get scale id from req params
+	params := mux.Vars(r)
+	scaleId, err := strconv.Atoi(params["scale_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM rating_scales WHERE scale_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(scaleId), middleware.TenantId(r))
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23503" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Rating scale is used by templates",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//Check if any row was affected by the delete operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Rating scale not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Rating scale deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,381 @@
+package performance
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//Implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+var kinds = map[string]bool{"self": true, "manager": true, "peer": true}
+
+//Returns what is wrong with a template, or an empty string
+func validateTemplate(t TemplateModel) string {
+	if t.TemplateName == "" {
+		return "template_name is required"
+	}
+	if len(t.Sections) == 0 {
+		return "A template needs at least one section"
+	}
+	for _, section := range t.Sections {
+		if section.Title == "" {
+			return "Every section needs a title"
+		}
+		if !kinds[section.Kind] {
+			return "kind must be self, manager or peer"
+		}
+	}
+	return ""
+}
+
+//Replaces the sections of a template
+func saveSections(tx *sql.Tx, t TemplateModel) error {
+	if _, err := tx.Exec(`DELETE FROM performance_template_sections WHERE template_id = $1`, t.TemplateId); err != nil {
+		return err
+	}
+	stmt := `INSERT INTO performance_template_sections(template_id, position, kind, title, description, rated)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)`
+	for i, section := range t.Sections {
+		if _, err := tx.Exec(stmt, t.TemplateId, i+1, section.Kind, section.Title, section.Description, section.Rated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Reads the template with its sections. Returns sql.ErrNoRows if tenantId has no such template
+func loadTemplate(q queryer, templateId, tenantId uint64) (TemplateModel, error) {
+	t := TemplateModel{Sections: []SectionModel{}}
+	stmt := `SELECT template_id, template_name, scale_id, created_at FROM performance_templates
+		WHERE template_id = $1 AND tenant_id = $2`
+	err := q.QueryRow(stmt, templateId, tenantId).Scan(&t.TemplateId, &t.TemplateName, &t.ScaleId, &t.CreatedAt)
+	if err != nil {
+		return t, err
+	}
+	stmt = `SELECT section_id, kind, title, COALESCE(description, ''), rated FROM performance_template_sections
+		WHERE template_id = $1 ORDER BY position`
+	rows, err := q.Query(stmt, templateId)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		s := SectionModel{}
+		if err := rows.Scan(&s.SectionId, &s.Kind, &s.Title, &s.Description, &s.Rated); err != nil {
+			return t, err
+		}
+		t.Sections = append(t.Sections, s)
+	}
+	return t, rows.Err()
+}
+
+//Checks, saves and writes the response for a template being created, or replaced when
+//templateId is not zero. Templates of launched cycles are kept as they are, answers refer
+//to their sections
+func saveTemplate(w http.ResponseWriter, r *http.Request, templateId uint64) {
+	t := TemplateModel{}
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateTemplate(t); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	var found bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM rating_scales WHERE scale_id = $1 AND tenant_id = $2)`
+	err = tx.QueryRow(stmt, t.ScaleId, tenantId).Scan(&found)
+	if err == nil && !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Rating scale not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && templateId == 0 {
+		stmt = `INSERT INTO performance_templates(tenant_id, template_name, scale_id) VALUES ($1, $2, $3)
+			RETURNING template_id`
+		err = tx.QueryRow(stmt, tenantId, t.TemplateName, t.ScaleId).Scan(&t.TemplateId)
+	} else if err == nil {
+		t.TemplateId = templateId
+		var launched bool
+		stmt = `SELECT EXISTS(SELECT 1 FROM performance_cycles c JOIN performance_templates t ON t.template_id = c.template_id
+			WHERE c.template_id = $1 AND t.tenant_id = $2 AND c.status <> 'draft')`
+		err = tx.QueryRow(stmt, templateId, tenantId).Scan(&launched)
+		if err == nil && launched {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Template is used by a launched cycle, create a new one instead",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		var result sql.Result
+		if err == nil {
+			stmt = `UPDATE performance_templates SET template_name = $3, scale_id = $4 WHERE template_id = $1 AND tenant_id = $2`
+			result, err = tx.Exec(stmt, templateId, tenantId, t.TemplateName, t.ScaleId)
+		}
+		if err == nil {
+			if count, err := result.RowsAffected(); err != nil || count == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				res := middleware.Response{
+					Error:   true,
+					Message: "Template not found",
+				}
+				json.NewEncoder(w).Encode(res)
+				return
+			}
+		}
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Template name already in use",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		err = saveSections(tx, t)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	if templateId == 0 {
+		w.WriteHeader(http.StatusCreated)
+		res := middleware.Response{
+			Error:   false,
+			Message: "Template created with id " + strconv.FormatUint(t.TemplateId, 10),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Template updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For creating a review template. Takes template_name, scale_id and the sections in order,
+//each with kind, title, description and whether it is rated
+func AddTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	saveTemplate(w, r, 0)
+}
+
+//For fetching all review templates with their sections
+func GetTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT template_id FROM performance_templates WHERE tenant_id = $1 ORDER BY template_name`
+	rows, err := db.Query(stmt, tenantId)
+	var templateIds []uint64
+	if err == nil {
+		for rows.Next() {
+			var id uint64
+			if err = rows.Scan(&id); err != nil {
+				break
+			}
+			templateIds = append(templateIds, id)
+		}
+		rows.Close()
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	data := []TemplateModel{}
+	for _, templateId := range templateIds {
+		if err != nil {
+			break
+		}
+		var t TemplateModel
+		if t, err = loadTemplate(db, templateId, tenantId); err == nil {
+			data = append(data, t)
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of template objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching a single review template with its sections
+func GetTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get template id from req params
+	params := mux.Vars(r)
+	templateId, err := strconv.Atoi(params["template_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	t, err := loadTemplate(db, uint64(templateId), middleware.TenantId(r))
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Template not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return the template object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(t)
+}
+
+//For editing a review template that no launched cycle uses. Every field is replaced,
+//sections included
+func EditTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get template id from req params
+	params := mux.Vars(r)
+	templateId, err := strconv.Atoi(params["template_id"])
+	if err != nil || templateId <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	saveTemplate(w, r, uint64(templateId))
+}
+
+//For deleting a review template no cycle uses
+func DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get template id from req params
+	params := mux.Vars(r)
+	templateId, err := strconv.Atoi(params["template_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM performance_templates WHERE template_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(templateId), middleware.TenantId(r))
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23503" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Template is used by review cycles",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Template not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Template deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,16 @@
+package policy
+
+import "time"
+
+type PolicyModel struct {
+	PolicyId    uint64    `json:"id"`
+	PolicyName  string    `json:"policy_name"`
+	Description string    `json:"description"`
+	Effect      string    `json:"effect"`
+	Actions     []string  `json:"actions"`
+	Condition   string    `json:"condition"`
+	Version     uint64    `json:"version"`
+	Active      bool      `json:"active"`
+	CreatedBy   uint64    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,485 @@
+package policy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"hrm/policy/rule"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//Returns why the effect, actions or condition of a policy are not acceptable
+func validate(policy PolicyModel) string {
+	if policy.Effect != "permit" && policy.Effect != "deny" {
+		return "Effect must be permit or deny"
+	}
+	if len(policy.Actions) == 0 {
+		return "Policy must apply to at least one action"
+	}
+	if _, err := rule.Parse(policy.Condition); err != nil {
+		return "Invalid condition: " + err.Error()
+	}
+	return ""
+}
+
+//For adding a new policy. It starts at version 1
+func AddNewPolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	policy := PolicyModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validate(policy); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	stmt := `INSERT INTO policies(policy_name, description, tenant_id) VALUES ($1, $2, $3) RETURNING policy_id`
+	err = tx.QueryRow(stmt, policy.PolicyName, policy.Description, principal.TenantId).Scan(&policy.PolicyId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		//Check for duplicate data
+		if err.Code == "42701" || err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Policy already exists",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			//For all other errors
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	stmt = `INSERT INTO policy_versions(policy_id, version, effect, actions, condition, created_by, tenant_id)
+		VALUES ($1, 1, $2, $3, $4, $5, $6)`
+	_, err = tx.Exec(stmt, policy.PolicyId, policy.Effect, pq.Array(policy.Actions), policy.Condition, principal.UserId,
+		principal.TenantId)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Policy added",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+const selectPolicy = `SELECT p.policy_id, p.policy_name, COALESCE(p.description, ''), v.effect, v.actions,
+		v.condition, v.version, p.active, COALESCE(v.created_by, 0), v.created_at
+	FROM policies p JOIN policy_versions v ON v.policy_id = p.policy_id`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPolicy(row scanner, policy *PolicyModel) error {
+	return row.Scan(&policy.PolicyId, &policy.PolicyName, &policy.Description, &policy.Effect,
+		pq.Array(&policy.Actions), &policy.Condition, &policy.Version, &policy.Active,
+		&policy.CreatedBy, &policy.CreatedAt)
+}
+
+//For fetching the current version of every policy
+func GetPolicies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	data := []PolicyModel{}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectPolicy + ` AND v.version = p.current_version WHERE p.tenant_id = $1 ORDER BY p.policy_id`
+	rows, err := db.Query(stmt, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		policy := PolicyModel{}
+		if err := scanPolicy(rows, &policy); err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Unable to scan policy result set" + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, policy)
+	}
+	//If everything went well, return array of policy objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the current version of a single policy
+func GetPolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	policy := PolicyModel{}
+	//Extract policy id from req params
+	params := mux.Vars(r)
+	policyId, err := strconv.Atoi(params["policy_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectPolicy + ` AND v.version = p.current_version WHERE p.policy_id = $1 AND p.tenant_id = $2`
+	err = scanPolicy(db.QueryRow(stmt, uint64(policyId), middleware.TenantId(r)), &policy)
+	//Check for no data found error
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Policy not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return the policy object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(policy)
+}
+
+//For fetching every version of a policy, newest first
+func GetPolicyVersions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	data := []PolicyModel{}
+	//Extract policy id from req params
+	params := mux.Vars(r)
+	policyId, err := strconv.Atoi(params["policy_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectPolicy + ` WHERE p.policy_id = $1 AND p.tenant_id = $2 ORDER BY v.version DESC`
+	rows, err := db.Query(stmt, uint64(policyId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		policy := PolicyModel{}
+		if err := scanPolicy(rows, &policy); err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Unable to scan policy result set" + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, policy)
+	}
+	if len(data) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Policy not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of policy versions
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//Stores policy as the next version of policyId and makes it current. Returns sql.ErrNoRows
+//if the policy does not belong to the tenant of createdBy
+func addVersion(db *sql.DB, policyId uint64, policy PolicyModel, createdBy middleware.Principal) (uint64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var version uint64
+	//Lock the policy row so concurrent edits get distinct version numbers
+	stmt := `UPDATE policies SET current_version = current_version + 1, active = TRUE
+		WHERE policy_id = $1 AND tenant_id = $2 RETURNING current_version`
+	if err := tx.QueryRow(stmt, policyId, createdBy.TenantId).Scan(&version); err != nil {
+		return 0, err
+	}
+	stmt = `INSERT INTO policy_versions(policy_id, version, effect, actions, condition, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = tx.Exec(stmt, policyId, version, policy.Effect, pq.Array(policy.Actions), policy.Condition,
+		createdBy.UserId, createdBy.TenantId)
+	if err != nil {
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+//For editing a policy. The previous versions are kept
+func EditPolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	policy := PolicyModel{}
+	//Extract policy id from req params
+	params := mux.Vars(r)
+	policyId, err := strconv.Atoi(params["policy_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validate(policy); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	version, err := addVersion(db, uint64(policyId), policy, principal)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Policy not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Policy updated to version " + strconv.FormatUint(version, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For rolling a policy back. The old version is copied into a new one so history stays linear
+func RestorePolicyVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract policy id and version from req params
+	params := mux.Vars(r)
+	policyId, err := strconv.Atoi(params["policy_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	version, err := strconv.Atoi(params["version"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	policy := PolicyModel{}
+	stmt := selectPolicy + ` WHERE p.policy_id = $1 AND v.version = $2 AND p.tenant_id = $3`
+	err = scanPolicy(db.QueryRow(stmt, uint64(policyId), uint64(version), principal.TenantId), &policy)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Policy version not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	newVersion, err := addVersion(db, uint64(policyId), policy, principal)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Version " + params["version"] + " restored as version " + strconv.FormatUint(newVersion, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For switching a policy off. Its versions are kept and editing it switches it back on
+func DeletePolicy(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract policy id from req params
+	params := mux.Vars(r)
+	policyId, err := strconv.Atoi(params["policy_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `UPDATE policies SET active = FALSE WHERE policy_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(policyId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Check if any row was affected by the update operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Policy not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Policy deactivated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,11 @@
+package policy
+
+import "hrm/catalog"
+
+//Privileges required by the policy routes
+var (
+	PrivCreatePolicy = catalog.Declare("create_policy", "Create an attribute based policy")
+	PrivReadPolicies = catalog.Declare("read_policies", "Read policies and their versions")
+	PrivModifyPolicy = catalog.Declare("modify_policy", "Edit a policy or restore an older version")
+	PrivDeletePolicy = catalog.Declare("delete_policy", "Deactivate a policy")
+)
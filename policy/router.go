@@ -0,0 +1,37 @@
+package policy
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandlePolicyRoutes(r *mux.Router) {
+	//Endpoint for adding a new policy
+	r.HandleFunc("/policies",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivCreatePolicy, AddNewPolicy))).Methods("POST")
+
+	//Endpoint for fetching all policies
+	r.HandleFunc("/policies",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadPolicies, GetPolicies))).Methods("GET")
+
+	//Endpoint for fetching a single policy by id
+	r.HandleFunc("/policies/{policy_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadPolicies, GetPolicy))).Methods("GET")
+
+	//Endpoint for editing a policy. Creates a new version
+	r.HandleFunc("/policies/{policy_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyPolicy, EditPolicy))).Methods("PUT")
+
+	//Endpoint for deactivating a policy
+	r.HandleFunc("/policies/{policy_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDeletePolicy, DeletePolicy))).Methods("DELETE")
+
+	//Endpoint for fetching the version history of a policy
+	r.HandleFunc("/policies/{policy_id}/versions",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadPolicies, GetPolicyVersions))).Methods("GET")
+
+	//Endpoint for restoring an older version of a policy
+	r.HandleFunc("/policies/{policy_id}/versions/{version}/restore",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyPolicy, RestorePolicyVersion))).Methods("POST")
+}
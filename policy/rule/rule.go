@@ -0,0 +1,502 @@
+//Package rule implements the small condition language used by policies.
+//
+//A condition is a boolean expression over dotted attribute names, for example
+//
+//	overlaps(principal.group_ids, resource.group_ids) and env.hour >= 9 and env.hour < 17
+//	env.weekday in [1, 2, 3, 4, 5] && ip_in(env.ip, "10.0.0.0/8", "192.168.0.0/16")
+//
+//Supported are number, string and boolean literals, lists, the comparison operators
+//== != < <= > >= and in, the logical operators && || ! (or and, or, not), parentheses
+//and the functions ip_in(ip, cidr...), lower(string) and overlaps(list, list). An attribute
+//that is not set evaluates to null, which is only equal to null, never ordered and contains nothing.
+package rule
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+//Attribute values keyed by their dotted name, e.g. "principal.user_id"
+type Attributes map[string]interface{}
+
+type Rule struct {
+	src  string
+	root node
+}
+
+//Parses a condition. The returned rule can be evaluated any number of times.
+func Parse(src string) (*Rule, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Rule{src: src, root: root}, nil
+}
+
+func (r *Rule) String() string {
+	return r.src
+}
+
+//Evaluates the rule against attrs. The rule must produce a boolean.
+func (r *Rule) Eval(attrs Attributes) (bool, error) {
+	v, err := r.root.eval(attrs)
+	if err != nil {
+		return false, err
+	}
+	return toBool(v)
+}
+
+type node interface {
+	eval(attrs Attributes) (interface{}, error)
+}
+
+type literal struct{ value interface{} }
+
+type attribute struct{ name string }
+
+type list struct{ items []node }
+
+type unary struct {
+	op string
+	x  node
+}
+
+type binary struct {
+	op   string
+	l, r node
+}
+
+type call struct {
+	name string
+	args []node
+}
+
+var functions = map[string]func(args []interface{}) (interface{}, error){
+	"ip_in":    ipIn,
+	"lower":    lower,
+	"overlaps": overlaps,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if t := p.next(); t.kind != kind {
+		return fmt.Errorf("expected %q at %d, got %q", text, t.pos, t.text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && t.text == "||"; t = p.peek() {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{"||", l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t.kind == tokOp && t.text == "&&"; t = p.peek() {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = binary{"&&", l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if t := p.peek(); t.kind == tokOp && t.text == "!" {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return unary{"!", x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	l, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokOp {
+		return l, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=", "in":
+		p.next()
+		r, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return binary{t.text, l, r}, nil
+	}
+	return l, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", t.text, t.pos)
+		}
+		return literal{f}, nil
+	case tokString:
+		return literal{t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		if p.peek().kind != tokLParen {
+			return attribute{t.text}, nil
+		}
+		if _, ok := functions[t.text]; !ok {
+			return nil, fmt.Errorf("unknown function %q at %d", t.text, t.pos)
+		}
+		p.next()
+		args, err := p.parseItems(tokRParen, ")")
+		if err != nil {
+			return nil, err
+		}
+		return call{t.text, args}, nil
+	case tokLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case tokLBracket:
+		items, err := p.parseItems(tokRBracket, "]")
+		if err != nil {
+			return nil, err
+		}
+		return list{items}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+//Parses a comma separated sequence up to and including the closing token
+func (p *parser) parseItems(closing tokenKind, text string) ([]node, error) {
+	var items []node
+	if p.peek().kind == closing {
+		p.next()
+		return items, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(closing, text); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (n literal) eval(attrs Attributes) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n attribute) eval(attrs Attributes) (interface{}, error) {
+	return normalize(attrs[n.name]), nil
+}
+
+func (n list) eval(attrs Attributes) (interface{}, error) {
+	values := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(attrs)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (n unary) eval(attrs Attributes) (interface{}, error) {
+	v, err := n.x.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toBool(v)
+	if err != nil {
+		return nil, err
+	}
+	return !b, nil
+}
+
+func (n binary) eval(attrs Attributes) (interface{}, error) {
+	l, err := n.l.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	//Logical operators short-circuit
+	if n.op == "&&" || n.op == "||" {
+		lb, err := toBool(l)
+		if err != nil {
+			return nil, err
+		}
+		if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+			return lb, nil
+		}
+		r, err := n.r.eval(attrs)
+		if err != nil {
+			return nil, err
+		}
+		return toBool(r)
+	}
+	r, err := n.r.eval(attrs)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		//Nothing is in a missing attribute or one that is not a list
+		items, ok := r.([]interface{})
+		if !ok {
+			return false, nil
+		}
+		for _, item := range items {
+			if equal(l, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return compare(n.op, l, r)
+}
+
+func (n call) eval(attrs Attributes) (interface{}, error) {
+	args := make([]interface{}, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(attrs)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	return functions[n.name](args)
+}
+
+//Attribute values come from Go code, so bring them to the types literals use
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint:
+		return float64(x)
+	case uint32:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case []string:
+		values := make([]interface{}, 0, len(x))
+		for _, s := range x {
+			values = append(values, s)
+		}
+		return values
+	case []uint64:
+		values := make([]interface{}, 0, len(x))
+		for _, n := range x {
+			values = append(values, float64(n))
+		}
+		return values
+	}
+	return v
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch x := v.(type) {
+	case bool:
+		return x, nil
+	case nil:
+		return false, nil
+	}
+	return false, fmt.Errorf("expected a boolean, got %v", v)
+}
+
+func equal(l, r interface{}) bool {
+	switch x := l.(type) {
+	case nil:
+		return r == nil
+	case float64, string, bool:
+		return l == r
+	case []interface{}:
+		y, ok := r.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func compare(op string, l, r interface{}) (interface{}, error) {
+	if l == nil || r == nil {
+		return false, nil
+	}
+	var c int
+	switch x := l.(type) {
+	case float64:
+		y, ok := r.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %v with %v", l, r)
+		}
+		switch {
+		case x < y:
+			c = -1
+		case x > y:
+			c = 1
+		}
+	case string:
+		y, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %v with %v", l, r)
+		}
+		c = strings.Compare(x, y)
+	default:
+		return nil, fmt.Errorf("cannot order %v", l)
+	}
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+//ip_in(ip, cidr...) reports whether ip falls into any of the cidr ranges
+func ipIn(args []interface{}) (interface{}, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("ip_in expects an ip and at least one cidr")
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return false, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false, nil
+	}
+	for _, arg := range args[1:] {
+		cidr, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("ip_in expects cidr strings")
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		if network.Contains(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func lower(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("lower expects one argument")
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return args[0], nil
+	}
+	return strings.ToLower(s), nil
+}
+
+//overlaps(a, b) reports whether the lists a and b have an item in common
+func overlaps(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("overlaps expects two lists")
+	}
+	a, okA := args[0].([]interface{})
+	b, okB := args[1].([]interface{})
+	if !okA || !okB {
+		return false, nil
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if equal(x, y) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,130 @@
+package rule
+
+import "testing"
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		//&& binds tighter than ||
+		{"true || false && false", true},
+		{"false && false || true", true},
+		{"(true || false) && false", false},
+		//! binds tighter than && and ||
+		{"!false && false", false},
+		{"!(false && false)", true},
+		{"not true or true", true},
+		{"!!true", true},
+		//Comparisons bind tighter than the logical operators
+		{"env.hour >= 9 and env.hour < 17", true},
+		{"env.hour < 9 || env.hour >= 17", false},
+		{"!env.hour == 10", false},
+		{"env.weekday in [1, 2, 3] && env.hour == 10", true},
+		{"lower(principal.name) == 'ann' and ip_in(env.ip, '10.0.0.0/8')", true},
+	}
+	attrs := Attributes{
+		"env.hour":       10,
+		"env.weekday":    uint64(2),
+		"env.ip":         "10.1.2.3",
+		"principal.name": "Ann",
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		got, err := r.Eval(attrs)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	tests := []string{
+		"",
+		"env.hour ==",
+		"(env.hour == 1",
+		"env.hour == 1)",
+		"env.hour 1",
+		"env.hour == 'open",
+		"env.hour @ 1",
+		"env.hour == 1.2.3",
+		"env.weekday in [1, 2",
+		"env.weekday in [1 2]",
+		"unknown(env.hour)",
+		"ip_in(env.ip, '10.0.0.0/8'",
+		"&& true",
+		"true ||",
+	}
+	for _, src := range tests {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", src)
+		}
+	}
+}
+
+func TestUnknownAttributes(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		//An attribute that is not set is null, which is only equal to null, never ordered and contains nothing
+		{"missing == null", true},
+		{"missing != null", false},
+		{"missing == 0", false},
+		{"missing == ''", false},
+		{"missing < 5", false},
+		{"missing >= 5", false},
+		{"missing in [1, 2]", false},
+		{"1 in missing", false},
+		{"'a' in missing", false},
+		//Neither does an attribute that is not a list
+		{"1 in env.hour", false},
+		{"missing", false},
+		{"ip_in(missing, '10.0.0.0/8')", false},
+		{"overlaps(missing, [1])", false},
+	}
+	for _, tt := range tests {
+		r, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		got, err := r.Eval(Attributes{"env.hour": 10})
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.src, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []string{
+		"1",
+		"'yes' && true",
+		"env.hour < 'nine'",
+		"ip_in(env.ip, 'not a cidr')",
+		"lower('a', 'b') == 'a'",
+	}
+	attrs := Attributes{"env.hour": 10, "env.ip": "10.1.2.3"}
+	for _, src := range tests {
+		r, err := Parse(src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", src, err)
+			continue
+		}
+		if _, err := r.Eval(attrs); err == nil {
+			t.Errorf("Eval(%q) succeeded, want an error", src)
+		}
+	}
+}
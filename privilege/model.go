@@ -5,4 +5,6 @@ type PrivilegeModel struct{
 	PrivilegeId uint64 `json:"id"`
 	PrivilegeName string `json:"privilege_name"`
 	Description string `json:"description"`
-}
\ No newline at end of file
+	//Set by the catalog sync when no package declares the privilege any more
+	Orphaned bool `json:"orphaned"`
+}
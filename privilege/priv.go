@@ -3,6 +3,7 @@ package privilege
 import (
 	"database/sql"
 	"encoding/json"
+	"hrm/catalog"
 	"hrm/db"
 	"hrm/middleware"
 	"net/http"
@@ -12,6 +13,16 @@ import (
 	"github.com/lib/pq"
 )
 
+//Reports whether the caller holds cross_tenant_admin
+func holdsCrossTenant(r *http.Request) bool {
+	for _, p := range middleware.PrivilegesFromContext(r.Context()) {
+		if p == middleware.PrivCrossTenant {
+			return true
+		}
+	}
+	return false
+}
+
 //For adding a new privilege
 func AddNewPrivilege(w http.ResponseWriter, r *http.Request) {
 	priv := PrivilegeModel{}
@@ -24,12 +35,23 @@ func AddNewPrivilege(w http.ResponseWriter, r *http.Request) {
 			Message: "Unable to parse req body to json",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Names of declared privileges are taken in every tenant
+	if catalog.IsDeclared(priv.PrivilegeName) {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Privilege name is reserved by a declared privilege",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `INSERT INTO privileges(privilege_name, description) VALUES ($1, $2)`
-	_, err = db.Exec(stmt)
+	stmt := `INSERT INTO privileges(privilege_name, description, tenant_id) VALUES ($1, $2, $3)`
+	_, err = db.Exec(stmt, priv.PrivilegeName, priv.Description, middleware.TenantId(r))
 	//Check for errors
 	if err, ok := err.(*pq.Error); ok {
 		if err.Code == "23505" || err.Code == "42701" {
@@ -48,6 +70,7 @@ func AddNewPrivilege(w http.ResponseWriter, r *http.Request) {
 			}
 			json.NewEncoder(w).Encode(res)
 		}
+		return
 	}
 	//If everything went well, return response
 	w.WriteHeader(http.StatusCreated)
@@ -62,43 +85,50 @@ func AddNewPrivilege(w http.ResponseWriter, r *http.Request) {
 func DeletePrivilege(w http.ResponseWriter, r *http.Request) {
 	//Extract privilege id from req params
 	params := mux.Vars(r)
-	privId, err := strconv.Atoi(params["id"])
+	privId, err := strconv.Atoi(params["privilege_id"])
 	if err != nil {
 		res := middleware.Response{
 			Error:   true,
 			Message: "Unable to convert req params to int",
 		}
 		json.NewEncoder(w).Encode(res)
-		//Call db connection
-		db := db.ConnectDB()
-		defer db.Close()
-		stmt := `DELETE FROM privileges WHERE privilege_id = $1`
-		result, err := db.Exec(stmt, uint64(privId))
-		//Check for errors
-		if err, ok := err.(*pq.Error); ok {
-			res := middleware.Response{
-				Error:   true,
-				Message: err.Error(),
-			}
-			json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	crossTenant := holdsCrossTenant(r)
+	//Declared privileges have no tenant. Only cross-tenant admins delete them, once the
+	//catalog sync has flagged them as orphaned
+	stmt := `DELETE FROM privileges WHERE privilege_id = $1
+		AND (tenant_id = $2 OR ($3 AND tenant_id IS NULL AND orphaned))`
+	result, err := db.Exec(stmt, uint64(privId), middleware.TenantId(r), crossTenant)
+	//Check for errors
+	if err, ok := err.(*pq.Error); ok {
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
 		}
-		//Check if any row is affected by the delete operation
-		if count, err := result.RowsAffected(); err != nil {
-			w.WriteHeader(http.StatusExpectationFailed)
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Check if any row is affected by the delete operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+	} else {
+		if count == 0 {
+			w.WriteHeader(http.StatusNotImplemented)
 			res := middleware.Response{
 				Error:   true,
-				Message: "Error counting rows affected by delete operation",
+				Message: "No row affected by the delete operation",
 			}
 			json.NewEncoder(w).Encode(res)
-		} else {
-			if count == 0 {
-				w.WriteHeader(http.StatusNotImplemented)
-				res := middleware.Response{
-					Error:   true,
-					Message: "No row affected by the delete operation",
-				}
-				json.NewEncoder(w).Encode(res)
-			}
+			return
 		}
 	}
 	//If everything went well, return response
@@ -116,7 +146,7 @@ func GetPrivilege(w http.ResponseWriter, r *http.Request) {
 	priv := PrivilegeModel{}
 	//Extract privilege id from req params
 	params := mux.Vars(r)
-	privId, err := strconv.Atoi(params["id"])
+	privId, err := strconv.Atoi(params["privilege_id"])
 	if err != nil {
 		res := middleware.Response{
 			Error:   true,
@@ -127,9 +157,10 @@ func GetPrivilege(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT * FROM privileges WHERE privilege_id = $1`
-	row := db.QueryRow(stmt, uint64(privId))
-	err = row.Scan(&priv.PrivilegeId, &priv.PrivilegeName, &priv.Description)
+	stmt := `SELECT privilege_id, privilege_name, COALESCE(description, ''), orphaned
+		FROM privileges WHERE privilege_id = $1 AND (tenant_id IS NULL OR tenant_id = $2)`
+	row := db.QueryRow(stmt, uint64(privId), middleware.TenantId(r))
+	err = row.Scan(&priv.PrivilegeId, &priv.PrivilegeName, &priv.Description, &priv.Orphaned)
 	//Check for no data found error
 	if err == sql.ErrNoRows {
 		w.WriteHeader(http.StatusNotFound)
@@ -159,8 +190,9 @@ func GetPrivileges(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT * FROM privileges`
-	rows, err := db.Query(stmt)
+	stmt := `SELECT privilege_id, privilege_name, COALESCE(description, ''), orphaned FROM privileges
+		WHERE tenant_id IS NULL OR tenant_id = $1`
+	rows, err := db.Query(stmt, middleware.TenantId(r))
 	//Check for errors
 	if err, ok := err.(*pq.Error); ok {
 		if err.Code == "02000" || err.Code == "P0002" {
@@ -183,7 +215,7 @@ func GetPrivileges(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 	for rows.Next() {
 		privs := PrivilegeModel{}
-		err := rows.Scan(&privs.PrivilegeId, &privs.PrivilegeName, &privs.Description)
+		err := rows.Scan(&privs.PrivilegeId, &privs.PrivilegeName, &privs.Description, &privs.Orphaned)
 		if err != nil {
 			w.WriteHeader(http.StatusExpectationFailed)
 			res := middleware.Response{
@@ -204,7 +236,7 @@ func EditPrivilege(w http.ResponseWriter, r *http.Request) {
 	priv := PrivilegeModel{}
 	//Extract privilege id from req params
 	params := mux.Vars(r)
-	privId, err := strconv.Atoi(params["id"])
+	privId, err := strconv.Atoi(params["privilege_id"])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		res := middleware.Response{
@@ -222,12 +254,25 @@ func EditPrivilege(w http.ResponseWriter, r *http.Request) {
 			Message: "Unable to parse req body to json",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if catalog.IsDeclared(priv.PrivilegeName) {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Privilege name is reserved by a declared privilege",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := ` UPDATE privileges SET privilege_name = $2, description = $3 WHERE id = $1`
-	result, err := db.Exec(stmt, privId, priv.PrivilegeName, priv.Description)
+	crossTenant := holdsCrossTenant(r)
+	//Same rows as DeletePrivilege: the tenant's own, and orphaned declared ones for cross-tenant admins
+	stmt := ` UPDATE privileges SET privilege_name = $2, description = $3 WHERE privilege_id = $1
+		AND (tenant_id = $4 OR ($5 AND tenant_id IS NULL AND orphaned))`
+	result, err := db.Exec(stmt, privId, priv.PrivilegeName, priv.Description, middleware.TenantId(r), crossTenant)
 	//Checking for errors
 	if err, ok := err.(*pq.Error); ok {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -0,0 +1,12 @@
+package privilege
+
+import "hrm/catalog"
+
+//Privileges required by the privilege routes
+var (
+	PrivAddPrivilege = catalog.Declare("add_privilege", "Add a privilege by hand")
+	PrivReadOnePriv  = catalog.Declare("read_one_priv", "Read a privilege")
+	PrivReadAllPrivs = catalog.Declare("read_all_privs", "List all privileges")
+	PrivModifyPriv   = catalog.Declare("modify_priv", "Edit a privilege")
+	PrivDeletePriv   = catalog.Declare("delete_priv", "Delete a privilege")
+)
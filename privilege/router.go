@@ -2,24 +2,29 @@ package privilege
 
 import (
 	"hrm/middleware"
-	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func HandleUserRoutes(r *mux.Router) {
+func HandlePrivilegeRoutes(r *mux.Router) {
 	//Endpoint for adding a new privilege
-	http.HandleFunc("/newpriv", middleware.JwtVerify(middleware.IsAuthorize("add_privilege", AddNewPrivilege)))
+	r.HandleFunc("/newpriv",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivAddPrivilege, AddNewPrivilege))).Methods("POST")
 
 	//Endpoint for fetching a single privilege by id
-	http.HandleFunc("/privs/:privilege_id",
-		middleware.JwtVerify(middleware.IsAuthorize("read_one_priv", GetPrivilege)))
+	r.HandleFunc("/privs/{privilege_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadOnePriv, GetPrivilege))).Methods("GET")
 
 	//Endpoint for fetching all privileges
-	http.HandleFunc("/privs",
-		middleware.JwtVerify(middleware.IsAuthorize("read_all_privs", GetPrivileges)))
+	r.HandleFunc("/privs",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadAllPrivs, GetPrivileges))).Methods("GET")
 
 	//Endpoint for editing a single privilege by id
-	http.HandleFunc("/privs/:privilege_id",
-		middleware.JwtVerify(middleware.IsAuthorize("modify_priv", EditPrivilege)))
+	r.HandleFunc("/privs/{privilege_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivModifyPriv, EditPrivilege))).Methods("PUT")
+
+	//Endpoint for deleting a privilege of the tenant. Cross-tenant admins also delete the
+	//declared privileges the catalog sync flagged as orphaned
+	r.HandleFunc("/privs/{privilege_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDeletePriv, DeletePrivilege))).Methods("DELETE")
 }
@@ -0,0 +1,66 @@
+package review
+
+import (
+	"encoding/json"
+	"hrm/role"
+	"time"
+)
+
+type CampaignModel struct {
+	CampaignId   uint64 `json:"id"`
+	CampaignName string `json:"campaign_name"`
+	Description  string `json:"description"`
+	//open or closed
+	Status    string     `json:"status"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+	CreatedBy uint64     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ClosedBy  uint64     `json:"closed_by,omitempty"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	Items     int        `json:"items"`
+	Pending   int        `json:"pending"`
+}
+
+//A grant as it was when the campaign opened, with the reviewer's decision
+type ItemModel struct {
+	ItemId     uint64 `json:"id"`
+	CampaignId uint64 `json:"campaign_id"`
+	//user_role, group_role or role_privilege
+	GrantType   string `json:"grant_type"`
+	SubjectId   uint64 `json:"subject_id"`
+	SubjectName string `json:"subject_name"`
+	ObjectId    uint64 `json:"object_id"`
+	ObjectName  string `json:"object_name"`
+	role.Validity
+	ReviewerId uint64 `json:"reviewer_id,omitempty"`
+	//keep, revoke or empty while undecided
+	Decision  string     `json:"decision"`
+	Comment   string     `json:"comment,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type DecisionModel struct {
+	Decision string `json:"decision"`
+	Comment  string `json:"comment"`
+}
+
+type ReviewerModel struct {
+	ReviewerId uint64 `json:"reviewer_id"`
+}
+
+type ReportModel struct {
+	Campaign    CampaignModel `json:"campaign"`
+	Kept        int           `json:"kept"`
+	Revoked     int           `json:"revoked"`
+	Undecided   int           `json:"undecided"`
+	Items       []ItemModel   `json:"items"`
+	GeneratedAt time.Time     `json:"generated_at"`
+}
+
+//Signature is the hex HMAC-SHA256 of the exact bytes of Report
+type SignedReportModel struct {
+	Report    json.RawMessage `json:"report"`
+	Algorithm string          `json:"algorithm"`
+	Signature string          `json:"signature"`
+}
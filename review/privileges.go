@@ -0,0 +1,9 @@
+package review
+
+import "hrm/catalog"
+
+//Privileges required by the access review routes
+var (
+	PrivManageReviews = catalog.Declare("manage_reviews", "Open, close and report on access review campaigns")
+	PrivReviewAccess  = catalog.Declare("review_access", "Decide the review items assigned to the caller")
+)
@@ -0,0 +1,225 @@
+package review
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+//Each statement removes one grant given subject_id and object_id, matching the
+//subject and object of grant_events. A grant changed since the snapshot is left alone
+var revokeGrant = map[string]string{
+	"user_role": `UPDATE users SET role_id = NULL, role_valid_from = NULL, role_valid_until = NULL
+		WHERE user_id = $1 AND role_id = $2`,
+	"group_role":     `DELETE FROM group_roles WHERE group_id = $1 AND role_id = $2`,
+	"role_privilege": `DELETE FROM role_privileges WHERE role_id = $1 AND privilege_id = $2`,
+}
+
+//Closes the campaign and carries out its revoke decisions in one transaction.
+//Returns sql.ErrNoRows if there is no open campaign with this id in the tenant
+func closeCampaign(db *sql.DB, tenantId, campaignId, closedBy uint64) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt := `UPDATE review_campaigns SET status = 'closed', closed_by = $2, closed_at = NOW()
+		WHERE campaign_id = $1 AND status = 'open' AND tenant_id = $3 RETURNING campaign_id`
+	if err := tx.QueryRow(stmt, campaignId, closedBy, tenantId).Scan(&campaignId); err != nil {
+		return 0, err
+	}
+	type revoke struct {
+		itemId, subjectId, objectId uint64
+		grantType                   string
+	}
+	var revokes []revoke
+	stmt = `SELECT item_id, grant_type, subject_id, object_id FROM review_items
+		WHERE campaign_id = $1 AND decision = 'revoke'`
+	rows, err := tx.Query(stmt, campaignId)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		v := revoke{}
+		if err := rows.Scan(&v.itemId, &v.grantType, &v.subjectId, &v.objectId); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		revokes = append(revokes, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, v := range revokes {
+		result, err := tx.Exec(revokeGrant[v.grantType], v.subjectId, v.objectId)
+		if err != nil {
+			return 0, err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		stmt := `UPDATE review_items SET revoked_at = NOW() WHERE item_id = $1`
+		if _, err := tx.Exec(stmt, v.itemId); err != nil {
+			return 0, err
+		}
+		stmt = `INSERT INTO grant_events(event_type, grant_type, subject_id, object_id, tenant_id)
+			VALUES ('review_revoked', $1, $2, $3, $4)`
+		if _, err := tx.Exec(stmt, v.grantType, v.subjectId, v.objectId, tenantId); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, tx.Commit()
+}
+
+//For closing a campaign. Items decided as revoke lose their grant, undecided items are kept
+//and show as undecided in the report
+func CloseCampaign(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract campaign id from req params
+	params := mux.Vars(r)
+	campaignId, err := strconv.Atoi(params["campaign_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	count, err := closeCampaign(db, principal.TenantId, uint64(campaignId), principal.UserId)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "No open campaign with this id",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Campaign closed, " + strconv.Itoa(count) + " grants revoked",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For exporting the completion report of a closed campaign, signed with REPORT_SIGNING_KEY.
+//Auditors recompute the HMAC over the report field to check it was not altered
+func GetCampaignReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract campaign id from req params
+	params := mux.Vars(r)
+	campaignId, err := strconv.Atoi(params["campaign_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	key := os.Getenv("REPORT_SIGNING_KEY")
+	if key == "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "REPORT_SIGNING_KEY is not set",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	report := ReportModel{GeneratedAt: time.Now().UTC()}
+	stmt := selectCampaign + ` WHERE c.campaign_id = $1 AND c.tenant_id = $2`
+	err = scanCampaign(db.QueryRow(stmt, uint64(campaignId), middleware.TenantId(r)), &report.Campaign)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Campaign not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && report.Campaign.Status != "closed" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Campaign must be closed before it can be reported",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		report.Items, err = queryItems(db, selectItem+` WHERE campaign_id = $1 ORDER BY item_id`, report.Campaign.CampaignId)
+	}
+	var body []byte
+	if err == nil {
+		for _, item := range report.Items {
+			switch item.Decision {
+			case "keep":
+				report.Kept++
+			case "revoke":
+				report.Revoked++
+			default:
+				report.Undecided++
+			}
+		}
+		body, err = json.Marshal(report)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(body)
+	signed := SignedReportModel{
+		Report:    body,
+		Algorithm: "HMAC-SHA256",
+		Signature: hex.EncodeToString(mac.Sum(nil)),
+	}
+	w.Header().Set("X-Report-Signature", signed.Signature)
+	//If everything went well, return the signed report
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(signed)
+}
@@ -0,0 +1,452 @@
+package review
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//Copies every grant into review items of campaign $1. User roles go to the user's manager,
+//then the role owner; group roles to the group owner, then the role owner; role privileges
+//to the role owner. The campaign creator $2 reviews whatever has nobody else.
+//Only the grants of tenant $3 are copied.
+const snapshotGrants = `INSERT INTO review_items(campaign_id, grant_type, subject_id, subject_name,
+		object_id, object_name, valid_from, valid_until, reviewer_id, tenant_id)
+	SELECT $1, 'user_role', u.user_id, u.username, r.role_id, r.role_name, u.role_valid_from, u.role_valid_until,
+		COALESCE(e.manager_id, NULLIF(r.owner_id, u.user_id), $2), $3
+	FROM users u JOIN roles r ON r.role_id = u.role_id LEFT JOIN employees e ON e.user_id = u.user_id
+	WHERE u.tenant_id = $3
+	UNION ALL
+	SELECT $1, 'group_role', g.group_id, g.group_name, r.role_id, r.role_name, gr.valid_from, gr.valid_until,
+		COALESCE(g.owner_id, r.owner_id, $2), $3
+	FROM group_roles gr JOIN groups g ON g.group_id = gr.group_id JOIN roles r ON r.role_id = gr.role_id
+	WHERE gr.tenant_id = $3
+	UNION ALL
+	SELECT $1, 'role_privilege', r.role_id, r.role_name, p.privilege_id, p.privilege_name, rp.valid_from, rp.valid_until,
+		COALESCE(r.owner_id, $2), $3
+	FROM role_privileges rp JOIN roles r ON r.role_id = rp.role_id JOIN privileges p ON p.privilege_id = rp.privilege_id
+	WHERE rp.tenant_id = $3`
+
+const selectCampaign = `SELECT c.campaign_id, c.campaign_name, COALESCE(c.description, ''), c.status, c.due_at,
+		COALESCE(c.created_by, 0), c.created_at, COALESCE(c.closed_by, 0), c.closed_at,
+		(SELECT COUNT(*) FROM review_items i WHERE i.campaign_id = c.campaign_id),
+		(SELECT COUNT(*) FROM review_items i WHERE i.campaign_id = c.campaign_id AND i.decision IS NULL)
+	FROM review_campaigns c`
+
+const selectItem = `SELECT item_id, campaign_id, grant_type, subject_id, subject_name, object_id, object_name,
+		valid_from, valid_until, COALESCE(reviewer_id, 0), COALESCE(decision, ''), COALESCE(comment, ''),
+		decided_at, revoked_at
+	FROM review_items`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCampaign(row scanner, campaign *CampaignModel) error {
+	return row.Scan(&campaign.CampaignId, &campaign.CampaignName, &campaign.Description, &campaign.Status,
+		&campaign.DueAt, &campaign.CreatedBy, &campaign.CreatedAt, &campaign.ClosedBy, &campaign.ClosedAt,
+		&campaign.Items, &campaign.Pending)
+}
+
+func queryItems(db *sql.DB, stmt string, args ...interface{}) ([]ItemModel, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ItemModel{}
+	for rows.Next() {
+		item := ItemModel{}
+		err := rows.Scan(&item.ItemId, &item.CampaignId, &item.GrantType, &item.SubjectId, &item.SubjectName,
+			&item.ObjectId, &item.ObjectName, &item.ValidFrom, &item.ValidUntil, &item.ReviewerId,
+			&item.Decision, &item.Comment, &item.DecidedAt, &item.RevokedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+//For opening a campaign over the grants as they are right now
+func AddCampaign(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	campaign := CampaignModel{}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&campaign); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	stmt := `INSERT INTO review_campaigns(campaign_name, description, due_at, created_by, tenant_id)
+		VALUES ($1, $2, $3, $4, $5) RETURNING campaign_id`
+	err = tx.QueryRow(stmt, campaign.CampaignName, campaign.Description, campaign.DueAt,
+		principal.UserId, principal.TenantId).Scan(&campaign.CampaignId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		//Check for duplicate data
+		if err.Code == "42701" || err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Campaign already exists",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			//For all other errors
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	result, err := tx.Exec(snapshotGrants, campaign.CampaignId, principal.UserId, principal.TenantId)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	count, _ := result.RowsAffected()
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Campaign " + strconv.FormatUint(campaign.CampaignId, 10) + " opened with " + strconv.FormatInt(count, 10) + " items",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all campaigns, newest first
+func GetCampaigns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	data := []CampaignModel{}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	rows, err := db.Query(selectCampaign+` WHERE c.tenant_id = $1 ORDER BY c.campaign_id DESC`, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		campaign := CampaignModel{}
+		if err := scanCampaign(rows, &campaign); err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Unable to scan campaign result set" + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, campaign)
+	}
+	//If everything went well, return array of campaign objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the items of a campaign
+func GetCampaignItems(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract campaign id from req params
+	params := mux.Vars(r)
+	campaignId, err := strconv.Atoi(params["campaign_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	pending := r.URL.Query().Get("pending") == "true"
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectItem + ` WHERE campaign_id = $1 AND (NOT $2 OR decision IS NULL) AND tenant_id = $3 ORDER BY item_id`
+	data, err := queryItems(db, stmt, uint64(campaignId), pending, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of item objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the undecided items the caller has to review
+func GetMyItems(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectItem + ` WHERE reviewer_id = $1 AND decision IS NULL
+		AND campaign_id IN (SELECT campaign_id FROM review_campaigns WHERE status = 'open')
+		ORDER BY item_id`
+	data, err := queryItems(db, stmt, principal.UserId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of item objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For recording the reviewer's keep or revoke decision. Decisions can be changed until
+//the campaign closes. Nobody certifies their own role
+func DecideItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract item id from req params
+	params := mux.Vars(r)
+	itemId, err := strconv.Atoi(params["item_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	decision := DecisionModel{}
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if decision.Decision != "keep" && decision.Decision != "revoke" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Decision must be keep or revoke",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	if principal.Impersonated() {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Review items cannot be decided under impersonation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	item := ItemModel{}
+	var status string
+	//The campaign row is shared-locked so it cannot close while the decision is written
+	stmt := `SELECT i.campaign_id, i.grant_type, i.subject_id, COALESCE(i.reviewer_id, 0), c.status
+		FROM review_items i JOIN review_campaigns c ON c.campaign_id = i.campaign_id
+		WHERE i.item_id = $1 AND i.tenant_id = $2 FOR SHARE OF c`
+	err = tx.QueryRow(stmt, uint64(itemId), middleware.TenantId(r)).Scan(&item.CampaignId, &item.GrantType, &item.SubjectId, &item.ReviewerId, &status)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Review item not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if item.ReviewerId != principal.UserId || (item.GrantType == "user_role" && item.SubjectId == principal.UserId) {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You are not the reviewer of this item",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if status != "open" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Campaign is closed",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	stmt = `UPDATE review_items SET decision = $2, comment = NULLIF($3, ''), decided_at = NOW() WHERE item_id = $1`
+	_, err = tx.Exec(stmt, uint64(itemId), decision.Decision, decision.Comment)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Decision recorded",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For handing an undecided item of an open campaign to another reviewer
+func ReassignItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract item id from req params
+	params := mux.Vars(r)
+	itemId, err := strconv.Atoi(params["item_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	reviewer := ReviewerModel{}
+	if err := json.NewDecoder(r.Body).Decode(&reviewer); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `UPDATE review_items SET reviewer_id = $2 WHERE item_id = $1 AND decision IS NULL
+		AND campaign_id IN (SELECT campaign_id FROM review_campaigns WHERE status = 'open')
+		AND tenant_id = $3 AND $2 IN (SELECT user_id FROM users WHERE tenant_id = $3)`
+	result, err := db.Exec(stmt, uint64(itemId), reviewer.ReviewerId, middleware.TenantId(r))
+	//Check for errors
+	if err, ok := err.(*pq.Error); ok {
+		//Reviewer must be an existing user
+		if err.Code == "23503" {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Reviewer not found",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//Check if any row was affected in the update operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to count rows affected in update operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "No undecided item with this id in an open campaign",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Item reassigned",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,41 @@
+package review
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleReviewRoutes(r *mux.Router) {
+	//Endpoint for opening a campaign. Snapshots every grant into review items
+	r.HandleFunc("/reviews",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageReviews, AddCampaign))).Methods("POST")
+
+	//Endpoint for fetching all campaigns
+	r.HandleFunc("/reviews",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageReviews, GetCampaigns))).Methods("GET")
+
+	//Endpoint for fetching the undecided items assigned to the caller in open campaigns
+	r.HandleFunc("/reviews/items/mine",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReviewAccess, GetMyItems))).Methods("GET")
+
+	//Endpoint for deciding an item, keep or revoke
+	r.HandleFunc("/reviews/items/{item_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReviewAccess, DecideItem))).Methods("PUT")
+
+	//Endpoint for handing an item to another reviewer
+	r.HandleFunc("/reviews/items/{item_id}/reviewer",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageReviews, ReassignItem))).Methods("PUT")
+
+	//Endpoint for fetching the items of a campaign. ?pending=true keeps the undecided ones
+	r.HandleFunc("/reviews/{campaign_id}/items",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageReviews, GetCampaignItems))).Methods("GET")
+
+	//Endpoint for closing a campaign. Revokes the grants decided as revoke
+	r.HandleFunc("/reviews/{campaign_id}/close",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageReviews, CloseCampaign))).Methods("POST")
+
+	//Endpoint for exporting the signed completion report of a closed campaign
+	r.HandleFunc("/reviews/{campaign_id}/report",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageReviews, GetCampaignReport))).Methods("GET")
+}
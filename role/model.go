@@ -1,9 +1,34 @@
 package role
 
+import "time"
 
 type RoleModel struct{
 	RoleId uint64 `json:"id"`
 	RoleName string `json:"role_name"`
 	Description string `json:"description"`
-	
-}
\ No newline at end of file
+	//Only read when the role is being granted
+	Validity
+}
+
+//Optional window in which a grant is in force. A nil bound is open ended
+type Validity struct {
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+}
+
+//Reports whether the window is non-empty
+func (v Validity) IsValid() bool {
+	return v.ValidFrom == nil || v.ValidUntil == nil || v.ValidUntil.After(*v.ValidFrom)
+}
+
+//Body of a request granting a privilege to a role
+type PrivilegeGrant struct {
+	PrivilegeName string `json:"privilege_name"`
+	Validity
+}
+
+//Who decides access requests for a role. Approvers lists owner, group and/or manager
+type ApprovalModel struct {
+	OwnerId   *uint64  `json:"owner_id"`
+	Approvers []string `json:"approvers"`
+}
@@ -0,0 +1,14 @@
+package role
+
+import "hrm/catalog"
+
+//Privileges required by the role routes
+var (
+	PrivCreateRole   = catalog.Declare("create_role", "Create a role")
+	PrivReadAllRoles = catalog.Declare("read_all_roles", "List all roles")
+	PrivReadOneRole  = catalog.Declare("read_one_role", "Read a role, its children and its privileges")
+	PrivModifyRole   = catalog.Declare("modify_role", "Edit a role and its place in the role hierarchy")
+	PrivDeleteRole   = catalog.Declare("delete_role", "Delete a role")
+	PrivGrantPriv    = catalog.Declare("grant_priv", "Grant a privilege to a role")
+	PrivRevokePriv   = catalog.Declare("revoke_priv", "Revoke a privilege from a role")
+)
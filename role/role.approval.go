@@ -0,0 +1,110 @@
+package role
+
+import (
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//For setting the owner of a role and who may approve requests for it: the role owner,
+//the owner of the requester's group and/or the requester's manager
+func SetRoleApprovers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract role id from req params
+	params := mux.Vars(r)
+	roleId, err := strconv.Atoi(params["role_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	approval := ApprovalModel{}
+	if err := json.NewDecoder(r.Body).Decode(&approval); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if len(approval.Approvers) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "At least one approver is required",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	for _, approver := range approval.Approvers {
+		if approver != "owner" && approver != "group" && approver != "manager" {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Approvers must be owner, group or manager",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `UPDATE roles SET owner_id = $2, approvers = $3 WHERE role_id = $1 AND tenant_id = $4`
+	result, err := db.Exec(stmt, uint64(roleId), approval.OwnerId, pq.Array(approval.Approvers), middleware.TenantId(r))
+	//Check for errors
+	if err, ok := err.(*pq.Error); ok {
+		//Owner must be an existing user
+		if err.Code == "23503" {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Owner not found",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//Check if any row was affected in the update operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to count rows affected in update operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Role not found!",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Role approvers updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -28,8 +28,8 @@ func AddNewRole(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `INSERT INTO roles(role_name, description) VALUES($1, $2)`
-	_, err := db.Exec(stmt, role.RoleName, role.Description)
+	stmt := `INSERT INTO roles(role_name, description, tenant_id) VALUES($1, $2, $3)`
+	_, err := db.Exec(stmt, role.RoleName, role.Description, middleware.TenantId(r))
 	//Checking for errors
 	if err, ok := err.(*pq.Error); ok {
 		//Check for duplicate data
@@ -77,8 +77,8 @@ func DeleteRole(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `DELETE FROM roles WHERE id = $1`
-	result, err := db.Exec(stmt, roleId)
+	stmt := `DELETE FROM roles WHERE role_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, roleId, middleware.TenantId(r))
 	//Check for errors
 	if err, ok := err.(*pq.Error); ok {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -122,7 +122,7 @@ func GetRole(w http.ResponseWriter, r *http.Request) {
 	role := RoleModel{}
 	//Get role id from req params
 	params := mux.Vars(r)
-	roleId, err := strconv.Atoi(params["id"])
+	roleId, err := strconv.Atoi(params["role_id"])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		res := middleware.Response{
@@ -135,8 +135,8 @@ func GetRole(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT FROM roles WHERE role_id = $1`
-	row := db.QueryRow(stmt, roleId)
+	stmt := `SELECT role_id, role_name, COALESCE(description, '') FROM roles WHERE role_id = $1 AND tenant_id = $2`
+	row := db.QueryRow(stmt, roleId, middleware.TenantId(r))
 	err = row.Scan(&role.RoleId, &role.RoleName, &role.Description)
 	//Checking for no data found error and other errors
 	if err == sql.ErrNoRows {
@@ -166,8 +166,8 @@ func GetRoles(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT * FROM roles`
-	rows, err := db.Query(stmt)
+	stmt := `SELECT role_id, role_name, COALESCE(description, '') FROM roles WHERE tenant_id = $1`
+	rows, err := db.Query(stmt, middleware.TenantId(r))
 	//Check for errors
 	if err, ok := err.(*pq.Error); ok {
 		w.WriteHeader(http.StatusNotFound)
@@ -233,8 +233,8 @@ func EditRole(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `UPDATE roles SET role_name = $2, description = $3 WHERE id = $1`
-	result, err := db.Exec(stmt, uint64(roleId), role.RoleName, role.Description)
+	stmt := `UPDATE roles SET role_name = $2, description = $3 WHERE role_id = $1 AND tenant_id = $4`
+	result, err := db.Exec(stmt, uint64(roleId), role.RoleName, role.Description, middleware.TenantId(r))
 	//Check for  errors
 	if err, ok := err.(*pq.Error); ok {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -0,0 +1,359 @@
+package role
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//For making a role the child of another. The parent inherits all the child's privileges
+func AddChildRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get parent role id from req params
+	params := mux.Vars(r)
+	parentId, err := strconv.Atoi(params["role_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Use role name of the child to get its role id
+	child := RoleModel{}
+	if err := json.NewDecoder(r.Body).Decode(&child); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT role_id FROM roles WHERE role_name = $1 AND tenant_id = $2`
+	err = db.QueryRow(stmt, child.RoleName, middleware.TenantId(r)).Scan(&child.RoleId)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Role not found!",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Adding the edge creates a cycle if the parent is already one of the child's descendants
+	descendants, err := middleware.EffectiveRoles(db, child.RoleId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	for _, id := range descendants {
+		if id == uint64(parentId) {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Role hierarchy cannot contain a cycle",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	//The parent inherits the child's privileges, platform ones only by a cross-tenant admin
+	inherited, err := middleware.RolePrivileges(db, child.RoleId)
+	var name string
+	if err == nil {
+		principal, _ := middleware.PrincipalFromContext(r.Context())
+		name, err = middleware.UngrantablePrivilege(db, principal.UserId, inherited)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if name != "" {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Only holders of " + middleware.PrivCrossTenant + " may grant " + name,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Everyone holding the parent gains the child's roles, so check them before committing
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//The parent must belong to the tenant, the child was looked up in it
+	stmt = `INSERT INTO role_hierarchy(parent_role_id, child_role_id, tenant_id)
+		SELECT role_id, $2, tenant_id FROM roles WHERE role_id = $1 AND tenant_id = $3`
+	result, err := tx.Exec(stmt, uint64(parentId), child.RoleId, middleware.TenantId(r))
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		//Check for duplicate data
+		if err.Code == "42701" || err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Role is already a child of this role",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			//For all other errors
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Parent role not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	holders, err := middleware.UsersHoldingRole(tx, uint64(parentId))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	msg, err := middleware.CheckStaticSoD(tx, holders)
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Child role added successfully",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For removing a child role from its parent
+func RemoveChildRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract parent and child role ids from req params
+	params := mux.Vars(r)
+	parentId, err := strconv.Atoi(params["role_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	childId, err := strconv.Atoi(params["child_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM role_hierarchy WHERE parent_role_id = $1 AND child_role_id = $2 AND tenant_id = $3`
+	result, err := db.Exec(stmt, uint64(parentId), uint64(childId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Check if the delete operation was successful
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error returning rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Role is not a child of this role",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Child role removed successfully",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching the direct children of a role
+func GetChildRoles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	data := []RoleModel{}
+	//Get role id from req params
+	params := mux.Vars(r)
+	roleId, err := strconv.Atoi(params["role_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT r.role_id, r.role_name, COALESCE(r.description, '') FROM roles r
+		JOIN role_hierarchy rh ON rh.child_role_id = r.role_id
+		WHERE rh.parent_role_id = $1 AND r.tenant_id = $2`
+	rows, err := db.Query(stmt, uint64(roleId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		role := RoleModel{}
+		if err := rows.Scan(&role.RoleId, &role.RoleName, &role.Description); err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Error scanning result set",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, role)
+	}
+	//If everything went fine, return array of role objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching every privilege a role holds, directly or through the roles it inherits from
+func GetEffectivePrivileges(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get role id from req params
+	params := mux.Vars(r)
+	roleId, err := strconv.Atoi(params["role_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	found, err := middleware.InTenant(db, "roles", uint64(roleId), middleware.TenantId(r))
+	if err == nil && !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Role not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var privileges []string
+	if err == nil {
+		privileges, err = middleware.RolePrivileges(db, uint64(roleId))
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went fine, return the privilege names
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(privileges)
+}
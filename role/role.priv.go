@@ -14,11 +14,10 @@ import (
 
 //For assigning privilege to a role
 func AddPrivRole(w http.ResponseWriter, r *http.Request){
-	privilegeName := RoleModel{}
-	// roleName := RoleModel{}
+	privilegeName := PrivilegeGrant{}
 	//Get role id from req params
 	params := mux.Vars(r)
-	roleId, err := strconv.Atoi(params["id"])
+	roleId, err := strconv.Atoi(params["role_id"])
 	if err != nil{
 		w.WriteHeader(http.StatusBadRequest)
 		res := middleware.Response{
@@ -35,24 +34,59 @@ func AddPrivRole(w http.ResponseWriter, r *http.Request){
 			Message: "Unable to parse json",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if !privilegeName.IsValid() {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error: true,
+			Message: "valid_until must be after valid_from",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
 	var privId uint64
-	stmt := `SELECT privilege_id FROM privileges WHERE privilege_name = $1`
-	row := db.QueryRow(stmt, privilegeName)
+	stmt := `SELECT privilege_id FROM privileges WHERE privilege_name = $1 AND (tenant_id IS NULL OR tenant_id = $2)`
+	row := db.QueryRow(stmt, privilegeName.PrivilegeName, middleware.TenantId(r))
 	err = row.Scan(&privId)
 	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
 		res := middleware.Response{
 			Error: true,
 			Message: "Privilege not found",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Tenant admins cannot hand out privileges over the whole platform
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	name, err := middleware.UngrantablePrivilege(db, principal.UserId, []string{privilegeName.PrivilegeName})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error: true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if name != "" {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error: true,
+			Message: "Only holders of " + middleware.PrivCrossTenant + " may grant " + name,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
-	//Add role id and privilege id to role_priv
-	stmts := `INSERT INTO role_privileges(privilege_id, role_id) VALUES ($1, $2)`
-	_, err = db.Exec(stmts, uint64(privId), roleId)
+	//Add role id and privilege id to role_priv, optionally limited to a window.
+	//Nothing is added if the role belongs to another tenant
+	stmts := `INSERT INTO role_privileges(privilege_id, role_id, valid_from, valid_until, tenant_id)
+		SELECT $1, role_id, $3, $4, tenant_id FROM roles WHERE role_id = $2 AND tenant_id = $5`
+	result, err := db.Exec(stmts, uint64(privId), roleId, privilegeName.ValidFrom, privilegeName.ValidUntil, middleware.TenantId(r))
 	//Checking for errors
 	if err, ok := err.(*pq.Error); ok {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -61,6 +95,16 @@ func AddPrivRole(w http.ResponseWriter, r *http.Request){
 			Message: err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error: true,
+			Message: "Role not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//If everything is fine then return response
 	w.WriteHeader(http.StatusCreated)
@@ -96,8 +140,8 @@ func RevokePrivRole(w http.ResponseWriter, r *http.Request){
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `DELETE FROM role_privileges WHERE privilege_id = $1 AND role_id = $2`
-	result, err := db.Exec(stmt, uint64(privilegeId), uint64(roleId))
+	stmt := `DELETE FROM role_privileges WHERE privilege_id = $1 AND role_id = $2 AND tenant_id = $3`
+	result, err := db.Exec(stmt, uint64(privilegeId), uint64(roleId), middleware.TenantId(r))
 	if err, ok := err.(*pq.Error); ok {
 		res := middleware.Response{
 			Error: true,
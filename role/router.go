@@ -10,21 +10,49 @@ import (
 func HandleRoleRoutes(r *mux.Router) {
 //Endpoint for creating a new role
 	r.HandleFunc("/newrole",
-	 middleware.JwtVerify(middleware.IsAuthorize("create_role", AddNewRole))).Methods("POST")
+	 middleware.JwtVerify(middleware.IsAuthorize(PrivCreateRole, AddNewRole))).Methods("POST")
 
-//Endpoint for registering new user
+//Endpoint for fetching all roles
 	r.HandleFunc("/roles", 
-	middleware.JwtVerify(middleware.IsAuthorize("read_all_roles", GetRoles))).Methods("GET")
+	middleware.JwtVerify(middleware.IsAuthorize(PrivReadAllRoles, GetRoles))).Methods("GET")
 
-//Endpoint for fetching all users
-r.HandleFunc("/roles/:role_id",
- middleware.JwtVerify(middleware.IsAuthorize("read_one_role", GetRole))).Methods("GET")
+//Endpoint for fetching a single role by id
+r.HandleFunc("/roles/{role_id}",
+ middleware.JwtVerify(middleware.IsAuthorize(PrivReadOneRole, GetRole))).Methods("GET")
 
-//Endpoint for fetching a single user by id
-r.HandleFunc("/roles/:role_id", 
-middleware.JwtVerify(middleware.IsAuthorize("delete_role", DeleteRole))).Methods("DELETE")
+//Endpoint for deleting a single role by id
+r.HandleFunc("/roles/{role_id}", 
+middleware.JwtVerify(middleware.IsAuthorize(PrivDeleteRole, DeleteRole))).Methods("DELETE")
 
-//Endpoint for editing a single user by id
-r.HandleFunc("/roles/:role_id", 
-middleware.JwtVerify(middleware.IsAuthorize("modify_role", EditRole))).Methods("PUT")
+//Endpoint for editing a single role by id
+r.HandleFunc("/roles/{role_id}", 
+middleware.JwtVerify(middleware.IsAuthorize(PrivModifyRole, EditRole))).Methods("PUT")
+
+//Endpoint for fetching the direct children of a role
+r.HandleFunc("/roles/{role_id}/children",
+middleware.JwtVerify(middleware.IsAuthorize(PrivReadOneRole, GetChildRoles))).Methods("GET")
+
+//Endpoint for making a role the child of another
+r.HandleFunc("/roles/{role_id}/children",
+middleware.JwtVerify(middleware.IsAuthorize(PrivModifyRole, AddChildRole))).Methods("POST")
+
+//Endpoint for removing a child role from its parent
+r.HandleFunc("/roles/{role_id}/children/{child_id}",
+middleware.JwtVerify(middleware.IsAuthorize(PrivModifyRole, RemoveChildRole))).Methods("DELETE")
+
+//Endpoint for fetching all privileges of a role including inherited ones
+r.HandleFunc("/roles/{role_id}/privileges",
+middleware.JwtVerify(middleware.IsAuthorize(PrivReadOneRole, GetEffectivePrivileges))).Methods("GET")
+
+//Endpoint for granting a privilege to a role
+r.HandleFunc("/roles/{role_id}/privileges",
+middleware.JwtVerify(middleware.IsAuthorize(PrivGrantPriv, AddPrivRole))).Methods("POST")
+
+//Endpoint for revoking a privilege from a role
+r.HandleFunc("/roles/{role_id}/privileges/{privilege_id}",
+middleware.JwtVerify(middleware.IsAuthorize(PrivRevokePriv, RevokePrivRole))).Methods("DELETE")
+
+//For setting the owner of a role and who approves access requests for it
+r.HandleFunc("/roles/{role_id}/approvers",
+middleware.JwtVerify(middleware.IsAuthorize(PrivModifyRole, SetRoleApprovers))).Methods("PUT")
 }
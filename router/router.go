@@ -1,8 +1,26 @@
 package router
 
 import (
+	"hrm/access"
+	"hrm/authz"
+	"hrm/calendar"
+	"hrm/department"
+	"hrm/document"
+	"hrm/elevation"
+	"hrm/employee"
+	"hrm/grant"
+	"hrm/group"
+	"hrm/leave"
+	"hrm/performance"
+	"hrm/policy"
+	"hrm/privilege"
 	"hrm/user"
+	"hrm/review"
 	"hrm/role"
+	"hrm/sod"
+	"hrm/tenant"
+	"hrm/timesheet"
+	"hrm/workflow"
 	"github.com/gorilla/mux"
 )
 
@@ -10,5 +28,23 @@ func Router() *mux.Router {
 	r := mux.NewRouter().StrictSlash(true)
 	user.HandleUserRoutes(r)
 	role.HandleRoleRoutes(r)
+	privilege.HandlePrivilegeRoutes(r)
+	group.HandleGroupRoutes(r)
+	policy.HandlePolicyRoutes(r)
+	grant.HandleGrantRoutes(r)
+	authz.HandleAuthzRoutes(r)
+	sod.HandleSoDRoutes(r)
+	access.HandleAccessRoutes(r)
+	review.HandleReviewRoutes(r)
+	elevation.HandleElevationRoutes(r)
+	tenant.HandleTenantRoutes(r)
+	employee.HandleEmployeeRoutes(r)
+	department.HandleDepartmentRoutes(r)
+	leave.HandleLeaveRoutes(r)
+	calendar.HandleCalendarRoutes(r)
+	timesheet.HandleTimesheetRoutes(r)
+	workflow.HandleWorkflowRoutes(r)
+	document.HandleDocumentRoutes(r)
+	performance.HandlePerformanceRoutes(r)
 	return r
 }
@@ -0,0 +1,11 @@
+package sod
+
+type ConstraintModel struct {
+	ConstraintId   uint64 `json:"id"`
+	ConstraintName string `json:"constraint_name"`
+	Description    string `json:"description"`
+	//static or dynamic
+	Kind      string   `json:"kind"`
+	MaxRoles  int      `json:"max_roles"`
+	RoleNames []string `json:"role_names"`
+}
@@ -0,0 +1,10 @@
+package sod
+
+import "hrm/catalog"
+
+//Privileges required by the separation-of-duties routes
+var (
+	PrivCreateSoD = catalog.Declare("create_sod_constraint", "Create separation-of-duties constraints")
+	PrivReadSoD   = catalog.Declare("read_sod_constraints", "List separation-of-duties constraints and violations")
+	PrivDeleteSoD = catalog.Declare("delete_sod_constraint", "Delete separation-of-duties constraints")
+)
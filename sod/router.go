@@ -0,0 +1,25 @@
+package sod
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleSoDRoutes(r *mux.Router) {
+	//Endpoint for adding a separation-of-duties constraint
+	r.HandleFunc("/sod/constraints",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivCreateSoD, AddConstraint))).Methods("POST")
+
+	//Endpoint for fetching all constraints
+	r.HandleFunc("/sod/constraints",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadSoD, GetConstraints))).Methods("GET")
+
+	//Endpoint for deleting a constraint
+	r.HandleFunc("/sod/constraints/{constraint_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDeleteSoD, DeleteConstraint))).Methods("DELETE")
+
+	//Endpoint for reporting the users currently breaking a constraint
+	r.HandleFunc("/sod/violations",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadSoD, GetViolations))).Methods("GET")
+}
@@ -0,0 +1,252 @@
+package sod
+
+import (
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//For adding a constraint over a set of roles, given by name. Existing violations are not
+//rejected here, they are listed by GetViolations
+func AddConstraint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	constraint := ConstraintModel{MaxRoles: 1}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&constraint); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if constraint.Kind != "static" && constraint.Kind != "dynamic" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Kind must be static or dynamic",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if constraint.MaxRoles < 1 || len(constraint.RoleNames) <= constraint.MaxRoles {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Constraint must name more roles than max_roles, and max_roles must be at least 1",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	tenantId := middleware.TenantId(r)
+	stmt := `INSERT INTO sod_constraints(constraint_name, description, kind, max_roles, tenant_id)
+		VALUES ($1, $2, $3, $4, $5) RETURNING constraint_id`
+	err = tx.QueryRow(stmt, constraint.ConstraintName, constraint.Description, constraint.Kind,
+		constraint.MaxRoles, tenantId).Scan(&constraint.ConstraintId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		//Check for duplicate data
+		if err.Code == "42701" || err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Constraint already exists",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			//For all other errors
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	stmt = `INSERT INTO sod_constraint_roles(constraint_id, role_id, tenant_id)
+		SELECT $1, role_id, tenant_id FROM roles WHERE role_name = ANY($2) AND tenant_id = $3`
+	result, err := tx.Exec(stmt, constraint.ConstraintId, pq.Array(constraint.RoleNames), tenantId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Every named role must exist
+	if count, err := result.RowsAffected(); err != nil || int(count) != len(constraint.RoleNames) {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Role not found!",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Constraint added",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all constraints with the names of their roles
+func GetConstraints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	data := []ConstraintModel{}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT c.constraint_id, c.constraint_name, COALESCE(c.description, ''), c.kind, c.max_roles,
+			array_agg(r.role_name ORDER BY r.role_name)
+		FROM sod_constraints c
+		JOIN sod_constraint_roles cr ON cr.constraint_id = c.constraint_id
+		JOIN roles r ON r.role_id = cr.role_id
+		WHERE c.tenant_id = $1
+		GROUP BY c.constraint_id ORDER BY c.constraint_id`
+	rows, err := db.Query(stmt, middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		constraint := ConstraintModel{}
+		err := rows.Scan(&constraint.ConstraintId, &constraint.ConstraintName, &constraint.Description,
+			&constraint.Kind, &constraint.MaxRoles, pq.Array(&constraint.RoleNames))
+		if err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Unable to scan constraint result set" + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, constraint)
+	}
+	//If everything went well, return array of constraint objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For deleting a constraint
+func DeleteConstraint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract constraint id from req params
+	params := mux.Vars(r)
+	constraintId, err := strconv.Atoi(params["constraint_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM sod_constraints WHERE constraint_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(constraintId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Check if any row was affected by the delete operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Constraint not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Constraint deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For reporting the users breaking a static or dynamic constraint, e.g. because
+//they held their roles before the constraint was added
+func GetViolations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	data := []middleware.Violation{}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	for _, kind := range []string{"static", "dynamic"} {
+		violations, err := middleware.TenantSoDViolations(db, middleware.TenantId(r), kind)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, violations...)
+	}
+	//If everything went well, return array of violations
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,15 @@
+package tenant
+
+import "time"
+
+type TenantModel struct {
+	TenantId    uint64    `json:"id"`
+	TenantName  string    `json:"tenant_name"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ActiveModel struct {
+	Active bool `json:"active"`
+}
@@ -0,0 +1,30 @@
+package tenant
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+//Tenants are managed across tenants, so every route needs cross_tenant_admin
+func HandleTenantRoutes(r *mux.Router) {
+	//Endpoint for adding a tenant
+	r.HandleFunc("/tenants",
+		middleware.JwtVerify(middleware.IsAuthorize(middleware.PrivCrossTenant, AddTenant))).Methods("POST")
+
+	//Endpoint for fetching all tenants
+	r.HandleFunc("/tenants",
+		middleware.JwtVerify(middleware.IsAuthorize(middleware.PrivCrossTenant, GetTenants))).Methods("GET")
+
+	//Endpoint for fetching a single tenant
+	r.HandleFunc("/tenants/{tenant_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(middleware.PrivCrossTenant, GetTenant))).Methods("GET")
+
+	//Endpoint for renaming a tenant
+	r.HandleFunc("/tenants/{tenant_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(middleware.PrivCrossTenant, EditTenant))).Methods("PUT")
+
+	//Endpoint for switching a tenant on or off
+	r.HandleFunc("/tenants/{tenant_id}/active",
+		middleware.JwtVerify(middleware.IsAuthorize(middleware.PrivCrossTenant, SetTenantActive))).Methods("PUT")
+}
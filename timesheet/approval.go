@@ -0,0 +1,182 @@
+package timesheet
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/employee"
+	"hrm/middleware"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+//For fetching the submitted timesheets of everyone reporting to the caller, oldest first
+func GetTimesheetInbox(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := employee.ReportsCTE + ` ` + selectTimesheet + ` JOIN reports rp ON rp.user_id = t.user_id
+		WHERE t.status = 'submitted' AND t.tenant_id = $2 ORDER BY t.submitted_at`
+	data, err := queryTimesheets(db, stmt, principal.UserId, principal.TenantId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of timesheet objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For approving a submitted timesheet. The week is locked from then on
+func ApproveTimesheet(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "approved")
+}
+
+//For sending a submitted timesheet back to the employee
+func RejectTimesheet(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "rejected")
+}
+
+//For unlocking an approved timesheet. It goes back to draft and has to be submitted again
+func ReopenTimesheet(w http.ResponseWriter, r *http.Request) {
+	decide(w, r, "draft")
+}
+
+//Moves a timesheet to status. Approving and rejecting a submitted timesheet is for anyone
+//above the employee in the reporting lines, reopening an approved one for holders of reopen_timesheet
+func decide(w http.ResponseWriter, r *http.Request, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract timesheet id from req params
+	params := mux.Vars(r)
+	timesheetId, err := strconv.Atoi(params["timesheet_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	decision := DecisionModel{}
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//An impersonating admin must not decide in someone else's name
+	if principal.Impersonated() {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Timesheets cannot be decided under impersonation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Lock the timesheet so two managers cannot decide it at the same time
+	var userId uint64
+	var current string
+	stmt := `SELECT user_id, status FROM timesheets WHERE timesheet_id = $1 AND tenant_id = $2 FOR UPDATE`
+	err = tx.QueryRow(stmt, uint64(timesheetId), principal.TenantId).Scan(&userId, &current)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Timesheet not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	allowed := status == "draft"
+	if err == nil && !allowed {
+		stmt = employee.ReportsCTE + ` SELECT EXISTS(SELECT 1 FROM reports WHERE user_id = $2)`
+		err = tx.QueryRow(stmt, principal.UserId, userId).Scan(&allowed)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if !allowed {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You are not allowed to decide this timesheet",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	expected := "submitted"
+	if status == "draft" {
+		expected = "approved"
+	}
+	if current != expected {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Timesheet is " + current + ", not " + expected,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	stmt = `UPDATE timesheets SET status = $2, decided_by = $3, decision_note = NULLIF($4, ''), decided_at = NOW()
+		WHERE timesheet_id = $1`
+	_, err = tx.Exec(stmt, uint64(timesheetId), status, principal.UserId, decision.Note)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	msg := "Timesheet " + status
+	if status == "draft" {
+		msg = "Timesheet reopened"
+	}
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: msg,
+	}
+	json.NewEncoder(w).Encode(res)
+}
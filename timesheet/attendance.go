@@ -0,0 +1,223 @@
+package timesheet
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//Whether the week of timestamp $2, or of now if it is null, is locked by an approved timesheet of employee $1
+const weekLocked = `SELECT EXISTS(SELECT 1 FROM timesheets WHERE user_id = $1 AND status = 'approved'
+	AND week_start = DATE_TRUNC('week', COALESCE($2::TIMESTAMP, NOW()))::DATE)`
+
+//Writes the error response and returns false if the optional body of a clock event cannot be parsed
+func parseClock(w http.ResponseWriter, r *http.Request) (ClockModel, bool) {
+	clock := ClockModel{}
+	if err := json.NewDecoder(r.Body).Decode(&clock); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return clock, false
+	}
+	return clock, true
+}
+
+//For clocking the caller in. Takes an optional note
+func ClockIn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	clock, ok := parseClock(w, r)
+	if !ok {
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	var locked bool
+	err := db.QueryRow(weekLocked, principal.UserId, nil).Scan(&locked)
+	if locked {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "This week's timesheet is approved and locked",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var attendanceId uint64
+	if err == nil {
+		stmt := `INSERT INTO attendance(user_id, tenant_id, note)
+			SELECT user_id, tenant_id, NULLIF($2, '') FROM employees WHERE user_id = $1 AND tenant_id = $3
+			RETURNING attendance_id`
+		err = db.QueryRow(stmt, principal.UserId, clock.Note, principal.HomeTenantId).Scan(&attendanceId)
+	}
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You have no employee record",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You are already clocked in",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Clocked in, attendance id " + strconv.FormatUint(attendanceId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For clocking the caller out. Takes an optional note, which replaces the one given at clock-in
+func ClockOut(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	clock, ok := parseClock(w, r)
+	if !ok {
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	var clockIn time.Time
+	err := db.QueryRow(`SELECT clock_in FROM attendance WHERE user_id = $1 AND clock_out IS NULL`, principal.UserId).Scan(&clockIn)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You are not clocked in",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var locked bool
+	if err == nil {
+		err = db.QueryRow(weekLocked, principal.UserId, clockIn).Scan(&locked)
+	}
+	if locked {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "The timesheet of the week you clocked in is approved and locked",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		stmt := `UPDATE attendance SET clock_out = NOW(), note = COALESCE(NULLIF($2, ''), note)
+			WHERE user_id = $1 AND clock_out IS NULL`
+		_, err = db.Exec(stmt, principal.UserId, clock.Note)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Clocked out",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching the attendance records of an employee, the latest first. ?from= and ?to=
+//(YYYY-MM-DD, inclusive) limit them by the day of clock-in
+func GetAttendance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	for _, value := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", value); value != "" && err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "from and to must be formatted YYYY-MM-DD",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT attendance_id, user_id, clock_in, clock_out,
+			COALESCE(ROUND((EXTRACT(EPOCH FROM clock_out - clock_in) / 3600)::NUMERIC, 2), 0), COALESCE(note, '')
+		FROM attendance WHERE user_id = $1 AND tenant_id = $2
+		AND ($3 = '' OR clock_in >= $3::DATE) AND ($4 = '' OR clock_in < $4::DATE + 1)
+		ORDER BY clock_in DESC`
+	rows, err := db.Query(stmt, uint64(userId), middleware.TenantId(r), from, to)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []AttendanceModel{}
+	for rows.Next() {
+		a := AttendanceModel{}
+		if err := rows.Scan(&a.AttendanceId, &a.UserId, &a.ClockIn, &a.ClockOut, &a.Hours, &a.Note); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, a)
+	}
+	//If everything went well, return array of attendance objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,69 @@
+package timesheet
+
+import "time"
+
+//A clock-in, with its clock-out once the employee clocked out. hours is 0 while open
+type AttendanceModel struct {
+	AttendanceId uint64     `json:"id"`
+	UserId       uint64     `json:"user_id"`
+	ClockIn      time.Time  `json:"clock_in"`
+	ClockOut     *time.Time `json:"clock_out"`
+	Hours        float64    `json:"hours"`
+	Note         string     `json:"note"`
+}
+
+//Optional body of clock-in and clock-out
+type ClockModel struct {
+	Note string `json:"note"`
+}
+
+//A project, or a task of a project, time can be booked on. Inactive codes cannot be booked on
+type TimeCodeModel struct {
+	TimeCodeId  uint64    `json:"id"`
+	ProjectCode string    `json:"project_code"`
+	TaskCode    string    `json:"task_code"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+//Hours booked on a time code on one day. work_date is YYYY-MM-DD
+type EntryModel struct {
+	EntryId     uint64  `json:"id"`
+	WorkDate    string  `json:"work_date"`
+	TimeCodeId  uint64  `json:"time_code_id"`
+	ProjectCode string  `json:"project_code"`
+	TaskCode    string  `json:"task_code"`
+	Hours       float64 `json:"hours"`
+	Note        string  `json:"note"`
+}
+
+//The timesheet of an employee for the week starting on week_start, a Monday. clocked_hours
+//are the closed attendance records of the week, for comparison with worked_hours
+type TimesheetModel struct {
+	TimesheetId uint64 `json:"id"`
+	UserId      uint64 `json:"user_id"`
+	Username    string `json:"username"`
+	WeekStart   string `json:"week_start"`
+	//draft, submitted, approved or rejected
+	Status        string       `json:"status"`
+	WorkedHours   float64      `json:"worked_hours"`
+	ExpectedHours float64      `json:"expected_hours"`
+	OvertimeHours float64      `json:"overtime_hours"`
+	ClockedHours  float64      `json:"clocked_hours"`
+	SubmittedAt   *time.Time   `json:"submitted_at,omitempty"`
+	DecidedBy     *uint64      `json:"decided_by,omitempty"`
+	DecisionNote  string       `json:"decision_note,omitempty"`
+	DecidedAt     *time.Time   `json:"decided_at,omitempty"`
+	Entries       []EntryModel `json:"entries,omitempty"`
+}
+
+//Body of saving a timesheet. The entries replace those the timesheet had
+type EntriesModel struct {
+	Entries []EntryModel `json:"entries"`
+}
+
+//Optional body of approve, reject and reopen
+type DecisionModel struct {
+	Note string `json:"note"`
+}
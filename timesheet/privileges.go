@@ -0,0 +1,15 @@
+package timesheet
+
+import "hrm/catalog"
+
+//Privileges required by the attendance and timesheet routes
+var (
+	PrivRecordTime         = catalog.Declare("record_time", "Clock in and out and fill in the caller's own timesheets")
+	PrivManageTimeCodes    = catalog.Declare("manage_time_codes", "Create, edit and delete project and task codes")
+	PrivReadTimeCodes      = catalog.Declare("read_time_codes", "List project and task codes")
+	PrivReadAllTimesheets  = catalog.Declare("read_all_timesheets", "Read the attendance and timesheets of any employee")
+	PrivReadDeptTimesheets = catalog.Declare("read_department_timesheets", "Read the attendance and timesheets of employees in the caller's department or below it")
+	PrivReadOwnTimesheets  = catalog.Declare("read_own_timesheets", "Read the caller's own attendance and timesheets")
+	PrivDecideTimesheet    = catalog.Declare("decide_timesheet", "Approve or reject timesheets of employees reporting to the caller")
+	PrivReopenTimesheet    = catalog.Declare("reopen_timesheet", "Unlock an approved timesheet for changes")
+)
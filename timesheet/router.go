@@ -0,0 +1,79 @@
+package timesheet
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleTimesheetRoutes(r *mux.Router) {
+	//Endpoint for clocking in
+	r.HandleFunc("/attendance/clock-in",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRecordTime, ClockIn))).Methods("POST")
+
+	//Endpoint for clocking out
+	r.HandleFunc("/attendance/clock-out",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRecordTime, ClockOut))).Methods("POST")
+
+	//Endpoint for fetching the attendance records of an employee
+	r.HandleFunc("/employees/{user_id}/attendance",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivReadOwnTimesheets, Department: PrivReadDeptTimesheets, Any: PrivReadAllTimesheets,
+		}, GetAttendance))).Methods("GET")
+
+	//Endpoint for creating a project or task code
+	r.HandleFunc("/time-codes",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTimeCodes, AddTimeCode))).Methods("POST")
+
+	//Endpoint for fetching all project and task codes
+	r.HandleFunc("/time-codes",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadTimeCodes, GetTimeCodes))).Methods("GET")
+
+	//Endpoint for editing a project or task code
+	r.HandleFunc("/time-codes/{time_code_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTimeCodes, EditTimeCode))).Methods("PUT")
+
+	//Endpoint for deleting a project or task code
+	r.HandleFunc("/time-codes/{time_code_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTimeCodes, DeleteTimeCode))).Methods("DELETE")
+
+	//Endpoint for fetching the caller's own timesheets
+	r.HandleFunc("/timesheets/mine",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRecordTime, GetMyTimesheets))).Methods("GET")
+
+	//Endpoint for filling in the caller's timesheet of a week
+	r.HandleFunc("/timesheets/mine/{week_start}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRecordTime, SaveTimesheet))).Methods("PUT")
+
+	//Endpoint for submitting the caller's timesheet of a week
+	r.HandleFunc("/timesheets/mine/{week_start}/submit",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivRecordTime, SubmitTimesheet))).Methods("POST")
+
+	//Endpoint for fetching the submitted timesheets of employees reporting to the caller
+	r.HandleFunc("/timesheets/inbox",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDecideTimesheet, GetTimesheetInbox))).Methods("GET")
+
+	//Endpoint for approving a timesheet
+	r.HandleFunc("/timesheets/{timesheet_id}/approve",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDecideTimesheet, ApproveTimesheet))).Methods("POST")
+
+	//Endpoint for rejecting a timesheet
+	r.HandleFunc("/timesheets/{timesheet_id}/reject",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivDecideTimesheet, RejectTimesheet))).Methods("POST")
+
+	//Endpoint for unlocking an approved timesheet
+	r.HandleFunc("/timesheets/{timesheet_id}/reopen",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReopenTimesheet, ReopenTimesheet))).Methods("POST")
+
+	//Endpoint for fetching the timesheets of an employee
+	r.HandleFunc("/employees/{user_id}/timesheets",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivReadOwnTimesheets, Department: PrivReadDeptTimesheets, Any: PrivReadAllTimesheets,
+		}, GetTimesheets))).Methods("GET")
+
+	//Endpoint for fetching the timesheet of an employee for a week, with its entries
+	r.HandleFunc("/employees/{user_id}/timesheets/{week_start}",
+		middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+			Own: PrivReadOwnTimesheets, Department: PrivReadDeptTimesheets, Any: PrivReadAllTimesheets,
+		}, GetTimesheet))).Methods("GET")
+}
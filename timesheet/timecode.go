@@ -0,0 +1,253 @@
+package timesheet
+
+import (
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//For creating a time code. Takes project_code and the optional task_code, description and active
+func AddTimeCode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	timeCode := TimeCodeModel{Active: true}
+	//Parse req body to json
+	if err := json.NewDecoder(r.Body).Decode(&timeCode); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if timeCode.ProjectCode == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "project_code is required",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `INSERT INTO time_codes(tenant_id, project_code, task_code, description, active)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5) RETURNING time_code_id`
+	err := db.QueryRow(stmt, middleware.TenantId(r), timeCode.ProjectCode, timeCode.TaskCode, timeCode.Description,
+		timeCode.Active).Scan(&timeCode.TimeCodeId)
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Time code already exists",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Time code created with id " + strconv.FormatUint(timeCode.TimeCodeId, 10),
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching all time codes. ?active=true leaves out the inactive ones
+func GetTimeCodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	activeOnly := r.URL.Query().Get("active") == "true"
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT time_code_id, project_code, task_code, COALESCE(description, ''), active, created_at
+		FROM time_codes WHERE tenant_id = $1 AND (active OR NOT $2) ORDER BY project_code, task_code`
+	rows, err := db.Query(stmt, middleware.TenantId(r), activeOnly)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []TimeCodeModel{}
+	for rows.Next() {
+		c := TimeCodeModel{}
+		if err := rows.Scan(&c.TimeCodeId, &c.ProjectCode, &c.TaskCode, &c.Description, &c.Active, &c.CreatedAt); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, c)
+	}
+	//If everything went well, return array of time code objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For editing a time code. Every field is replaced, set active to false to retire a code
+func EditTimeCode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get time code id from req params
+	params := mux.Vars(r)
+	timeCodeId, err := strconv.Atoi(params["time_code_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	timeCode := TimeCodeModel{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&timeCode); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if timeCode.ProjectCode == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "project_code is required",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `UPDATE time_codes SET project_code = $2, task_code = $3, description = NULLIF($4, ''), active = $5
+		WHERE time_code_id = $1 AND tenant_id = $6`
+	result, err := db.Exec(stmt, uint64(timeCodeId), timeCode.ProjectCode, timeCode.TaskCode, timeCode.Description,
+		timeCode.Active, middleware.TenantId(r))
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23505" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Time code already exists",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Time code not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Time code updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For deleting a time code no hours were booked on
+func DeleteTimeCode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get time code id from req params
+	params := mux.Vars(r)
+	timeCodeId, err := strconv.Atoi(params["time_code_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM time_codes WHERE time_code_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(timeCodeId), middleware.TenantId(r))
+	//Checking for errors
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == "23503" {
+			w.WriteHeader(http.StatusConflict)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Hours are booked on the time code, deactivate it instead",
+			}
+			json.NewEncoder(w).Encode(res)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+		}
+		return
+	}
+	//Check if any row was affected by the delete operation
+	if count, err := result.RowsAffected(); err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Error counting rows affected by delete operation",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	} else if count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Time code not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Time code deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,485 @@
+package timesheet
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/calendar"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const selectTimesheet = `SELECT t.timesheet_id, t.user_id, u.username, TO_CHAR(t.week_start, 'YYYY-MM-DD'), t.status,
+		t.worked_hours, t.expected_hours, t.overtime_hours,
+		COALESCE((SELECT ROUND((SUM(EXTRACT(EPOCH FROM a.clock_out - a.clock_in)) / 3600)::NUMERIC, 2) FROM attendance a
+			WHERE a.user_id = t.user_id AND a.clock_out IS NOT NULL
+			AND a.clock_in >= t.week_start AND a.clock_in < t.week_start + 7), 0),
+		t.submitted_at, t.decided_by, COALESCE(t.decision_note, ''), t.decided_at
+	FROM timesheets t JOIN users u ON u.user_id = t.user_id`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTimesheet(row scanner, t *TimesheetModel) error {
+	return row.Scan(&t.TimesheetId, &t.UserId, &t.Username, &t.WeekStart, &t.Status, &t.WorkedHours,
+		&t.ExpectedHours, &t.OvertimeHours, &t.ClockedHours, &t.SubmittedAt, &t.DecidedBy, &t.DecisionNote, &t.DecidedAt)
+}
+
+func queryTimesheets(db *sql.DB, stmt string, args ...interface{}) ([]TimesheetModel, error) {
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	timesheets := []TimesheetModel{}
+	for rows.Next() {
+		timesheet := TimesheetModel{}
+		if err := scanTimesheet(rows, &timesheet); err != nil {
+			return nil, err
+		}
+		timesheets = append(timesheets, timesheet)
+	}
+	return timesheets, rows.Err()
+}
+
+//Returns the entries of a timesheet by day
+func timesheetEntries(db *sql.DB, timesheetId uint64) ([]EntryModel, error) {
+	stmt := `SELECT e.entry_id, TO_CHAR(e.work_date, 'YYYY-MM-DD'), e.time_code_id, c.project_code, c.task_code,
+			e.hours, COALESCE(e.note, '')
+		FROM timesheet_entries e JOIN time_codes c ON c.time_code_id = e.time_code_id
+		WHERE e.timesheet_id = $1 ORDER BY e.work_date, c.project_code, c.task_code`
+	rows, err := db.Query(stmt, timesheetId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []EntryModel{}
+	for rows.Next() {
+		e := EntryModel{}
+		err := rows.Scan(&e.EntryId, &e.WorkDate, &e.TimeCodeId, &e.ProjectCode, &e.TaskCode, &e.Hours, &e.Note)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+//Returns the Monday a week_start req param stands for, or what is wrong with it
+func parseWeek(value string) (time.Time, string) {
+	weekStart, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return weekStart, "week_start must be formatted YYYY-MM-DD"
+	}
+	if weekStart.Weekday() != time.Monday {
+		return weekStart, "week_start must be a Monday"
+	}
+	return weekStart, ""
+}
+
+//The hours an employee is expected to work in a week: the working days of their calendar,
+//less the days of approved leave
+func expectedHours(q calendar.Queryer, userId uint64, weekStart time.Time) (float64, error) {
+	weekEnd := weekStart.AddDate(0, 0, 6)
+	week, err := calendar.WorkingDays(q, userId, weekStart, weekEnd)
+	if err != nil {
+		return 0, err
+	}
+	stmt := `SELECT GREATEST(start_date, $2::DATE), LEAST(end_date, $3::DATE) FROM leave_requests
+		WHERE user_id = $1 AND status = 'approved' AND start_date <= $3::DATE AND end_date >= $2::DATE`
+	rows, err := q.Query(stmt, userId, weekStart.Format("2006-01-02"), weekEnd.Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+	var leaves [][2]time.Time
+	for rows.Next() {
+		var leave [2]time.Time
+		if err := rows.Scan(&leave[0], &leave[1]); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		leaves = append(leaves, leave)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	hours := week.Hours
+	for _, leave := range leaves {
+		period, err := calendar.WorkingDays(q, userId, leave[0], leave[1])
+		if err != nil {
+			return 0, err
+		}
+		hours -= period.Hours
+	}
+	if hours < 0 {
+		hours = 0
+	}
+	return hours, nil
+}
+
+//Works out the worked, expected and overtime hours of a timesheet again
+func updateTotals(tx *sql.Tx, timesheetId, userId uint64, weekStart time.Time) error {
+	expected, err := expectedHours(tx, userId, weekStart)
+	if err != nil {
+		return err
+	}
+	stmt := `WITH worked AS (SELECT COALESCE(SUM(hours), 0) AS hours FROM timesheet_entries WHERE timesheet_id = $1)
+		UPDATE timesheets SET worked_hours = worked.hours, expected_hours = $2::NUMERIC,
+			overtime_hours = GREATEST(worked.hours - $2::NUMERIC, 0)
+		FROM worked WHERE timesheet_id = $1`
+	_, err = tx.Exec(stmt, timesheetId, expected)
+	return err
+}
+
+//Returns what is wrong with the entries of the week starting on weekStart, or an empty string
+func validateEntries(entries []EntryModel, weekStart time.Time) string {
+	perDay := map[string]float64{}
+	for _, entry := range entries {
+		day, err := time.Parse("2006-01-02", entry.WorkDate)
+		if err != nil {
+			return "work_date must be formatted YYYY-MM-DD"
+		}
+		if day.Before(weekStart) || day.After(weekStart.AddDate(0, 0, 6)) {
+			return "work_date " + entry.WorkDate + " is not in the week of the timesheet"
+		}
+		if entry.Hours <= 0 || entry.Hours > 24 {
+			return "hours must be more than 0 and at most 24"
+		}
+		if perDay[entry.WorkDate] += entry.Hours; perDay[entry.WorkDate] > 24 {
+			return "More than 24 hours booked on " + entry.WorkDate
+		}
+	}
+	return ""
+}
+
+//For filling in the caller's timesheet of a week. The entries replace the ones it had.
+//Submitted and approved timesheets cannot be changed, a rejected one goes back to draft
+func SaveTimesheet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	weekStart, msg := parseWeek(mux.Vars(r)["week_start"])
+	body := EntriesModel{}
+	if msg == "" {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			msg = "Unable to parse req body to json"
+		}
+	}
+	if msg == "" {
+		msg = validateEntries(body.Entries, weekStart)
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	week := weekStart.Format("2006-01-02")
+	stmt := `INSERT INTO timesheets(user_id, tenant_id, week_start)
+		SELECT user_id, tenant_id, $2::DATE FROM employees WHERE user_id = $1 AND tenant_id = $3
+		ON CONFLICT (user_id, week_start) DO NOTHING`
+	_, err = tx.Exec(stmt, principal.UserId, week, principal.HomeTenantId)
+	var timesheetId uint64
+	var status string
+	if err == nil {
+		stmt = `SELECT timesheet_id, status FROM timesheets WHERE user_id = $1 AND week_start = $2::DATE FOR UPDATE`
+		err = tx.QueryRow(stmt, principal.UserId, week).Scan(&timesheetId, &status)
+	}
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "You have no employee record",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && (status == "approved" || status == "submitted") {
+		msg = "Timesheet is approved and locked"
+		if status == "submitted" {
+			msg = "Timesheet is submitted and waits for a decision"
+		}
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		_, err = tx.Exec(`DELETE FROM timesheet_entries WHERE timesheet_id = $1`, timesheetId)
+	}
+	//Only active time codes of the caller's tenant can be booked on
+	stmt = `INSERT INTO timesheet_entries(timesheet_id, tenant_id, work_date, time_code_id, hours, note)
+		SELECT $1, tenant_id, $2::DATE, time_code_id, $4, NULLIF($5, '') FROM time_codes
+		WHERE time_code_id = $3 AND tenant_id = $6 AND active`
+	for _, entry := range body.Entries {
+		if err != nil {
+			break
+		}
+		var result sql.Result
+		result, err = tx.Exec(stmt, timesheetId, entry.WorkDate, entry.TimeCodeId, entry.Hours, entry.Note, principal.HomeTenantId)
+		if err != nil {
+			break
+		}
+		if count, err := result.RowsAffected(); err == nil && count == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Time code " + strconv.FormatUint(entry.TimeCodeId, 10) + " not found or inactive",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	if err == nil {
+		_, err = tx.Exec(`UPDATE timesheets SET status = 'draft' WHERE timesheet_id = $1`, timesheetId)
+	}
+	if err == nil {
+		err = updateTotals(tx, timesheetId, principal.UserId, weekStart)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Timesheet saved",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For submitting the caller's timesheet of a week for approval. Overtime is worked out
+//against the calendar and approved leave as they are at submission
+func SubmitTimesheet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	weekStart, msg := parseWeek(mux.Vars(r)["week_start"])
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	var timesheetId uint64
+	var status string
+	var started bool
+	stmt := `SELECT timesheet_id, status, week_start <= CURRENT_DATE FROM timesheets
+		WHERE user_id = $1 AND week_start = $2::DATE FOR UPDATE`
+	err = tx.QueryRow(stmt, principal.UserId, weekStart.Format("2006-01-02")).Scan(&timesheetId, &status, &started)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Timesheet not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && status != "draft" && status != "rejected" {
+		msg = "Timesheet is already " + status
+	} else if err == nil && !started {
+		msg = "A timesheet cannot be submitted before its week starts"
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		err = updateTotals(tx, timesheetId, principal.UserId, weekStart)
+	}
+	if err == nil {
+		stmt = `UPDATE timesheets SET status = 'submitted', submitted_at = NOW(), decided_by = NULL,
+			decision_note = NULL, decided_at = NULL WHERE timesheet_id = $1`
+		_, err = tx.Exec(stmt, timesheetId)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Timesheet submitted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching the caller's own timesheets, the latest week first
+func GetMyTimesheets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	data, err := queryTimesheets(db, selectTimesheet+` WHERE t.user_id = $1 ORDER BY t.week_start DESC`, principal.UserId)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of timesheet objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the timesheets of an employee, the latest week first. ?status= narrows them down
+func GetTimesheets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectTimesheet + ` WHERE t.user_id = $1 AND t.tenant_id = $2 AND ($3 = '' OR t.status = $3)
+		ORDER BY t.week_start DESC`
+	data, err := queryTimesheets(db, stmt, uint64(userId), middleware.TenantId(r), r.URL.Query().Get("status"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of timesheet objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching the timesheet of an employee for one week, with its entries
+func GetTimesheet(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get user id and week from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	weekStart, msg := parseWeek(params["week_start"])
+	if msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	timesheet := TimesheetModel{}
+	row := db.QueryRow(selectTimesheet+` WHERE t.user_id = $1 AND t.week_start = $2::DATE AND t.tenant_id = $3`,
+		uint64(userId), weekStart.Format("2006-01-02"), middleware.TenantId(r))
+	err = scanTimesheet(row, &timesheet)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Timesheet not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		timesheet.Entries, err = timesheetEntries(db, timesheet.TimesheetId)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return timesheet object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(timesheet)
+}
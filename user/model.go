@@ -1,5 +1,7 @@
 package user
 
+import "time"
+
 type UserModel struct{
 	UserId uint64 `json:"id"`
 	Firstname string `json:"first_name"`
@@ -12,4 +14,33 @@ type UserModel struct{
 	// DaysB4Expn uint64 `json:"days_b4_expn"`
 	RoleId uint64 `json:"role_id"`
 	RoleName string `json:"role_name"`
-}
\ No newline at end of file
+	//pending, active, on_leave, suspended or terminated
+	Status string `json:"status"`
+}
+//A request made, or token issued, while an admin acted as another user
+type ImpersonationAuditModel struct{
+	AuditId uint64 `json:"id"`
+	ActorId uint64 `json:"actor_id"`
+	ActorUsername string `json:"actor_username"`
+	SubjectId uint64 `json:"subject_id"`
+	SubjectUsername string `json:"subject_username"`
+	Event string `json:"event"`
+	Method string `json:"method"`
+	Path string `json:"path"`
+	Status int `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+//A lifecycle status change of a user. Changes dated in the future stay scheduled until then
+type StatusChangeModel struct{
+	ChangeId uint64 `json:"id"`
+	UserId uint64 `json:"user_id"`
+	FromStatus string `json:"from_status"`
+	Status string `json:"status"`
+	EffectiveDate string `json:"effective_date"`
+	Reason string `json:"reason"`
+	//scheduled, applied or cancelled
+	State string `json:"state"`
+	ChangedBy *uint64 `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
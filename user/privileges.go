@@ -0,0 +1,24 @@
+package user
+
+import "hrm/catalog"
+
+//Privileges required by the user routes
+var (
+	PrivCreateUser     = catalog.Declare("create_user", "Register a new user")
+	PrivReadAllUsers   = catalog.Declare("read_all_users", "List all users")
+	PrivReadOneUser    = catalog.Declare("read_one_user", "Read any user")
+	PrivReadGroupUsers = catalog.Declare("read_group_users", "Read users in the caller's group")
+	PrivReadDeptUsers  = catalog.Declare("read_department_users", "Read users in the caller's department or below it")
+	PrivReadOwnUser    = catalog.Declare("read_own_user", "Read the caller's own user")
+	PrivModifyAnyUser  = catalog.Declare("modify_any_user", "Edit any user or change their password")
+	PrivModifyOwnUser  = catalog.Declare("modify_own_user", "Edit the caller's own user or change their password")
+	PrivDeleteUser     = catalog.Declare("delete_user", "Delete a user")
+	PrivGrantUserRole  = catalog.Declare("grant_user_role", "Assign a role to a user")
+	PrivRevokeUserRole = catalog.Declare("revoke_user_role", "Remove the role of a user")
+
+	PrivImpersonate            = catalog.Declare("impersonate_user", "Act as a user holding no privilege the caller lacks")
+	PrivReadImpersonationAudit = catalog.Declare("read_impersonation_audit", "List tokens issued and requests made under impersonation")
+
+	PrivChangeUserStatus = catalog.Declare("change_user_status", "Move a user between lifecycle states, now or from a future date")
+	PrivReadUserStatus   = catalog.Declare("read_user_status", "List the lifecycle status changes of a user")
+)
@@ -11,29 +11,59 @@ func HandleUserRoutes(r *mux.Router) {
 
 	//Endpoint for registering new user
 	r.HandleFunc("/register",
-	 middleware.JwtVerify(middleware.IsAuthorize("create_user", RegisterUser))).Methods("POST")
+	 middleware.JwtVerify(middleware.IsAuthorize(PrivCreateUser, RegisterUser))).Methods("POST")
 	
 	 //Endpoint for fetching all users
 	r.HandleFunc("/users", 
-	middleware.JwtVerify(middleware.IsAuthorize("read_all_users", GetUsers))).Methods("GET")
+	middleware.JwtVerify(middleware.IsAuthorize(PrivReadAllUsers, GetUsers))).Methods("GET")
 
 	//Endpoint for fetching a single user by id
-	r.HandleFunc("/users/:user_id", 
-	middleware.JwtVerify(middleware.IsAuthorize("read_one_user", GetUser))).Methods("GET")
+	r.HandleFunc("/users/{user_id}",
+	middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+		Own: PrivReadOwnUser, Group: PrivReadGroupUsers, Department: PrivReadDeptUsers, Any: PrivReadOneUser,
+	}, GetUser))).Methods("GET")
 
 	//Endpoint for editing a single user by id
-	r.HandleFunc("/users/:user_id",
-	 middleware.JwtVerify(middleware.IsAuthorize("modify_user", EditUser))).Methods("PUT")
+	r.HandleFunc("/users/{user_id}",
+	 middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+		Own: PrivModifyOwnUser, Any: PrivModifyAnyUser,
+	}, EditUser))).Methods("PUT")
+
+	//Endpoint for changing the password of a user
+	r.HandleFunc("/users/{user_id}/password",
+	 middleware.JwtVerify(middleware.IsAuthorizeScoped(middleware.Scope{
+		Own: PrivModifyOwnUser, Any: PrivModifyAnyUser,
+	}, ChangePassword))).Methods("PUT")
 
 	 //Endpoint for deleting a user by id
-	r.HandleFunc("/users/:user_id",
-	middleware.JwtVerify(middleware.IsAuthorize("delete_user", EditUser))).Methods("DELETE")
+	r.HandleFunc("/users/{user_id}",
+	middleware.JwtVerify(middleware.IsAuthorize(PrivDeleteUser, DeleteUser))).Methods("DELETE")
 
 	//Endpoint for granting role to a user
-	r.HandleFunc("/users/:user_id", 
-	middleware.JwtVerify(middleware.IsAuthorize("grant_user_role", AssignRoleToUser))).Methods("PUT")
+	r.HandleFunc("/users/{user_id}/role", 
+	middleware.JwtVerify(middleware.IsAuthorize(PrivGrantUserRole, AssignRoleToUser))).Methods("PUT")
 	
 	//For revoking roles granted to a user
-	r.HandleFunc("/users/:user_id",
-middleware.JwtVerify(middleware.IsAuthorize("revoke_user_role", RemoveRoleFromUser))).Methods("PUT")
+	r.HandleFunc("/users/{user_id}/role",
+middleware.JwtVerify(middleware.IsAuthorize(PrivRevokeUserRole, RemoveRoleFromUser))).Methods("DELETE")
+
+	//For moving a user between lifecycle states, at once or from a future date
+	r.HandleFunc("/users/{user_id}/status",
+	middleware.JwtVerify(middleware.IsAuthorize(PrivChangeUserStatus, ChangeUserStatus))).Methods("POST")
+
+	//For listing the status changes of a user
+	r.HandleFunc("/users/{user_id}/status-changes",
+	middleware.JwtVerify(middleware.IsAuthorize(PrivReadUserStatus, GetStatusChanges))).Methods("GET")
+
+	//For cancelling a scheduled status change
+	r.HandleFunc("/users/{user_id}/status-changes/{change_id}",
+	middleware.JwtVerify(middleware.IsAuthorize(PrivChangeUserStatus, CancelStatusChange))).Methods("DELETE")
+
+	//For issuing a short-lived token to act as a user
+	r.HandleFunc("/users/{user_id}/impersonate",
+	middleware.JwtVerify(middleware.IsAuthorize(PrivImpersonate, ImpersonateUser))).Methods("POST")
+
+	//For listing the impersonation audit trail
+	r.HandleFunc("/impersonations",
+	middleware.JwtVerify(middleware.IsAuthorize(PrivReadImpersonationAudit, GetImpersonationAudit))).Methods("GET")
 }
@@ -28,13 +28,18 @@ func AuthenticateUser(w http.ResponseWriter, r *http.Request) {
 	//Call db connection :Get user password from the database
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `select password, username, role_id	from users 	WHERE username = $1`
+	//Users of a deactivated tenant cannot log in, nor can onboarded users without a password yet
+	stmt := `select user_id, COALESCE(password, ''), username, COALESCE(role_id::TEXT, ''), tenant_id::TEXT, status from users
+		WHERE username = $1 AND tenant_id IN (SELECT tenant_id FROM tenants WHERE active)`
 	row := db.QueryRow(stmt, user.Username)
 	//Create a variable pass to hold password returned from the database. It's the hashed version
 	var pass string
+	var userId string
 	var roleId string
 	var username string
-	err := row.Scan(&pass, &username, &roleId)
+	var tenantId string
+	var status string
+	err := row.Scan(&userId, &pass, &username, &roleId, &tenantId, &status)
 	if err == sql.ErrNoRows {
 		w.WriteHeader(http.StatusUnauthorized)
 		res := middleware.Response{
@@ -42,6 +47,7 @@ func AuthenticateUser(w http.ResponseWriter, r *http.Request) {
 			Message: "Invalid Username or Password!",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(pass), []byte(user.Password)); err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
@@ -50,9 +56,20 @@ func AuthenticateUser(w http.ResponseWriter, r *http.Request) {
 			Message: "Invalid Username or Password!",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Suspended and terminated users are told so only once they proved who they are
+	if middleware.StatusLocked(status) {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Account is " + status,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//If everything is correct get use user's role_id and generate token
-	token, err := middleware.GenerateJWT(username, roleId)
+	token, err := middleware.GenerateJWT(userId, username, roleId, tenantId)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		res := middleware.Response{
@@ -97,9 +114,10 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `INSERT INTO users(first_name, last_name, middle_name, username, password)
-	VALUES($1, $2, $3, $4, $5, $6, $7)`
-	_, err = db.Exec(stmt, user.Firstname, user.Lastname, user.Middlename, user.Username, user.Password)
+	//The user joins the tenant the request acts on
+	stmt := `INSERT INTO users(first_name, last_name, middle_name, username, password, tenant_id)
+	VALUES($1, $2, $3, $4, $5, $6)`
+	_, err = db.Exec(stmt, user.Firstname, user.Lastname, user.Middlename, user.Username, user.Password, middleware.TenantId(r))
 	//Checking for errors
 	if err, ok := err.(*pq.Error); ok {
 		//Checking for duplicate entry/unique violation
@@ -153,11 +171,23 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(res)
 	}
 	user.Password = string(hash)
+	//The target user comes from the route so ownership can be checked before this runs
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
 	//Call db connection]
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `UPDATE users SET password = $2 WHERE id = $1`
-	result, err := db.Exec(stmt, user.UserId, user.Password)
+	stmt := `UPDATE users SET password = $2 WHERE user_id = $1 AND tenant_id = $3`
+	result, err := db.Exec(stmt, uint64(userId), user.Password, middleware.TenantId(r))
 	//Check for errors
 	if err, ok := err.(*pq.Error); ok {
 		res := middleware.Response{
@@ -199,20 +229,22 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 	user := UserModel{}
 	//Extract user id from req params
 	params := mux.Vars(r)
-	userId, err := strconv.Atoi(params["id"])
+	userId, err := strconv.Atoi(params["user_id"])
 	if err != nil {
 		res := middleware.Response{
 			Error:   true,
 			Message: "Unable to convert req param to int",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Call db connction
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT * FROM users WHERE id = $1`
-	row := db.QueryRow(stmt, userId)
-	err = row.Scan(&user.UserId, &user.Firstname, &user.Middlename, &user.Lastname, &user.Username,)
+	stmt := `SELECT user_id, first_name, COALESCE(middle_name, ''), last_name, username, status
+		FROM users WHERE user_id = $1 AND tenant_id = $2`
+	row := db.QueryRow(stmt, userId, middleware.TenantId(r))
+	err = row.Scan(&user.UserId, &user.Firstname, &user.Middlename, &user.Lastname, &user.Username, &user.Status)
 	//Check if any row was returned or not
 	if err == sql.ErrNoRows {
 		w.WriteHeader(http.StatusNotFound)
@@ -242,8 +274,8 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT * FROM users`
-	rows, err := db.Query(stmt)
+	stmt := `SELECT first_name, last_name, COALESCE(middle_name, ''), username, user_id, status FROM users WHERE tenant_id = $1`
+	rows, err := db.Query(stmt, middleware.TenantId(r))
 	//Check for all errors
 	if err, ok := err.(*pq.Error); ok {
 		if err.Code == "P0002" || err.Code == "02000" {
@@ -267,7 +299,7 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 		users := UserModel{}
 		err := rows.Scan(
 			&users.Firstname, &users.Lastname, &users.Middlename, &users.Username,
-			&users.UserId,
+			&users.UserId, &users.Status,
 		)
 		if err != nil {
 			w.WriteHeader(http.StatusExpectationFailed)
@@ -287,7 +319,7 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	//Extract user id from req params
 	params := mux.Vars(r)
-	userId, err := strconv.Atoi(params["id"])
+	userId, err := strconv.Atoi(params["user_id"])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		res := middleware.Response{
@@ -299,8 +331,8 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 	//Call db connection
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `DELETE FROM users WHERE id = $1`
-	result, err := db.Exec(stmt, userId)
+	stmt := `DELETE FROM users WHERE user_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, userId, middleware.TenantId(r))
 	//Check if any row is affected by the delete operation
 	if count, err := result.RowsAffected(); err != nil {
 		w.WriteHeader(http.StatusExpectationFailed)
@@ -364,10 +396,10 @@ func EditUser(w http.ResponseWriter, r *http.Request) {
 	//call db connect
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `UPDATE user SET first_name = $2, last_name = $3, middle_name = $4 
+	stmt := `UPDATE users SET first_name = $2, last_name = $3, middle_name = $4 
 				WHERE
-				 user_id = $1`
-	result, err := db.Exec(stmt, uint64(userId), user.Firstname, user.Lastname, user.Middlename)
+				 user_id = $1 AND tenant_id = $5`
+	result, err := db.Exec(stmt, uint64(userId), user.Firstname, user.Lastname, user.Middlename, middleware.TenantId(r))
 	//Check for errors
 	if err, ok := err.(*pq.Error); ok {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -0,0 +1,204 @@
+package user
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+//Impersonation tokens are kept short, the admin asks for a new one when it runs out
+const impersonationTTL = 15 * time.Minute
+
+//For issuing a token to act as another user. The admin must hold every privilege the
+//user holds, so impersonation never gives access the admin does not already have.
+func ImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	actor, _ := middleware.PrincipalFromContext(r.Context())
+	//Tokens are not chained, an impersonated session cannot impersonate again
+	if actor.Impersonated() {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Cannot impersonate while impersonating",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Extract user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if uint64(userId) == actor.UserId {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Cannot impersonate yourself",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	//The token is issued for the tenant of the target, which is the one the request acts on
+	tenantId := middleware.TenantId(r)
+	target := middleware.Principal{UserId: uint64(userId), HomeTenantId: tenantId, TenantId: tenantId}
+	var roleId sql.NullInt64
+	var status string
+	stmt := `SELECT username, role_id, status FROM users WHERE user_id = $1 AND tenant_id = $2`
+	err = db.QueryRow(stmt, target.UserId, tenantId).Scan(&target.Username, &roleId, &status)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "User not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//The token would be rejected anyway
+	if middleware.StatusLocked(status) {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Cannot impersonate a " + status + " user",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	target.RoleId = uint64(roleId.Int64)
+	//The privileges the target gets must be a subset of the ones the admin gets
+	actorPrivileges, err := middleware.EffectivePrivileges(db, actor.UserId)
+	if err == nil {
+		held := map[string]bool{}
+		for _, privilege := range actorPrivileges {
+			held[privilege] = true
+		}
+		var targetPrivileges []string
+		targetPrivileges, err = middleware.EffectivePrivileges(db, target.UserId)
+		for _, privilege := range targetPrivileges {
+			if !held[privilege] {
+				w.WriteHeader(http.StatusForbidden)
+				res := middleware.Response{
+					Error:   true,
+					Message: "Cannot impersonate a user holding " + privilege + ", which you do not hold",
+				}
+				json.NewEncoder(w).Encode(res)
+				return
+			}
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	token, err := middleware.GenerateImpersonationJWT(target, actor, impersonationTTL)
+	if err == nil {
+		target.ActorId = actor.UserId
+		err = middleware.RecordImpersonation(db, target, "issued", r.Method, r.URL.Path, http.StatusOK)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: token,
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching the impersonation trail, newest first. ?actor_id= and ?subject_id= narrow it down
+func GetImpersonationAudit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	data := []ImpersonationAuditModel{}
+	filters := []int64{0, 0}
+	for i, key := range []string{"actor_id", "subject_id"} {
+		if value := r.URL.Query().Get(key); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				res := middleware.Response{
+					Error:   true,
+					Message: "Unable to convert " + key + " to int",
+				}
+				json.NewEncoder(w).Encode(res)
+				return
+			}
+			filters[i] = id
+		}
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT a.audit_id, a.actor_id, COALESCE(ua.username, ''), a.subject_id, COALESCE(us.username, ''),
+			a.event, a.method, a.path, a.status, a.created_at
+		FROM impersonation_audit a
+		LEFT JOIN users ua ON ua.user_id = a.actor_id
+		LEFT JOIN users us ON us.user_id = a.subject_id
+		WHERE ($1 = 0 OR a.actor_id = $1) AND ($2 = 0 OR a.subject_id = $2) AND a.tenant_id = $3
+		ORDER BY a.audit_id DESC`
+	rows, err := db.Query(stmt, filters[0], filters[1], middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		entry := ImpersonationAuditModel{}
+		err := rows.Scan(&entry.AuditId, &entry.ActorId, &entry.ActorUsername, &entry.SubjectId,
+			&entry.SubjectUsername, &entry.Event, &entry.Method, &entry.Path, &entry.Status, &entry.CreatedAt)
+		if err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			res := middleware.Response{
+				Error:   true,
+				Message: "Unable to scan audit result set" + err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, entry)
+	}
+	//If everything went well, return array of audit entries
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
@@ -3,6 +3,7 @@ package user
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"hrm/db"
 	"hrm/middleware"
 	"hrm/role"
@@ -13,6 +14,61 @@ import (
 	"github.com/lib/pq"
 )
 
+var ErrUserNotFound = errors.New("user not found")
+
+//Implemented by both *sql.DB and *sql.Tx
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+//Reports whether a group of userId, or a group containing one, has roleId. Users are only
+//given roles their groups have
+func GroupsHaveRole(q rowQueryer, userId, roleId uint64) (bool, error) {
+	stmt := `WITH RECURSIVE ` + middleware.UserGroupsCTE(`user_id = $1`) + `
+	SELECT EXISTS(SELECT 1 FROM group_roles WHERE role_id = $2 AND group_id IN (SELECT group_id FROM user_groups)
+	AND (valid_from IS NULL OR valid_from <= NOW()) AND (valid_until IS NULL OR valid_until > NOW()))`
+	var found bool
+	err := q.QueryRow(stmt, userId, roleId).Scan(&found)
+	return found, err
+}
+
+//Sets the role of a user, rejecting the grant if it would break a static
+//separation-of-duties constraint. Returns the reason of the rejection, if any, or a
+//middleware.UngrantableError if the role carries a privilege grantedBy may not hand out.
+func GrantRole(db *sql.DB, grantedBy, userId, roleId uint64, validity role.Validity) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	msg, err := GrantRoleTx(tx, grantedBy, userId, roleId, validity)
+	if err != nil || msg != "" {
+		return msg, err
+	}
+	return "", tx.Commit()
+}
+
+//Same as GrantRole inside a transaction owned by the caller, which must not commit
+//if a rejection reason is returned
+func GrantRoleTx(tx *sql.Tx, grantedBy, userId, roleId uint64, validity role.Validity) (string, error) {
+	if err := middleware.CheckGrantableRole(tx, grantedBy, roleId); err != nil {
+		return "", err
+	}
+	//The user and the role must belong to the same tenant
+	stmt := `UPDATE users SET role_id = $2, role_valid_from = $3, role_valid_until = $4
+		WHERE user_id = $1 AND tenant_id = (SELECT tenant_id FROM roles WHERE role_id = $2)`
+	result, err := tx.Exec(stmt, userId, roleId, validity.ValidFrom, validity.ValidUntil)
+	if err != nil {
+		return "", err
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return "", err
+	} else if count == 0 {
+		return "", ErrUserNotFound
+	}
+	return middleware.CheckStaticSoD(tx, []uint64{userId})
+}
+
 //It's an update operation that updates role_id on users table
 func AssignRoleToUser(w http.ResponseWriter, r *http.Request){
 	//User role name of the role to be assigned to user to get the role_id
@@ -24,20 +80,40 @@ func AssignRoleToUser(w http.ResponseWriter, r *http.Request){
 			Message: "Unable to parse json",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if !role.IsValid() {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error: true,
+			Message: "valid_until must be after valid_from",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Call db connect	
 	db := db.ConnectDB()
 	defer db.Close()
-	stmt := `SELECT role_id FROM roles WHERE role_name = $1`
-	row := db.QueryRow(stmt, role.RoleName)
+	stmt := `SELECT role_id FROM roles WHERE role_name = $1 AND tenant_id = $2`
+	row := db.QueryRow(stmt, role.RoleName, middleware.TenantId(r))
 	err := row.Scan(&role.RoleId)
-	if err, ok := err.(*pq.Error); ok {
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error: true,
+			Message: "Role not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		res := middleware.Response{
 			Error: true,
 			Message: err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Get user_id from req params and convert it to int
 	params := mux.Vars(r)
@@ -49,65 +125,69 @@ func AssignRoleToUser(w http.ResponseWriter, r *http.Request){
 			Message: "Unable to convert req params to int",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
-	//Check if user belong to a group and if that group has that role to be assigned to the user
-	stmt = `SELECT role_id FROM group_roles WHERE group_id =
-	(SELECT group_id FROM users WHERE user_id = $1)`
-	/*
-		If above query returns null, it means either user does not belong to any group
-		or the group the user belong to does not have that role
-	*/
-	var myUserId uint64
-	row = db.QueryRow(stmt, userId)
-	err = row.Scan(&myUserId)
-	//Check if any row was returned or not
-	if err == sql.ErrNoRows {
+	//Check if user belong to a group and if that group, or a group containing it, has that role
+	//to be assigned to the user
+	found, err := GroupsHaveRole(db, uint64(userId), role.RoleId)
+	if err == nil && !found {
 		w.WriteHeader(http.StatusNotFound)
 		res := middleware.Response{
 			Error:   true,
 			Message: "Incomplete!!! User is not part of a group or user's group does not have this role",
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//Check for other errors
-	if err, ok := err.(*pq.Error); ok {
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		res := middleware.Response{
 			Error: true,
 			Message: err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
 	
-	//Now update role_id of user on the users table
-	stmt = `UPDATE users SET role_id = $2 WHERE user_id = $1`
-	result, err := db.Exec(stmt, userId, role.RoleId)
-	//Check for errors
-	if err, ok := err.(*pq.Error); ok {
-		w.WriteHeader(http.StatusInternalServerError)
+	//Now update role_id of user on the users table, optionally limited to a window
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	msg, err := GrantRole(db, principal.UserId, uint64(userId), role.RoleId, role.Validity)
+	if err, ok := err.(middleware.UngrantableError); ok {
+		w.WriteHeader(http.StatusForbidden)
 		res := middleware.Response{
 			Error: true,
 			Message: err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
+		return
 	}
-	//Check if any row was affected in the update operation
-	if count, err := result.RowsAffected(); err != nil {
+	if err == ErrUserNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error: true,
+			Message: "Unsuccessful!!! update operation. User probably doesnt exist.",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		res := middleware.Response{
 			Error: true,
 			Message: "Internal server error" + err.Error(),
 		}
 		json.NewEncoder(w).Encode(res)
-	}else{
-		if count == 0 {
-			w.WriteHeader(http.StatusNotModified)
-			res := middleware.Response{
-				Error: true,
-				Message: "Unsuccessful!!! update operation",
-			}
-			json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error: true,
+			Message: msg,
 		}
+		json.NewEncoder(w).Encode(res)
+		return
 	}
 	//If everything was fine, return response
 	w.WriteHeader(http.StatusCreated)
@@ -130,12 +210,14 @@ func AssignRoleToUser(w http.ResponseWriter, r *http.Request){
 			 Message: "Unable to convert req params to int",
 		 }
 		 json.NewEncoder(w).Encode(res)
+		 return
 	 }
 	 //Call db connection
 	 db := db.ConnectDB()
 	 defer db.Close()
-	 stmt := `UPDATE users SET role_id = NULL WHERE user_id = $1`
-	 result, err := db.Exec(stmt, userId)
+	 stmt := `UPDATE users SET role_id = NULL, role_valid_from = NULL, role_valid_until = NULL
+		WHERE user_id = $1 AND tenant_id = $2`
+	 result, err := db.Exec(stmt, userId, middleware.TenantId(r))
 	 // Check for errors
 	 if err, ok := err.(*pq.Error); ok {
 		 w.WriteHeader(http.StatusInternalServerError)
@@ -144,6 +226,7 @@ func AssignRoleToUser(w http.ResponseWriter, r *http.Request){
 			 Message: "Internal server error" + err.Error(),
 		 }
 		 json.NewEncoder(w).Encode(res)
+		 return
 	 }
 	 //Check if any row was affected during update
 	 if count, err := result.RowsAffected(); err != nil {
@@ -153,14 +236,16 @@ func AssignRoleToUser(w http.ResponseWriter, r *http.Request){
 			 Message: "Unable to return rows affected by update operation",
 		 }
 		 json.NewEncoder(w).Encode(res)
+		 return
 	 }else {
 		 if count == 0 {
-			 w.WriteHeader(http.StatusNotModified)
+			 w.WriteHeader(http.StatusNotFound)
 			 res := middleware.Response{
 				 Error: true,
-				 Message: "Unsuccessful!!! update operation" + err.Error(),
+				 Message: "Unsuccessful!!! update operation. User probably doesnt exist.",
 			 }
 			 json.NewEncoder(w).Encode(res)
+			 return
 		 }
 	 }
 	 //If everything was fine, return response
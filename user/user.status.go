@@ -0,0 +1,532 @@
+package user
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/group"
+	"hrm/middleware"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+//Statuses a user in each status can be moved to. Termination is final
+var transitions = map[string][]string{
+	"pending":    {"active", "terminated"},
+	"active":     {"on_leave", "suspended", "terminated"},
+	"on_leave":   {"active", "suspended", "terminated"},
+	"suspended":  {"active", "terminated"},
+	"terminated": {},
+}
+
+func canTransition(from, to string) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+//Revokes the grants of a terminated user, by the grant type recorded in grant_events.
+//Each statement takes the user id and returns subject_id and object_id of what it removed
+var terminationRevokes = []struct{ grantType, stmt string }{
+	{"user_role", `WITH held AS (
+			SELECT user_id, role_id FROM users WHERE user_id = $1 AND role_id IS NOT NULL
+		)
+		UPDATE users u SET role_id = NULL, role_valid_from = NULL, role_valid_until = NULL
+		FROM held h WHERE u.user_id = h.user_id RETURNING h.user_id, h.role_id`},
+	{"group_membership", `DELETE FROM group_members WHERE user_id = $1 RETURNING user_id, group_id`},
+	{"role_activation", `UPDATE role_activations SET status = 'deactivated', ended_at = NOW()
+		WHERE user_id = $1 AND status IN ('pending', 'active') RETURNING user_id, role_id`},
+}
+
+//Strips a terminated user of every grant and records a grant event for each. Eligibilities
+//and dynamic group memberships are removed too; the dynamic group sync skips terminated users
+func revokeAccess(tx *sql.Tx, userId, tenantId uint64) error {
+	type revoked struct {
+		grantType           string
+		subjectId, objectId uint64
+	}
+	var events []revoked
+	for _, revoke := range terminationRevokes {
+		rows, err := tx.Query(revoke.stmt, userId)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			e := revoked{grantType: revoke.grantType}
+			if err := rows.Scan(&e.subjectId, &e.objectId); err != nil {
+				rows.Close()
+				return err
+			}
+			events = append(events, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	stmt := `INSERT INTO grant_events(event_type, grant_type, subject_id, object_id, tenant_id)
+		VALUES ('terminated', $1, $2, $3, $4)`
+	for _, e := range events {
+		if _, err := tx.Exec(stmt, e.grantType, e.subjectId, e.objectId, tenantId); err != nil {
+			return err
+		}
+	}
+	for _, stmt := range []string{
+		`DELETE FROM role_eligibilities WHERE user_id = $1`,
+		`DELETE FROM group_rule_members WHERE user_id = $1`,
+		`UPDATE user_status_changes SET state = 'cancelled' WHERE user_id = $1 AND state = 'scheduled'`,
+	} {
+		if _, err := tx.Exec(stmt, userId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Run in the transaction terminating a user, once their access is revoked
+type TerminationHook func(tx *sql.Tx, userId, tenantId uint64, effectiveDate string) error
+
+var terminationHooks []TerminationHook
+
+//Registers hook to run whenever a user is terminated. Meant to be called before the server starts
+func OnTermination(hook TerminationHook) {
+	terminationHooks = append(terminationHooks, hook)
+}
+
+//Puts the user, whose row the caller locked in tx, in status as of effectiveDate. Locking
+//the user out revokes the tokens issued so far, terminating them also revokes their access.
+//Dynamic groups can match on user.status, so the user's rule memberships are re-evaluated;
+//returns the reason the new memberships break separation of duties, if any
+func applyStatus(tx *sql.Tx, userId, tenantId uint64, status, effectiveDate string) (string, error) {
+	stmt := `UPDATE users SET status = $2, status_since = $3,
+			tokens_valid_after = CASE WHEN $4 THEN NOW() ELSE tokens_valid_after END
+		WHERE user_id = $1`
+	_, err := tx.Exec(stmt, userId, status, effectiveDate, middleware.StatusLocked(status))
+	if err == nil && status == "terminated" {
+		err = revokeAccess(tx, userId, tenantId)
+		for _, hook := range terminationHooks {
+			if err != nil {
+				break
+			}
+			err = hook(tx, userId, tenantId, effectiveDate)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return group.SyncUser(tx, userId)
+}
+
+//Sets the first status of a user hired from startDate (YYYY-MM-DD): pending until then with
+//the move to active scheduled for that day, or active at once if it is not in the future
+func StartLifecycle(tx *sql.Tx, userId, tenantId, changedBy uint64, startDate string) error {
+	var later bool
+	if err := tx.QueryRow(`SELECT $1::DATE > CURRENT_DATE`, startDate).Scan(&later); err != nil {
+		return err
+	}
+	status := "active"
+	if later {
+		status = "pending"
+	}
+	stmt := `UPDATE users SET status = $2, status_since = LEAST($3::DATE, CURRENT_DATE) WHERE user_id = $1`
+	if _, err := tx.Exec(stmt, userId, status, startDate); err != nil {
+		return err
+	}
+	stmt = `INSERT INTO user_status_changes(user_id, tenant_id, to_status, effective_date, reason, state,
+			changed_by, applied_at)
+		VALUES ($1, $2, $3, LEAST($4::DATE, CURRENT_DATE), 'Hired', 'applied', $5, NOW())`
+	if _, err := tx.Exec(stmt, userId, tenantId, status, startDate, changedBy); err != nil {
+		return err
+	}
+	if !later {
+		return nil
+	}
+	stmt = `INSERT INTO user_status_changes(user_id, tenant_id, from_status, to_status, effective_date, reason,
+			state, changed_by)
+		VALUES ($1, $2, 'pending', 'active', $3, 'Start date', 'scheduled', $4)`
+	_, err := tx.Exec(stmt, userId, tenantId, startDate, changedBy)
+	return err
+}
+
+//For changing the lifecycle status of a user. Takes status, the optional effective_date
+//(YYYY-MM-DD, today if left out) and reason. A change dated in the future is scheduled and
+//applied on that day, any other takes effect at once
+func ChangeUserStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	change := StatusChangeModel{}
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if _, ok := transitions[change.Status]; !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "status must be pending, active, on_leave, suspended or terminated",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if change.EffectiveDate != "" {
+		if _, err := time.Parse("2006-01-02", change.EffectiveDate); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "effective_date must be a date as YYYY-MM-DD",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//An admin locking themselves out cannot undo it
+	if uint64(userId) == principal.UserId {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Cannot change your own status",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	var current string
+	stmt := `SELECT status FROM users WHERE user_id = $1 AND tenant_id = $2 FOR UPDATE`
+	err = tx.QueryRow(stmt, uint64(userId), tenantId).Scan(&current)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "User not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && !canTransition(current, change.Status) {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Cannot move a " + current + " user to " + change.Status,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Dates are compared by the database, whose day the scheduler goes by
+	var scheduled bool
+	if err == nil {
+		stmt = `SELECT TO_CHAR(d, 'YYYY-MM-DD'), d > CURRENT_DATE
+			FROM (SELECT COALESCE(NULLIF($1, '')::DATE, CURRENT_DATE) AS d) dates`
+		err = tx.QueryRow(stmt, change.EffectiveDate).Scan(&change.EffectiveDate, &scheduled)
+	}
+	if err == nil {
+		change.State = "applied"
+		if scheduled {
+			change.State = "scheduled"
+		}
+		stmt = `INSERT INTO user_status_changes(user_id, tenant_id, from_status, to_status, effective_date,
+				reason, state, changed_by, applied_at)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, CASE WHEN $7 = 'applied' THEN NOW() END)
+			RETURNING change_id`
+		err = tx.QueryRow(stmt, uint64(userId), tenantId, current, change.Status, change.EffectiveDate,
+			change.Reason, change.State, principal.UserId).Scan(&change.ChangeId)
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "User already has a scheduled status change, cancel it first",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var msg string
+	if err == nil && !scheduled {
+		msg, err = applyStatus(tx, uint64(userId), tenantId, change.Status, change.EffectiveDate)
+	}
+	if err == nil && msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	msg = "User is now " + change.Status
+	if scheduled {
+		msg = "User becomes " + change.Status + " on " + change.EffectiveDate + ", change id " +
+			strconv.FormatUint(change.ChangeId, 10)
+	}
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: msg,
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For fetching the status changes of a user, newest first, including the scheduled one
+func GetStatusChanges(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract user id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	found, err := middleware.InTenant(db, "users", uint64(userId), tenantId)
+	if err == nil && !found {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "User not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	data := []StatusChangeModel{}
+	var rows *sql.Rows
+	if err == nil {
+		stmt := `SELECT change_id, user_id, COALESCE(from_status, ''), to_status, TO_CHAR(effective_date, 'YYYY-MM-DD'),
+				COALESCE(reason, ''), state, changed_by, created_at, applied_at
+			FROM user_status_changes WHERE user_id = $1 AND tenant_id = $2 ORDER BY change_id DESC`
+		rows, err = db.Query(stmt, uint64(userId), tenantId)
+	}
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			c := StatusChangeModel{}
+			err = rows.Scan(&c.ChangeId, &c.UserId, &c.FromStatus, &c.Status, &c.EffectiveDate, &c.Reason,
+				&c.State, &c.ChangedBy, &c.CreatedAt, &c.AppliedAt)
+			if err != nil {
+				break
+			}
+			data = append(data, c)
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of status change objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For cancelling a status change that has not taken effect yet
+func CancelStatusChange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Extract user id and change id from req params
+	params := mux.Vars(r)
+	userId, err := strconv.Atoi(params["user_id"])
+	var changeId int
+	if err == nil {
+		changeId, err = strconv.Atoi(params["change_id"])
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `UPDATE user_status_changes SET state = 'cancelled'
+		WHERE change_id = $1 AND user_id = $2 AND tenant_id = $3 AND state = 'scheduled'`
+	result, err := db.Exec(stmt, uint64(changeId), uint64(userId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Scheduled status change not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Status change cancelled",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//Applies the scheduled status changes that took effect every interval. Meant to run in its
+//own goroutine for the life of the server
+func ApplyStatusChanges(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		db := db.ConnectDB()
+		count, err := applyDue(db)
+		db.Close()
+		if err != nil {
+			log.Printf("status changes: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("status changes: applied %d scheduled changes", count)
+		}
+	}
+}
+
+//Applies each due change in its own transaction. A change the user's status no longer
+//allows, e.g. reinstating a user terminated in the meantime, is cancelled instead
+func applyDue(db *sql.DB) (int, error) {
+	rows, err := db.Query(`SELECT change_id FROM user_status_changes
+		WHERE state = 'scheduled' AND effective_date <= CURRENT_DATE ORDER BY effective_date`)
+	if err != nil {
+		return 0, err
+	}
+	var changeIds []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		changeIds = append(changeIds, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, changeId := range changeIds {
+		applied, err := applyOne(db, changeId)
+		if err != nil {
+			return count, err
+		}
+		if applied {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func applyOne(db *sql.DB, changeId uint64) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var userId, tenantId uint64
+	var current, status, effectiveDate string
+	stmt := `SELECT c.user_id, c.tenant_id, u.status, c.to_status, TO_CHAR(c.effective_date, 'YYYY-MM-DD')
+		FROM user_status_changes c JOIN users u ON u.user_id = c.user_id
+		WHERE c.change_id = $1 AND c.state = 'scheduled' FOR UPDATE`
+	err = tx.QueryRow(stmt, changeId).Scan(&userId, &tenantId, &current, &status, &effectiveDate)
+	if err == sql.ErrNoRows {
+		//Cancelled since it was listed
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	applied := canTransition(current, status)
+	if applied {
+		stmt = `UPDATE user_status_changes SET state = 'applied', from_status = $2, applied_at = NOW()
+			WHERE change_id = $1`
+		_, err = tx.Exec(stmt, changeId, current)
+		var msg string
+		if err == nil {
+			msg, err = applyStatus(tx, userId, tenantId, status, effectiveDate)
+		}
+		if err == nil && msg != "" {
+			log.Printf("status changes: change %d left scheduled: %s", changeId, msg)
+			return false, nil
+		}
+	} else {
+		log.Printf("status changes: change %d cancelled, a %s user cannot become %s", changeId, current, status)
+		stmt = `UPDATE user_status_changes SET state = 'cancelled' WHERE change_id = $1`
+		_, err = tx.Exec(stmt, changeId)
+	}
+	if err != nil {
+		return false, err
+	}
+	return applied, tx.Commit()
+}
@@ -0,0 +1,84 @@
+package workflow
+
+import "time"
+
+//A checklist item of a template. assignee is hr, it or manager, due_days counts from the
+//start date of the workflow and can be negative
+type TemplateTaskModel struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Assignee    string `json:"assignee"`
+	DueDays     int    `json:"due_days"`
+}
+
+//An onboarding or offboarding template. An empty job_title or null department_id matches
+//any. group_ids and role_id are only given by onboarding templates
+type TemplateModel struct {
+	TemplateId   uint64              `json:"id"`
+	TemplateName string              `json:"template_name"`
+	Kind         string              `json:"kind"`
+	JobTitle     string              `json:"job_title"`
+	DepartmentId *uint64             `json:"department_id"`
+	RoleId       *uint64             `json:"role_id"`
+	GroupIds     []uint64            `json:"group_ids"`
+	Tasks        []TemplateTaskModel `json:"tasks"`
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+//A new hire. start_date is YYYY-MM-DD; the account stays pending until then. template_id
+//picks a template instead of the one matching job_title and department_id. The account has no
+//password until one is set through PUT /users/{user_id}/password
+type OnboardingModel struct {
+	Firstname      string  `json:"first_name"`
+	Lastname       string  `json:"last_name"`
+	Middlename     string  `json:"middle_name"`
+	Username       string  `json:"username"`
+	EmployeeNumber string  `json:"employee_number"`
+	JobTitle       string  `json:"job_title"`
+	Location       string  `json:"location"`
+	EmploymentType string  `json:"employment_type"`
+	WorkEmail      string  `json:"work_email"`
+	DepartmentId   *uint64 `json:"department_id"`
+	ManagerId      *uint64 `json:"manager_id"`
+	StartDate      string  `json:"start_date"`
+	TemplateId     *uint64 `json:"template_id"`
+}
+
+//A task of a workflow. status is open, done or skipped
+type TaskModel struct {
+	TaskId      uint64     `json:"id"`
+	WorkflowId  uint64     `json:"workflow_id"`
+	UserId      uint64     `json:"user_id"`
+	Username    string     `json:"username"`
+	Kind        string     `json:"kind"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Assignee    string     `json:"assignee"`
+	AssigneeId  *uint64    `json:"assignee_id"`
+	DueDate     string     `json:"due_date"`
+	Status      string     `json:"status"`
+	Note        string     `json:"note"`
+	CompletedBy *uint64    `json:"completed_by"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+//The onboarding or offboarding of a user. status is open, completed or cancelled
+type WorkflowModel struct {
+	WorkflowId uint64      `json:"id"`
+	UserId     uint64      `json:"user_id"`
+	Username   string      `json:"username"`
+	TemplateId *uint64     `json:"template_id"`
+	Kind       string      `json:"kind"`
+	Status     string      `json:"status"`
+	StartDate  string      `json:"start_date"`
+	CreatedBy  *uint64     `json:"created_by"`
+	CreatedAt  time.Time   `json:"created_at"`
+	ClosedAt   *time.Time  `json:"closed_at"`
+	Tasks      []TaskModel `json:"tasks,omitempty"`
+}
+
+//Marks a task done or skipped, or open again
+type TaskUpdateModel struct {
+	Status string `json:"status"`
+	Note   string `json:"note"`
+}
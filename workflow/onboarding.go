@@ -0,0 +1,294 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/employee"
+	"hrm/group"
+	"hrm/middleware"
+	"hrm/role"
+	"hrm/user"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//Returns what is wrong with a new hire, or an empty string
+func validateHire(o OnboardingModel) string {
+	if o.Firstname == "" || o.Lastname == "" || o.Username == "" {
+		return "first_name, last_name and username are required"
+	}
+	if o.EmployeeNumber == "" {
+		return "employee_number is required"
+	}
+	if _, err := time.Parse("2006-01-02", o.StartDate); err != nil {
+		return "start_date must be a date as YYYY-MM-DD"
+	}
+	if o.WorkEmail != "" {
+		if _, err := mail.ParseAddress(o.WorkEmail); err != nil {
+			return "work_email is not a valid email address"
+		}
+	}
+	return ""
+}
+
+//Returns the reason the department, manager or template of a new hire cannot be used in
+//tenantId, or an empty string
+func checkHireReferences(tx *sql.Tx, o OnboardingModel, tenantId uint64) (string, error) {
+	var found bool
+	if o.DepartmentId != nil {
+		stmt := `SELECT EXISTS(SELECT 1 FROM departments WHERE department_id = $1 AND tenant_id = $2)`
+		if err := tx.QueryRow(stmt, *o.DepartmentId, tenantId).Scan(&found); err != nil || !found {
+			return "Department not found", err
+		}
+	}
+	if o.ManagerId != nil {
+		stmt := `SELECT EXISTS(SELECT 1 FROM employees WHERE user_id = $1 AND tenant_id = $2)`
+		if err := tx.QueryRow(stmt, *o.ManagerId, tenantId).Scan(&found); err != nil || !found {
+			return "Manager not found", err
+		}
+	}
+	if o.TemplateId != nil {
+		stmt := `SELECT EXISTS(SELECT 1 FROM workflow_templates WHERE template_id = $1 AND tenant_id = $2
+			AND kind = 'onboarding')`
+		if err := tx.QueryRow(stmt, *o.TemplateId, tenantId).Scan(&found); err != nil || !found {
+			return "Onboarding template not found", err
+		}
+	}
+	return "", nil
+}
+
+//The username is unique, and so is the employee number within the tenant
+func hireConflictMessage(err *pq.Error) string {
+	if err.Constraint == "emp_tnt_num_key" {
+		return "Employee number already in use"
+	}
+	return "User already exists"
+}
+
+//Creates the account and employee record of a new hire in tx, with the department
+//assignment from the start date. Returns the new user id. The account has no password, so
+//nobody can log in as the hire until a holder of modify_any_user or the hire sets one
+func createHire(tx *sql.Tx, o OnboardingModel, tenantId, createdBy uint64) (uint64, error) {
+	var userId uint64
+	stmt := `INSERT INTO users(first_name, last_name, middle_name, username, tenant_id)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5) RETURNING user_id`
+	err := tx.QueryRow(stmt, o.Firstname, o.Lastname, o.Middlename, o.Username, tenantId).Scan(&userId)
+	if err == nil {
+		err = user.StartLifecycle(tx, userId, tenantId, createdBy, o.StartDate)
+	}
+	if err == nil {
+		stmt = `INSERT INTO employees(user_id, tenant_id, employee_number, hire_date, job_title, location,
+				employment_type, work_email, manager_id)
+			VALUES ($1, $2, $3, $4::DATE, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), $9)`
+		_, err = tx.Exec(stmt, userId, tenantId, o.EmployeeNumber, o.StartDate, o.JobTitle, o.Location,
+			o.EmploymentType, o.WorkEmail, o.ManagerId)
+	}
+	if err == nil {
+		record := employee.EmployeeModel{
+			EmployeeNumber: o.EmployeeNumber, HireDate: o.StartDate, JobTitle: o.JobTitle,
+			Location: o.Location, EmploymentType: o.EmploymentType, WorkEmail: o.WorkEmail,
+		}
+		err = employee.RecordChanges(tx, userId, tenantId, createdBy, employee.EmployeeModel{}, record)
+	}
+	if err == nil && o.ManagerId != nil {
+		err = employee.RecordChange(tx, userId, tenantId, createdBy, "manager_id", "", strconv.FormatUint(*o.ManagerId, 10))
+	}
+	if err == nil && o.DepartmentId != nil {
+		stmt = `INSERT INTO employee_assignments(user_id, tenant_id, department_id, effective_from, created_by)
+			VALUES ($1, $2, $3, $4::DATE, $5)`
+		_, err = tx.Exec(stmt, userId, tenantId, *o.DepartmentId, o.StartDate, createdBy)
+	}
+	return userId, err
+}
+
+//Returns the privilege the caller lacks to hand out the role or the groups of t one by one, or
+//an empty string. Onboarding reaches no further than those routes
+func missingGrantPrivilege(privileges []string, t TemplateModel) string {
+	needed := []string{}
+	if t.RoleId != nil {
+		needed = append(needed, user.PrivGrantUserRole)
+	}
+	if len(t.GroupIds) > 0 {
+		needed = append(needed, group.PrivAddUserToGroup)
+	}
+	for _, privilege := range needed {
+		held := false
+		for _, p := range privileges {
+			held = held || p == privilege
+		}
+		if !held {
+			return privilege
+		}
+	}
+	return ""
+}
+
+//Gives a new hire the role and groups of an onboarding template. Returns the reason the role
+//breaks separation of duties, if any; group.SyncUser checks the groups once all are given.
+//Returns a middleware.UngrantableError if grantedBy may not hand out the role or a group's roles
+func grantTemplateAccess(tx *sql.Tx, t TemplateModel, userId, tenantId, grantedBy uint64) (string, error) {
+	if t.RoleId != nil {
+		msg, err := user.GrantRoleTx(tx, grantedBy, userId, *t.RoleId, role.Validity{})
+		if err != nil || msg != "" {
+			return msg, err
+		}
+	}
+	//Groups turned dynamic since the template was saved are left out
+	stmt := `INSERT INTO group_members(group_id, user_id, tenant_id, added_by)
+		SELECT group_id, $2, tenant_id, $3 FROM groups
+		WHERE group_id = $1 AND tenant_id = $4 AND membership_rule IS NULL`
+	for _, groupId := range t.GroupIds {
+		if err := middleware.CheckGrantableGroup(tx, grantedBy, groupId); err != nil {
+			return "", err
+		}
+		if _, err := tx.Exec(stmt, groupId, userId, grantedBy, tenantId); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+//For onboarding a new hire in one go: the account, pending until start_date, the employee
+//record, the department assignment, the role and groups of the onboarding template and the
+//workflow with its tasks for HR, IT and the manager. The template is the one matching
+//job_title and department_id unless template_id picks one. The caller needs the privileges
+//of the routes granting the template's role and groups; the password is set afterwards
+func Onboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	hire := OnboardingModel{}
+	if err := json.NewDecoder(r.Body).Decode(&hire); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateHire(hire); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	msg, err := checkHireReferences(tx, hire, tenantId)
+	if err == nil && msg != "" {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var userId uint64
+	if err == nil {
+		userId, err = createHire(tx, hire, tenantId, principal.UserId)
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: hireConflictMessage(err),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	var templateId, workflowId uint64
+	if err == nil && hire.TemplateId != nil {
+		templateId = *hire.TemplateId
+	} else if err == nil {
+		templateId, err = matchTemplate(tx, tenantId, "onboarding", hire.JobTitle, hire.DepartmentId)
+	}
+	if err == nil && templateId != 0 {
+		var t TemplateModel
+		t, err = loadTemplate(tx, templateId, tenantId)
+		missing := missingGrantPrivilege(middleware.PrivilegesFromContext(r.Context()), t)
+		if err == nil && missing != "" {
+			w.WriteHeader(http.StatusForbidden)
+			res := middleware.Response{
+				Error:   true,
+				Message: "The onboarding template grants a role or groups, which requires " + missing,
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		if err == nil {
+			msg, err = grantTemplateAccess(tx, t, userId, tenantId, principal.UserId)
+		}
+		if err == nil && msg == "" {
+			workflowId, err = startWorkflow(tx, t, userId, tenantId, &principal.UserId, hire.StartDate)
+		}
+	}
+	//The new employee attributes can put the user in dynamic groups
+	if err == nil && msg == "" {
+		msg, err = group.SyncUser(tx, userId)
+	}
+	if err == nil && msg == "" {
+		err = tx.Commit()
+	}
+	if err, ok := err.(middleware.UngrantableError); ok {
+		w.WriteHeader(http.StatusForbidden)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg != "" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	message := "User onboarded with id " + strconv.FormatUint(userId, 10)
+	if workflowId != 0 {
+		message += ", workflow id " + strconv.FormatUint(workflowId, 10)
+	} else if templateId == 0 {
+		message += ", no onboarding template applies"
+	}
+	w.WriteHeader(http.StatusCreated)
+	res := middleware.Response{
+		Error:   false,
+		Message: message,
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,15 @@
+package workflow
+
+import "hrm/catalog"
+
+//Privileges required by the workflow routes
+var (
+	PrivManageTemplates = catalog.Declare("manage_workflow_templates", "Create, edit and delete onboarding and offboarding templates")
+	PrivReadTemplates   = catalog.Declare("read_workflow_templates", "List onboarding and offboarding templates")
+	PrivOnboardUser     = catalog.Declare("onboard_user", "Create the account, employee record, groups, role and checklist of a new hire")
+	PrivReadWorkflows   = catalog.Declare("read_workflows", "List onboarding and offboarding workflows and their tasks")
+	PrivManageWorkflows = catalog.Declare("manage_workflows", "Cancel workflows and update any of their tasks")
+	PrivWorkTasks       = catalog.Declare("work_workflow_tasks", "List and complete the workflow tasks assigned to the caller")
+	PrivWorkHrTasks     = catalog.Declare("work_hr_tasks", "Work the HR tasks of every workflow")
+	PrivWorkItTasks     = catalog.Declare("work_it_tasks", "Work the IT tasks of every workflow")
+)
@@ -0,0 +1,53 @@
+package workflow
+
+import (
+	"hrm/middleware"
+
+	"github.com/gorilla/mux"
+)
+
+func HandleWorkflowRoutes(r *mux.Router) {
+	//Endpoint for creating an onboarding or offboarding template
+	r.HandleFunc("/workflow-templates",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTemplates, AddTemplate))).Methods("POST")
+
+	//Endpoint for fetching all templates. ?kind= narrows it down
+	r.HandleFunc("/workflow-templates",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadTemplates, GetTemplates))).Methods("GET")
+
+	//Endpoint for fetching a single template
+	r.HandleFunc("/workflow-templates/{template_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadTemplates, GetTemplate))).Methods("GET")
+
+	//Endpoint for editing a template
+	r.HandleFunc("/workflow-templates/{template_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTemplates, EditTemplate))).Methods("PUT")
+
+	//Endpoint for deleting a template
+	r.HandleFunc("/workflow-templates/{template_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageTemplates, DeleteTemplate))).Methods("DELETE")
+
+	//Endpoint for onboarding a new hire
+	r.HandleFunc("/onboarding",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivOnboardUser, Onboard))).Methods("POST")
+
+	//Endpoint for fetching workflows. ?user_id=, ?kind= and ?status= narrow it down
+	r.HandleFunc("/workflows",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadWorkflows, GetWorkflows))).Methods("GET")
+
+	//Endpoint for fetching a single workflow with its tasks
+	r.HandleFunc("/workflows/{workflow_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivReadWorkflows, GetWorkflow))).Methods("GET")
+
+	//Endpoint for cancelling a workflow
+	r.HandleFunc("/workflows/{workflow_id}/cancel",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivManageWorkflows, CancelWorkflow))).Methods("POST")
+
+	//Endpoint for fetching the open tasks the caller works
+	r.HandleFunc("/workflow-tasks/mine",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivWorkTasks, GetMyTasks))).Methods("GET")
+
+	//Endpoint for completing, skipping or reopening a task
+	r.HandleFunc("/workflow-tasks/{task_id}",
+		middleware.JwtVerify(middleware.IsAuthorize(PrivWorkTasks, UpdateTask))).Methods("PUT")
+}
@@ -0,0 +1,184 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+//The queues a caller works besides their own manager tasks
+type queues struct {
+	hr, it, all bool
+}
+
+func loadQueues(r *http.Request) queues {
+	q := queues{}
+	for _, privilege := range middleware.PrivilegesFromContext(r.Context()) {
+		switch privilege {
+		case PrivWorkHrTasks:
+			q.hr = true
+		case PrivWorkItTasks:
+			q.it = true
+		case PrivManageWorkflows:
+			q.all = true
+		}
+	}
+	return q
+}
+
+//Reports whether the caller may work the task. Manager tasks without a manager fall to HR
+func (q queues) canWork(t TaskModel, userId uint64) bool {
+	switch {
+	case q.all:
+		return true
+	case t.Assignee == "manager" && t.AssigneeId != nil:
+		return *t.AssigneeId == userId
+	case t.Assignee == "it":
+		return q.it
+	}
+	return q.hr
+}
+
+//For fetching the open tasks of open workflows the caller works, the soonest due first
+func GetMyTasks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	q := loadQueues(r)
+	stmt := selectTask + ` WHERE t.tenant_id = $1 AND t.status = 'open' AND w.status = 'open' AND (
+			(t.assignee = 'manager' AND t.assignee_id = $2)
+			OR (t.assignee = 'manager' AND t.assignee_id IS NULL AND $3)
+			OR (t.assignee = 'hr' AND $3) OR (t.assignee = 'it' AND $4))
+		ORDER BY t.due_date, t.task_id`
+	data, err := queryTasks(db, stmt, principal.TenantId, principal.UserId, q.hr, q.it)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of task objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For marking a task done or skipped, with an optional note, or open again. The workflow is
+//completed once none of its tasks is open
+func UpdateTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get task id from req params
+	params := mux.Vars(r)
+	taskId, err := strconv.Atoi(params["task_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	update := TaskUpdateModel{}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if update.Status != "done" && update.Status != "skipped" && update.Status != "open" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "status must be done, skipped or open",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	principal, _ := middleware.PrincipalFromContext(r.Context())
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	q := loadQueues(r)
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	//Locking the workflow keeps two last tasks finishing together from both leaving it open
+	var workflowStatus string
+	stmt := `SELECT w.status FROM workflows w JOIN workflow_tasks t ON t.workflow_id = w.workflow_id
+		WHERE t.task_id = $1 AND t.tenant_id = $2 FOR UPDATE OF w`
+	err = tx.QueryRow(stmt, uint64(taskId), principal.TenantId).Scan(&workflowStatus)
+	var tasks []TaskModel
+	if err == nil {
+		tasks, err = queryTasks(tx, selectTask+` WHERE t.task_id = $1`, uint64(taskId))
+	}
+	if err == sql.ErrNoRows || (err == nil && (len(tasks) == 0 || !q.canWork(tasks[0], principal.UserId))) {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Task not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && workflowStatus != "open" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Workflow is " + workflowStatus,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		stmt = `UPDATE workflow_tasks SET status = $2, note = NULLIF($3, ''),
+				completed_by = CASE WHEN $2 = 'open' THEN NULL ELSE $4::BIGINT END,
+				completed_at = CASE WHEN $2 = 'open' THEN NULL ELSE NOW() END
+			WHERE task_id = $1`
+		_, err = tx.Exec(stmt, uint64(taskId), update.Status, update.Note, principal.UserId)
+	}
+	if err == nil {
+		stmt = `UPDATE workflows SET status = 'completed', closed_at = NOW() WHERE workflow_id = $1
+			AND NOT EXISTS(SELECT 1 FROM workflow_tasks WHERE workflow_id = $1 AND status = 'open')`
+		_, err = tx.Exec(stmt, tasks[0].WorkflowId)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Task " + update.Status,
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,429 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const selectTemplate = `SELECT template_id, template_name, kind, COALESCE(job_title, ''), department_id, role_id, created_at
+	FROM workflow_templates`
+
+//Implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+var assignees = map[string]bool{"hr": true, "it": true, "manager": true}
+
+//Returns what is wrong with a template, or an empty string
+func validateTemplate(t TemplateModel) string {
+	if t.TemplateName == "" {
+		return "template_name is required"
+	}
+	if t.Kind != "onboarding" && t.Kind != "offboarding" {
+		return "kind must be onboarding or offboarding"
+	}
+	//Termination already revokes every group and role
+	if t.Kind == "offboarding" && (t.RoleId != nil || len(t.GroupIds) > 0) {
+		return "Offboarding templates give no groups or role"
+	}
+	for _, task := range t.Tasks {
+		if task.Title == "" {
+			return "Every task needs a title"
+		}
+		if !assignees[task.Assignee] {
+			return "assignee must be hr, it or manager"
+		}
+	}
+	return ""
+}
+
+//Returns the reason the department, role or groups of a template cannot be used in
+//tenantId, or an empty string. Dynamic groups are rejected, their members come from the rule
+func checkTemplateReferences(q queryer, t TemplateModel, tenantId uint64) (string, error) {
+	var found bool
+	if t.DepartmentId != nil {
+		stmt := `SELECT EXISTS(SELECT 1 FROM departments WHERE department_id = $1 AND tenant_id = $2)`
+		if err := q.QueryRow(stmt, *t.DepartmentId, tenantId).Scan(&found); err != nil || !found {
+			return "Department not found", err
+		}
+	}
+	if t.RoleId != nil {
+		if found, err := middleware.InTenant(q, "roles", *t.RoleId, tenantId); err != nil || !found {
+			return "Role not found", err
+		}
+	}
+	for _, groupId := range t.GroupIds {
+		var dynamic bool
+		stmt := `SELECT membership_rule IS NOT NULL FROM groups WHERE group_id = $1 AND tenant_id = $2`
+		err := q.QueryRow(stmt, groupId, tenantId).Scan(&dynamic)
+		if err == sql.ErrNoRows {
+			return "Group " + strconv.FormatUint(groupId, 10) + " not found", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if dynamic {
+			return "Group " + strconv.FormatUint(groupId, 10) + " is dynamic, its members come from its rule", nil
+		}
+	}
+	return "", nil
+}
+
+//Replaces the groups and tasks of a template
+func saveTemplateParts(tx *sql.Tx, t TemplateModel) error {
+	for _, stmt := range []string{
+		`DELETE FROM workflow_template_groups WHERE template_id = $1`,
+		`DELETE FROM workflow_template_tasks WHERE template_id = $1`,
+	} {
+		if _, err := tx.Exec(stmt, t.TemplateId); err != nil {
+			return err
+		}
+	}
+	stmt := `INSERT INTO workflow_template_groups(template_id, group_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	for _, groupId := range t.GroupIds {
+		if _, err := tx.Exec(stmt, t.TemplateId, groupId); err != nil {
+			return err
+		}
+	}
+	stmt = `INSERT INTO workflow_template_tasks(template_id, position, title, description, assignee, due_days)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)`
+	for i, task := range t.Tasks {
+		if _, err := tx.Exec(stmt, t.TemplateId, i+1, task.Title, task.Description, task.Assignee, task.DueDays); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Reads the template with its groups and tasks. Returns sql.ErrNoRows if tenantId has no such template
+func loadTemplate(q queryer, templateId, tenantId uint64) (TemplateModel, error) {
+	t := TemplateModel{GroupIds: []uint64{}, Tasks: []TemplateTaskModel{}}
+	row := q.QueryRow(selectTemplate+` WHERE template_id = $1 AND tenant_id = $2`, templateId, tenantId)
+	err := row.Scan(&t.TemplateId, &t.TemplateName, &t.Kind, &t.JobTitle, &t.DepartmentId, &t.RoleId, &t.CreatedAt)
+	if err != nil {
+		return t, err
+	}
+	rows, err := q.Query(`SELECT group_id FROM workflow_template_groups WHERE template_id = $1 ORDER BY group_id`, templateId)
+	if err != nil {
+		return t, err
+	}
+	for rows.Next() {
+		var groupId uint64
+		if err := rows.Scan(&groupId); err != nil {
+			rows.Close()
+			return t, err
+		}
+		t.GroupIds = append(t.GroupIds, groupId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return t, err
+	}
+	stmt := `SELECT title, COALESCE(description, ''), assignee, due_days FROM workflow_template_tasks
+		WHERE template_id = $1 ORDER BY position`
+	rows, err = q.Query(stmt, templateId)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		task := TemplateTaskModel{}
+		if err := rows.Scan(&task.Title, &task.Description, &task.Assignee, &task.DueDays); err != nil {
+			return t, err
+		}
+		t.Tasks = append(t.Tasks, task)
+	}
+	return t, rows.Err()
+}
+
+//Template names are unique, and so is the job title and department a template applies to
+func conflictMessage(err *pq.Error) string {
+	if err.Constraint == "wf_tpl_match_idx" {
+		return "Another template of this kind applies to the same job title and department"
+	}
+	return "Template name already in use"
+}
+
+//Checks, saves and writes the response for a template being created, or replaced when
+//templateId is not zero
+func saveTemplate(w http.ResponseWriter, r *http.Request, templateId uint64) {
+	t := TemplateModel{}
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to parse req body to json",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if msg := validateTemplate(t); msg != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer tx.Rollback()
+	msg, err := checkTemplateReferences(tx, t, tenantId)
+	if err == nil && msg != "" {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: msg,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil && templateId == 0 {
+		stmt := `INSERT INTO workflow_templates(tenant_id, template_name, kind, job_title, department_id, role_id)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6) RETURNING template_id`
+		err = tx.QueryRow(stmt, tenantId, t.TemplateName, t.Kind, t.JobTitle, t.DepartmentId, t.RoleId).Scan(&t.TemplateId)
+	} else if err == nil {
+		t.TemplateId = templateId
+		stmt := `UPDATE workflow_templates SET template_name = $3, kind = $4, job_title = NULLIF($5, ''),
+				department_id = $6, role_id = $7
+			WHERE template_id = $1 AND tenant_id = $2`
+		var result sql.Result
+		result, err = tx.Exec(stmt, templateId, tenantId, t.TemplateName, t.Kind, t.JobTitle, t.DepartmentId, t.RoleId)
+		if err == nil {
+			if count, err := result.RowsAffected(); err != nil || count == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				res := middleware.Response{
+					Error:   true,
+					Message: "Template not found",
+				}
+				json.NewEncoder(w).Encode(res)
+				return
+			}
+		}
+	}
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: conflictMessage(err),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		err = saveTemplateParts(tx, t)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Internal server error" + err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	if templateId == 0 {
+		w.WriteHeader(http.StatusCreated)
+		res := middleware.Response{
+			Error:   false,
+			Message: "Template created with id " + strconv.FormatUint(t.TemplateId, 10),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Template updated",
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+//For creating a template. Takes template_name, kind, the optional job_title, department_id,
+//role_id and group_ids it applies to or gives, and the tasks of its checklist
+func AddTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	saveTemplate(w, r, 0)
+}
+
+//For fetching all templates. ?kind= narrows it down
+func GetTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `SELECT template_id FROM workflow_templates WHERE tenant_id = $1 AND ($2 = '' OR kind = $2)
+		ORDER BY kind, template_name`
+	rows, err := db.Query(stmt, tenantId, r.URL.Query().Get("kind"))
+	var templateIds []uint64
+	if err == nil {
+		for rows.Next() {
+			var id uint64
+			if err = rows.Scan(&id); err != nil {
+				break
+			}
+			templateIds = append(templateIds, id)
+		}
+		rows.Close()
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+	data := []TemplateModel{}
+	for _, templateId := range templateIds {
+		if err != nil {
+			break
+		}
+		var t TemplateModel
+		if t, err = loadTemplate(db, templateId, tenantId); err == nil {
+			data = append(data, t)
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return array of template objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching a single template with its groups and tasks
+func GetTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get template id from req params
+	params := mux.Vars(r)
+	templateId, err := strconv.Atoi(params["template_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	t, err := loadTemplate(db, uint64(templateId), middleware.TenantId(r))
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Template not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return the template object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(t)
+}
+
+//For editing a template. Every field is replaced, groups and tasks included. Workflows
+//already started keep the tasks they were given
+func EditTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get template id from req params
+	params := mux.Vars(r)
+	templateId, err := strconv.Atoi(params["template_id"])
+	if err != nil || templateId <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	saveTemplate(w, r, uint64(templateId))
+}
+
+//For deleting a template. Workflows started from it are kept
+func DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get template id from req params
+	params := mux.Vars(r)
+	templateId, err := strconv.Atoi(params["template_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := `DELETE FROM workflow_templates WHERE template_id = $1 AND tenant_id = $2`
+	result, err := db.Exec(stmt, uint64(templateId), middleware.TenantId(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if count, err := result.RowsAffected(); err != nil || count == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Template not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Template deleted",
+	}
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,288 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hrm/db"
+	"hrm/middleware"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const selectWorkflow = `SELECT w.workflow_id, w.user_id, u.username, w.template_id, w.kind, w.status,
+		TO_CHAR(w.start_date, 'YYYY-MM-DD'), w.created_by, w.created_at, w.closed_at
+	FROM workflows w JOIN users u ON u.user_id = w.user_id`
+
+const selectTask = `SELECT t.task_id, t.workflow_id, w.user_id, u.username, w.kind, t.title, COALESCE(t.description, ''),
+		t.assignee, t.assignee_id, TO_CHAR(t.due_date, 'YYYY-MM-DD'), t.status, COALESCE(t.note, ''),
+		t.completed_by, t.completed_at
+	FROM workflow_tasks t JOIN workflows w ON w.workflow_id = t.workflow_id JOIN users u ON u.user_id = w.user_id`
+
+func queryTasks(q queryer, stmt string, args ...interface{}) ([]TaskModel, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tasks := []TaskModel{}
+	for rows.Next() {
+		t := TaskModel{}
+		err := rows.Scan(&t.TaskId, &t.WorkflowId, &t.UserId, &t.Username, &t.Kind, &t.Title, &t.Description,
+			&t.Assignee, &t.AssigneeId, &t.DueDate, &t.Status, &t.Note, &t.CompletedBy, &t.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+//Returns the template of kind for a user with jobTitle in departmentId: the one naming both,
+//then the one naming either, then the one naming neither. Returns 0 if none applies
+func matchTemplate(q queryer, tenantId uint64, kind, jobTitle string, departmentId *uint64) (uint64, error) {
+	var templateId uint64
+	stmt := `SELECT template_id FROM workflow_templates
+		WHERE tenant_id = $1 AND kind = $2 AND (job_title IS NULL OR job_title = $3)
+			AND (department_id IS NULL OR department_id = $4)
+		ORDER BY (job_title IS NOT NULL)::INT + (department_id IS NOT NULL)::INT DESC, template_id
+		LIMIT 1`
+	err := q.QueryRow(stmt, tenantId, kind, jobTitle, departmentId).Scan(&templateId)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return templateId, err
+}
+
+//Starts a workflow of template t for userId, with the due dates of its tasks counted from
+//startDate. Manager tasks go to the user's manager, or to HR if the user has none
+func startWorkflow(tx *sql.Tx, t TemplateModel, userId, tenantId uint64, createdBy *uint64, startDate string) (uint64, error) {
+	var managerId sql.NullInt64
+	stmt := `SELECT manager_id FROM employees WHERE user_id = $1`
+	if err := tx.QueryRow(stmt, userId).Scan(&managerId); err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	var workflowId uint64
+	stmt = `INSERT INTO workflows(tenant_id, user_id, template_id, kind, start_date, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING workflow_id`
+	err := tx.QueryRow(stmt, tenantId, userId, t.TemplateId, t.Kind, startDate, createdBy).Scan(&workflowId)
+	if err != nil {
+		return 0, err
+	}
+	stmt = `INSERT INTO workflow_tasks(workflow_id, tenant_id, position, title, description, assignee,
+			assignee_id, due_date)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8::DATE + $9::INT)`
+	for i, task := range t.Tasks {
+		var assigneeId sql.NullInt64
+		if task.Assignee == "manager" {
+			assigneeId = managerId
+		}
+		_, err := tx.Exec(stmt, workflowId, tenantId, i+1, task.Title, task.Description, task.Assignee,
+			assigneeId, startDate, task.DueDays)
+		if err != nil {
+			return 0, err
+		}
+	}
+	//A template without tasks has nothing left to do
+	if len(t.Tasks) == 0 {
+		stmt = `UPDATE workflows SET status = 'completed', closed_at = NOW() WHERE workflow_id = $1`
+		_, err = tx.Exec(stmt, workflowId)
+	}
+	return workflowId, err
+}
+
+//Starts the offboarding of a user being terminated as of effectiveDate, from the template
+//matching their job title and current department. Their open workflows are cancelled.
+//Registered with user.OnTermination
+func StartOffboarding(tx *sql.Tx, userId, tenantId uint64, effectiveDate string) error {
+	stmt := `UPDATE workflows SET status = 'cancelled', closed_at = NOW() WHERE user_id = $1 AND status = 'open'`
+	if _, err := tx.Exec(stmt, userId); err != nil {
+		return err
+	}
+	var jobTitle sql.NullString
+	var departmentId *uint64
+	stmt = `SELECT e.job_title, a.department_id FROM employees e
+		LEFT JOIN employee_assignments a ON a.user_id = e.user_id
+			AND a.effective_from <= CURRENT_DATE AND (a.effective_to IS NULL OR a.effective_to >= CURRENT_DATE)
+		WHERE e.user_id = $1`
+	err := tx.QueryRow(stmt, userId).Scan(&jobTitle, &departmentId)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	templateId, err := matchTemplate(tx, tenantId, "offboarding", jobTitle.String, departmentId)
+	if err != nil || templateId == 0 {
+		return err
+	}
+	t, err := loadTemplate(tx, templateId, tenantId)
+	if err == nil {
+		_, err = startWorkflow(tx, t, userId, tenantId, nil, effectiveDate)
+	}
+	return err
+}
+
+//For fetching workflows, newest first. ?user_id=, ?kind= and ?status= narrow it down
+func GetWorkflows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	var userId uint64
+	if value := query.Get("user_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			res := middleware.Response{
+				Error:   true,
+				Message: "user_id must be a user id",
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		userId = id
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	stmt := selectWorkflow + ` WHERE w.tenant_id = $1 AND ($2 = 0 OR w.user_id = $2)
+		AND ($3 = '' OR w.kind = $3) AND ($4 = '' OR w.status = $4) ORDER BY w.workflow_id DESC`
+	rows, err := db.Query(stmt, middleware.TenantId(r), userId, query.Get("kind"), query.Get("status"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	defer rows.Close()
+	data := []WorkflowModel{}
+	for rows.Next() {
+		wf := WorkflowModel{}
+		err := rows.Scan(&wf.WorkflowId, &wf.UserId, &wf.Username, &wf.TemplateId, &wf.Kind, &wf.Status,
+			&wf.StartDate, &wf.CreatedBy, &wf.CreatedAt, &wf.ClosedAt)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			res := middleware.Response{
+				Error:   true,
+				Message: err.Error(),
+			}
+			json.NewEncoder(w).Encode(res)
+			return
+		}
+		data = append(data, wf)
+	}
+	//If everything went well, return array of workflow objects
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+//For fetching a single workflow with its tasks
+func GetWorkflow(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get workflow id from req params
+	params := mux.Vars(r)
+	workflowId, err := strconv.Atoi(params["workflow_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	tenantId := middleware.TenantId(r)
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	wf := WorkflowModel{}
+	row := db.QueryRow(selectWorkflow+` WHERE w.workflow_id = $1 AND w.tenant_id = $2`, uint64(workflowId), tenantId)
+	err = row.Scan(&wf.WorkflowId, &wf.UserId, &wf.Username, &wf.TemplateId, &wf.Kind, &wf.Status,
+		&wf.StartDate, &wf.CreatedBy, &wf.CreatedAt, &wf.ClosedAt)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Workflow not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err == nil {
+		wf.Tasks, err = queryTasks(db, selectTask+` WHERE t.workflow_id = $1 ORDER BY t.position`, wf.WorkflowId)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return the workflow object
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wf)
+}
+
+//For cancelling an open workflow. Its open tasks are left as they are and drop off the task lists
+func CancelWorkflow(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	//Get workflow id from req params
+	params := mux.Vars(r)
+	workflowId, err := strconv.Atoi(params["workflow_id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Unable to convert req params to int",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//Call db connection
+	db := db.ConnectDB()
+	defer db.Close()
+	var status string
+	stmt := `WITH found AS (
+			SELECT workflow_id, status FROM workflows WHERE workflow_id = $1 AND tenant_id = $2 FOR UPDATE
+		)
+		UPDATE workflows w SET status = CASE WHEN f.status = 'open' THEN 'cancelled' ELSE f.status END,
+			closed_at = CASE WHEN f.status = 'open' THEN NOW() ELSE w.closed_at END
+		FROM found f WHERE w.workflow_id = f.workflow_id RETURNING f.status`
+	err = db.QueryRow(stmt, uint64(workflowId), middleware.TenantId(r)).Scan(&status)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Workflow not found",
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		res := middleware.Response{
+			Error:   true,
+			Message: err.Error(),
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	if status != "open" {
+		w.WriteHeader(http.StatusConflict)
+		res := middleware.Response{
+			Error:   true,
+			Message: "Workflow is already " + status,
+		}
+		json.NewEncoder(w).Encode(res)
+		return
+	}
+	//If everything went well, return response
+	w.WriteHeader(http.StatusOK)
+	res := middleware.Response{
+		Error:   false,
+		Message: "Workflow cancelled",
+	}
+	json.NewEncoder(w).Encode(res)
+}